@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// Login logs in as the user with the given email and password, and returns the user. The token
+// the api hands out is sent with every later request, until Logout
+func (c *Client) Login(ctx context.Context, email, password string) (*User, error) {
+	var result struct {
+		Token Token `json:"token"`
+		User  User  `json:"user"`
+	}
+
+	err := c.do(ctx, request{
+		method: http.MethodPost,
+		path:   "/users/login",
+		body:   map[string]string{"email": email, "password": password},
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+
+	c.SetToken(result.Token.Token)
+	return &result.User, nil
+}
+
+// Logout revokes the token of the client, which stops sending it. It does nothing when the client
+// has no token
+func (c *Client) Logout(ctx context.Context) error {
+	token := c.Token()
+	if token == "" {
+		return nil
+	}
+
+	err := c.do(ctx, request{method: http.MethodPost, path: "/users/logout", body: map[string]string{"token": token}}, nil)
+	if err != nil {
+		return err
+	}
+
+	c.SetToken("")
+	return nil
+}
+
+// ValidateToken reports whether token is one the api accepts
+func (c *Client) ValidateToken(ctx context.Context, token string) (bool, error) {
+	var valid bool
+	err := c.do(ctx, request{method: http.MethodPost, path: "/validate-token", body: map[string]string{"token": token}}, &valid)
+	return valid, err
+}
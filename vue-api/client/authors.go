@@ -0,0 +1,51 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListAuthors returns every author
+func (c *Client) ListAuthors(ctx context.Context) ([]Author, error) {
+	var result struct {
+		Authors []Author `json:"authors"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: apiV1Prefix + "/authors"}, &result)
+	return result.Authors, err
+}
+
+// GetAuthor returns the author with the given id
+func (c *Client) GetAuthor(ctx context.Context, id int) (*Author, error) {
+	return c.author(ctx, request{method: http.MethodGet, path: authorPath(id)})
+}
+
+// CreateAuthor adds an author with the given name, and returns it as stored
+func (c *Client) CreateAuthor(ctx context.Context, name string) (*Author, error) {
+	return c.author(ctx, request{method: http.MethodPost, path: apiV1Prefix + "/authors", body: Author{AuthorName: name}})
+}
+
+// RenameAuthor changes the name of the author with the given id, and returns it as stored
+func (c *Client) RenameAuthor(ctx context.Context, id int, name string) (*Author, error) {
+	return c.author(ctx, request{method: http.MethodPut, path: authorPath(id), body: Author{AuthorName: name}})
+}
+
+// DeleteAuthor deletes the author with the given id, which fails with ErrInUse while they have books
+func (c *Client) DeleteAuthor(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: authorPath(id)}, nil)
+}
+
+// author sends req, and returns the author the api answers with
+func (c *Client) author(ctx context.Context, req request) (*Author, error) {
+	var result struct {
+		Author Author `json:"author"`
+	}
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.Author, nil
+}
+
+func authorPath(id int) string {
+	return fmt.Sprintf("%s/authors/%d", apiV1Prefix, id)
+}
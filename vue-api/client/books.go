@@ -0,0 +1,82 @@
+package client
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// ListBooks returns the books of the catalogue, a page of them if page is not nil
+func (c *Client) ListBooks(ctx context.Context, page *Page) ([]Book, error) {
+	path := apiV1Prefix + "/books"
+	if page != nil {
+		qs := url.Values{}
+		qs.Set("page", strconv.Itoa(page.Page))
+		qs.Set("page_size", strconv.Itoa(page.PageSize))
+		path += "?" + qs.Encode()
+	}
+
+	var result struct {
+		Books []Book `json:"books"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: path}, &result)
+	return result.Books, err
+}
+
+// GetBook returns the book with the given id
+func (c *Client) GetBook(ctx context.Context, id int) (*Book, error) {
+	return c.book(ctx, request{method: http.MethodGet, path: bookPath(id)})
+}
+
+// CreateBook adds a book, and returns it as stored
+func (c *Client) CreateBook(ctx context.Context, input BookInput) (*Book, error) {
+	return c.book(ctx, request{method: http.MethodPost, path: apiV1Prefix + "/books", body: input})
+}
+
+// UpdateBook replaces the book with the given id, and returns it as stored
+func (c *Client) UpdateBook(ctx context.Context, id int, input BookInput) (*Book, error) {
+	return c.book(ctx, request{method: http.MethodPut, path: bookPath(id), body: input})
+}
+
+// UploadCover sets the cover of the book with the given id to a jpeg image
+func (c *Client) UploadCover(ctx context.Context, id int, jpeg []byte) (*Book, error) {
+	patch := map[string]string{"cover": base64.StdEncoding.EncodeToString(jpeg)}
+	return c.book(ctx, request{method: http.MethodPatch, path: bookPath(id), body: patch, contentType: mergePatchContentType})
+}
+
+// DeleteBook moves the book with the given id to the trash
+func (c *Client) DeleteBook(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: bookPath(id)}, nil)
+}
+
+// RestoreBook takes the book with the given id out of the trash
+func (c *Client) RestoreBook(ctx context.Context, id int) (*Book, error) {
+	return c.book(ctx, request{method: http.MethodPost, path: bookPath(id) + "/restore"})
+}
+
+// ListDeletedBooks returns the books in the trash
+func (c *Client) ListDeletedBooks(ctx context.Context) ([]Book, error) {
+	var result struct {
+		Books []Book `json:"books"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: apiV1Prefix + "/books/trash"}, &result)
+	return result.Books, err
+}
+
+// book sends req, and returns the book the api answers with
+func (c *Client) book(ctx context.Context, req request) (*Book, error) {
+	var result struct {
+		Book Book `json:"book"`
+	}
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.Book, nil
+}
+
+func bookPath(id int) string {
+	return fmt.Sprintf("%s/books/%d", apiV1Prefix, id)
+}
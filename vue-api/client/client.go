@@ -0,0 +1,242 @@
+// Package client is a Go client for the versioned vue-api, mounted at /api/v1, and the login
+// routes it relies on. A Client logs in once and sends its token as a bearer token with every
+// later request; requests which are safe to repeat are retried when the api or the network
+// fails them for a moment. Failed requests return an *Error, built from the problem details
+// the api sends, which can be matched against the Err variables with errors.Is
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxRetries = 3
+	defaultBackoff    = 200 * time.Millisecond
+	defaultTimeout    = 30 * time.Second
+)
+
+// apiV1Prefix is the path the versioned api is mounted under
+const apiV1Prefix = "/api/v1"
+
+// mergePatchContentType is the media type of a JSON Merge Patch document (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// Client calls the api at a base URL. It is safe for concurrent use
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	maxRetries int
+	backoff    time.Duration
+
+	mu    sync.RWMutex
+	token string
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient makes the client send its requests with hc, rather than with an http.Client of
+// its own which times requests out after 30 seconds
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) {
+		c.httpClient = hc
+	}
+}
+
+// WithToken makes the client send token with its requests, as if it had logged in
+func WithToken(token string) Option {
+	return func(c *Client) {
+		c.token = token
+	}
+}
+
+// WithRetries sets how many times a failed request is retried, 0 for never, and how long to wait
+// before the first retry; the wait doubles for each retry after it. The default is 3 retries,
+// starting after 200ms
+func WithRetries(max int, backoff time.Duration) Option {
+	return func(c *Client) {
+		c.maxRetries = max
+		c.backoff = backoff
+	}
+}
+
+// New returns a client for the api at baseURL, such as https://books.example.com
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimSuffix(baseURL, "/"),
+		httpClient: &http.Client{Timeout: defaultTimeout},
+		maxRetries: defaultMaxRetries,
+		backoff:    defaultBackoff,
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// Token returns the token the client sends, which is empty when it has not logged in
+func (c *Client) Token() string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.token
+}
+
+// SetToken sets the token the client sends; an empty token sends none
+func (c *Client) SetToken(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.token = token
+}
+
+// request describes a call to the api
+type request struct {
+	method      string
+	path        string
+	body        interface{} // encoded as json, unless nil
+	contentType string      // of the body, if not application/json
+}
+
+// do sends req, retrying it when that is safe, and decodes the data member of the response into
+// out, unless out is nil
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var payload []byte
+	if req.body != nil {
+		var err error
+		if payload, err = json.Marshal(req.body); err != nil {
+			return err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, req, payload)
+
+		retry := attempt < c.maxRetries && ctx.Err() == nil
+		if err != nil {
+			// the request may or may not have reached the api
+			if retry && idempotent(req.method) {
+				if err := c.wait(ctx, attempt, nil); err != nil {
+					return err
+				}
+				continue
+			}
+			return err
+		}
+
+		if res.StatusCode >= http.StatusBadRequest {
+			apiErr := readError(res)
+			if retry && retryable(req.method, res.StatusCode) {
+				if err := c.wait(ctx, attempt, res); err != nil {
+					return err
+				}
+				continue
+			}
+			return apiErr
+		}
+
+		return readData(res, out)
+	}
+}
+
+// send makes one attempt at req
+func (c *Client) send(ctx context.Context, req request, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, req.method, c.baseURL+req.path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	r.Header.Set("Accept", "application/json")
+	if payload != nil {
+		contentType := req.contentType
+		if contentType == "" {
+			contentType = "application/json"
+		}
+		r.Header.Set("Content-Type", contentType)
+	}
+	if token := c.Token(); token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	return c.httpClient.Do(r)
+}
+
+// wait sleeps before retry number attempt+1, for as long as the api asked in a Retry-After
+// header, or else for the backoff doubled once per earlier retry
+func (c *Client) wait(ctx context.Context, attempt int, res *http.Response) error {
+	delay := c.backoff << attempt
+	if res != nil {
+		if after, err := time.ParseDuration(res.Header.Get("Retry-After") + "s"); err == nil && after > 0 {
+			delay = after
+		}
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// idempotent reports whether sending a request with the given method twice has the same effect
+// as sending it once, so that it may be retried when it is not known whether it arrived
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether a request which failed with status is worth sending again. Too
+// many requests were turned away before being handled, so they are always retried; the other
+// statuses may come from a request which was partly carried out
+func retryable(method string, status int) bool {
+	switch status {
+	case http.StatusTooManyRequests:
+		return true
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return idempotent(method)
+	}
+	return false
+}
+
+// readData decodes the data member of a successful response into out, and closes the body
+func readData(res *http.Response, out interface{}) error {
+	defer res.Body.Close()
+
+	if out == nil || res.StatusCode == http.StatusNoContent {
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+
+	var response struct {
+		Data json.RawMessage `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&response); err != nil {
+		return fmt.Errorf("decoding the response to %s %s: %w", res.Request.Method, res.Request.URL.Path, err)
+	}
+	if len(response.Data) == 0 {
+		return errors.New("the response to " + res.Request.Method + " " + res.Request.URL.Path + " holds no data")
+	}
+
+	return json.Unmarshal(response.Data, out)
+}
@@ -0,0 +1,153 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flakyServer fails the first failures requests it gets with status, and then answers with an
+// empty list of books. It counts the requests in calls
+func flakyServer(t *testing.T, failures int32, status int, calls *atomic.Int32) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"error": false, "message": "", "data": {"books": [], "book": {"id": 1}}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func Test_retries(t *testing.T) {
+	var tests = []struct {
+		name          string
+		status        int
+		call          func(c *Client) error
+		expectedCalls int32
+		expectedErr   *Error
+	}{
+		{"get retried", http.StatusServiceUnavailable, func(c *Client) error {
+			_, err := c.ListBooks(context.Background(), nil)
+			return err
+		}, 3, nil},
+		{"post not retried", http.StatusServiceUnavailable, func(c *Client) error {
+			_, err := c.CreateBook(context.Background(), BookInput{})
+			return err
+		}, 1, &Error{}},
+		{"post retried when turned away", http.StatusTooManyRequests, func(c *Client) error {
+			_, err := c.CreateBook(context.Background(), BookInput{})
+			return err
+		}, 3, nil},
+		{"not found not retried", http.StatusNotFound, func(c *Client) error {
+			_, err := c.GetBook(context.Background(), 1)
+			return err
+		}, 1, ErrNotFound},
+	}
+
+	for _, e := range tests {
+		var calls atomic.Int32
+		srv := flakyServer(t, 2, e.status, &calls)
+		c := New(srv.URL, WithRetries(3, time.Millisecond))
+
+		err := e.call(c)
+		switch {
+		case e.expectedErr == nil && err != nil:
+			t.Errorf("%s: expected success, got %v", e.name, err)
+		case e.expectedErr != nil && err == nil:
+			t.Errorf("%s: expected an error", e.name)
+		case e.expectedErr != nil && e.expectedErr.Code != "" && !errors.Is(err, e.expectedErr):
+			t.Errorf("%s: expected %v, got %v", e.name, e.expectedErr, err)
+		}
+
+		if calls.Load() != e.expectedCalls {
+			t.Errorf("%s: expected %d request(s), got %d", e.name, e.expectedCalls, calls.Load())
+		}
+	}
+}
+
+func Test_retries_giveUp(t *testing.T) {
+	var calls atomic.Int32
+	srv := flakyServer(t, 10, http.StatusBadGateway, &calls)
+	c := New(srv.URL, WithRetries(2, time.Millisecond))
+
+	_, err := c.ListBooks(context.Background(), nil)
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) || apiErr.Status != http.StatusBadGateway {
+		t.Errorf("expected the last failure to be returned, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("expected the request and 2 retries, got %d request(s)", calls.Load())
+	}
+}
+
+func Test_retries_cancelled(t *testing.T) {
+	var calls atomic.Int32
+	srv := flakyServer(t, 10, http.StatusServiceUnavailable, &calls)
+	c := New(srv.URL, WithRetries(3, time.Hour))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := c.ListBooks(ctx, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected waiting to retry to stop with the context, got %v", err)
+	}
+}
+
+func Test_readError(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/problem+json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write([]byte(`{"type": "urn:vue-api:problem:edit_conflict", "title": "Conflict", "status": 409,
+			"code": "edit_conflict", "error": true, "message": "someone else changed it", "data": {"book": {"id": 4}}}`))
+	}))
+	defer srv.Close()
+
+	_, err := New(srv.URL).UpdateBook(context.Background(), 4, BookInput{Version: 1})
+
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected an *Error, got %v", err)
+	}
+	if !errors.Is(err, ErrEditConflict) || errors.Is(err, ErrNotFound) {
+		t.Errorf("expected only ErrEditConflict to match, got %v", err)
+	}
+	if apiErr.Status != http.StatusConflict || apiErr.Message != "someone else changed it" || string(apiErr.Data) != `{"book": {"id": 4}}` {
+		t.Errorf("unexpected error %+v", apiErr)
+	}
+}
+
+func Test_bearerToken(t *testing.T) {
+	var authorization string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		_, _ = w.Write([]byte(`{"data": {"books": []}}`))
+	}))
+	defer srv.Close()
+
+	c := New(srv.URL, WithToken("abc"))
+	if _, err := c.ListBooks(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "Bearer abc" {
+		t.Errorf("expected the token to be sent, got %q", authorization)
+	}
+
+	c.SetToken("")
+	if _, err := c.ListBooks(context.Background(), nil); err != nil {
+		t.Fatal(err)
+	}
+	if authorization != "" {
+		t.Errorf("expected no token to be sent, got %q", authorization)
+	}
+}
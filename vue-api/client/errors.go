@@ -0,0 +1,98 @@
+package client
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// Error is a request the api turned down. The api sends RFC 7807 problem details, whose code
+// says what went wrong, and which keep the error and message members of its older responses
+type Error struct {
+	Status  int    `json:"status"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Detail  string `json:"detail"`
+
+	// Errors holds the problems found with each field of a request which failed validation
+	Errors map[string][]string `json:"errors"`
+
+	// Data holds anything else the api sent, such as the current state of a record which was
+	// changed by someone else in the meantime
+	Data json.RawMessage `json:"data"`
+}
+
+func (e *Error) Error() string {
+	msg := fmt.Sprintf("vue-api: %d %s", e.Status, e.Code)
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	if e.Detail != "" {
+		msg += " (" + e.Detail + ")"
+	}
+	return msg
+}
+
+// Is reports whether target is an *Error with the same code, so that errors.Is(err, ErrNotFound)
+// matches whatever the message and status
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// The errors the api reports, by their code, to be matched with errors.Is
+var (
+	ErrBadRequest          = &Error{Code: "bad_request"}
+	ErrInvalidJSON         = &Error{Code: "invalid_json"}
+	ErrValidationFailed    = &Error{Code: "validation_failed"}
+	ErrInvalidPrecondition = &Error{Code: "invalid_precondition"}
+	ErrUnauthorized        = &Error{Code: "unauthorized"}
+	ErrInvalidCredentials  = &Error{Code: "invalid_credentials"}
+	ErrUserInactive        = &Error{Code: "user_inactive"}
+	ErrForbidden           = &Error{Code: "forbidden"}
+	ErrNotFound            = &Error{Code: "not_found"}
+	ErrNotInTrash          = &Error{Code: "not_in_trash"}
+	ErrRevisionMismatch    = &Error{Code: "revision_mismatch"}
+	ErrEditConflict        = &Error{Code: "edit_conflict"}
+	ErrInUse               = &Error{Code: "in_use"}
+	ErrDuplicateValue      = &Error{Code: "duplicate_value"}
+	ErrValueTooLong        = &Error{Code: "value_too_long"}
+	ErrForeignKeyViolation = &Error{Code: "foreign_key_violation"}
+	ErrUnsupportedMedia    = &Error{Code: "unsupported_media_type"}
+	ErrInternal            = &Error{Code: "internal_error"}
+)
+
+// statusCodes holds the code of an error which only has an http status to go on, such as one
+// sent by a proxy in front of the api
+var statusCodes = map[int]string{
+	http.StatusBadRequest:           ErrBadRequest.Code,
+	http.StatusUnauthorized:         ErrUnauthorized.Code,
+	http.StatusForbidden:            ErrForbidden.Code,
+	http.StatusNotFound:             ErrNotFound.Code,
+	http.StatusConflict:             ErrEditConflict.Code,
+	http.StatusUnsupportedMediaType: ErrUnsupportedMedia.Code,
+	http.StatusUnprocessableEntity:  ErrValidationFailed.Code,
+	http.StatusInternalServerError:  ErrInternal.Code,
+}
+
+// readError builds the error for a failed response, and closes the body
+func readError(res *http.Response) *Error {
+	defer res.Body.Close()
+
+	var e Error
+	body, _ := io.ReadAll(io.LimitReader(res.Body, 1<<20))
+	if err := json.Unmarshal(body, &e); err != nil {
+		e = Error{}
+	}
+
+	e.Status = res.StatusCode
+	if e.Code == "" {
+		e.Code = statusCodes[res.StatusCode]
+	}
+	if e.Message == "" {
+		e.Message = http.StatusText(res.StatusCode)
+	}
+
+	return &e
+}
@@ -0,0 +1,92 @@
+package client
+
+import "time"
+
+// The records of the api, as it sends them
+
+// Token is a token a user logged in with, to be sent as a bearer token
+type Token struct {
+	UserID int       `json:"user_id"`
+	Token  string    `json:"token"`
+	Expiry time.Time `json:"expiry"`
+}
+
+// User is a user of the admin side of the api
+type User struct {
+	ID        int        `json:"id"`
+	Email     string     `json:"email"`
+	FirstName string     `json:"first_name"`
+	LastName  string     `json:"last_name"`
+	Active    int        `json:"active"`
+	Language  string     `json:"language"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+}
+
+// Book is a book of the catalogue
+type Book struct {
+	ID              int        `json:"id"`
+	Title           string     `json:"title"`
+	AuthorID        int        `json:"author_id"`
+	PublicationYear int        `json:"publication_year"`
+	Slug            string     `json:"slug"`
+	Author          Author     `json:"author"`
+	Description     string     `json:"description"`
+	Genres          []Genre    `json:"genres"`
+	GenreIDs        []int      `json:"genre_ids"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	Version         int        `json:"version"`
+}
+
+// Author is the author of books
+type Author struct {
+	ID         int       `json:"id"`
+	AuthorName string    `json:"author_name"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// Genre is a genre books belong to
+type Genre struct {
+	ID        int       `json:"id"`
+	GenreName string    `json:"genre_name"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// BookInput holds the fields of a book to add, or to replace those of an existing one with.
+// Version is that of the book the change was based on; when it is not 0 the change is turned
+// down with ErrEditConflict if the book has changed since
+type BookInput struct {
+	Title           string `json:"title"`
+	AuthorID        int    `json:"author_id"`
+	PublicationYear int    `json:"publication_year"`
+	Description     string `json:"description"`
+	GenreIDs        []int  `json:"genre_ids"`
+	Version         int    `json:"version,omitempty"`
+
+	// Cover is a jpeg image, which is left as it is when empty
+	Cover []byte `json:"cover,omitempty"`
+}
+
+// UserInput holds the fields of a user to add, or to replace those of an existing one with.
+// The password is only changed when one is given. Version works as it does for BookInput
+type UserInput struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Password  string `json:"password,omitempty"`
+	Active    int    `json:"active"`
+	Language  string `json:"language,omitempty"`
+	Version   int    `json:"version,omitempty"`
+}
+
+// Page selects a page of a list, counting from 1. PageSize is at most 100
+type Page struct {
+	Page     int
+	PageSize int
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+// ListUsers returns every user who is not in the trash
+func (c *Client) ListUsers(ctx context.Context) ([]User, error) {
+	var result struct {
+		Users []User `json:"users"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: apiV1Prefix + "/users"}, &result)
+	return result.Users, err
+}
+
+// GetUser returns the user with the given id
+func (c *Client) GetUser(ctx context.Context, id int) (*User, error) {
+	return c.user(ctx, request{method: http.MethodGet, path: userPath(id)})
+}
+
+// CreateUser adds a user, and returns it as stored
+func (c *Client) CreateUser(ctx context.Context, input UserInput) (*User, error) {
+	return c.user(ctx, request{method: http.MethodPost, path: apiV1Prefix + "/users", body: input})
+}
+
+// UpdateUser replaces the user with the given id, and returns it as stored
+func (c *Client) UpdateUser(ctx context.Context, id int, input UserInput) (*User, error) {
+	return c.user(ctx, request{method: http.MethodPut, path: userPath(id), body: input})
+}
+
+// DeleteUser moves the user with the given id to the trash
+func (c *Client) DeleteUser(ctx context.Context, id int) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: userPath(id)}, nil)
+}
+
+// RestoreUser takes the user with the given id out of the trash
+func (c *Client) RestoreUser(ctx context.Context, id int) (*User, error) {
+	return c.user(ctx, request{method: http.MethodPost, path: userPath(id) + "/restore"})
+}
+
+// LogUserOut deactivates the user with the given id and revokes their tokens
+func (c *Client) LogUserOut(ctx context.Context, id int) (*User, error) {
+	return c.user(ctx, request{method: http.MethodPost, path: userPath(id) + "/logout"})
+}
+
+// user sends req, and returns the user the api answers with
+func (c *Client) user(ctx context.Context, req request) (*User, error) {
+	var result struct {
+		User User `json:"user"`
+	}
+	if err := c.do(ctx, req, &result); err != nil {
+		return nil, err
+	}
+	return &result.User, nil
+}
+
+func userPath(id int) string {
+	return fmt.Sprintf("%s/users/%d", apiV1Prefix, id)
+}
@@ -0,0 +1,543 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"vue-api/internal/data"
+
+	"github.com/mozillazg/go-slugify"
+)
+
+// The functions in this file carry out the changes requested through the admin api. They are
+// shared by the original POST-only admin handlers and the /api/v1 resource handlers, so that
+// both record revisions, write the audit log and check versions in exactly the same way.
+
+// conflictError is returned when an edit is rejected because the record was changed since
+// the client read it. It holds the current state of the record, which errorJSON sends back
+type conflictError struct {
+	name    string
+	current interface{}
+	version int
+}
+
+func (e *conflictError) Error() string {
+	return data.ErrEditConflict.Error()
+}
+
+func (e *conflictError) Unwrap() error {
+	return data.ErrEditConflict
+}
+
+// bookInput is the JSON payload used to add or edit a book
+type bookInput struct {
+	ID              int    `json:"id"`
+	Title           string `json:"title" validate:"required,max=512"`
+	AuthorID        int    `json:"author_id" validate:"required,min=1"`
+	PublicationYear int    `json:"publication_year" validate:"required,min=1"`
+	Description     string `json:"description"`
+	CoverBase64     string `json:"cover"`
+	GenreIDs        []int  `json:"genre_ids"`
+	Version         int    `json:"version"`
+}
+
+// saveBook adds a new book when input has no id, or updates an existing one, and returns the
+// book as stored in the database
+func (app *application) saveBook(r *http.Request, input bookInput) (*data.Book, error) {
+	version, err := app.expectedVersion(r, input.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.validateBook(r, input); err != nil {
+		return nil, err
+	}
+
+	book := data.Book{
+		ID:              input.ID,
+		Title:           input.Title,
+		AuthorID:        input.AuthorID,
+		PublicationYear: input.PublicationYear,
+		Description:     input.Description,
+		Slug:            slugify.Slugify(input.Title),
+		GenreIDs:        input.GenreIDs,
+		Version:         version,
+	}
+
+	var cover []byte
+	if len(input.CoverBase64) > 0 {
+		// we have a cover; it is only written once the book has been saved
+		cover, err = base64.StdEncoding.DecodeString(input.CoverBase64)
+		if err != nil {
+			return nil, badRequest(codeInvalidJSON, err)
+		}
+		app.metrics.coverUploaded(len(cover))
+	}
+
+	// the book, its revisions and the audit log entry are saved together, so that a rejected edit
+	// leaves no trace
+	var before, after *data.Book
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		if book.ID == 0 {
+			// adding a book
+			newID, err := tx.Book.Insert(r.Context(), book)
+			if err != nil {
+				return err
+			}
+			book.ID = newID
+		} else {
+			var err error
+			before, err = tx.Book.GetOneById(r.Context(), book.ID)
+			if err != nil {
+				return err
+			}
+
+			// books saved before revisions were recorded have no history, so keep the
+			// state they are in now before it gets overwritten
+			hasRevisions, err := tx.BookRevision.HasRevisions(r.Context(), book.ID)
+			if err != nil {
+				return err
+			}
+
+			if !hasRevisions {
+				if _, err := tx.BookRevision.Record(r.Context(), book.ID, 0); err != nil {
+					return err
+				}
+			}
+
+			// updating a book
+			if err := tx.Book.Update(r.Context(), &book); err != nil {
+				return err
+			}
+		}
+
+		// store the saved book as a new revision
+		if _, err := tx.BookRevision.Record(r.Context(), book.ID, app.authenticatedUserID(r)); err != nil {
+			return err
+		}
+
+		var err error
+		if after, err = tx.Book.GetOneById(r.Context(), book.ID); err != nil {
+			return err
+		}
+
+		if before == nil {
+			return app.auditTx(r, tx, "book.create", "book", book.ID, nil, after)
+		}
+		return app.auditTx(r, tx, "book.update", "book", book.ID, before, after)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return nil, app.bookConflict(r.Context(), book.ID)
+		}
+		return nil, err
+	}
+
+	if cover != nil {
+		// write image to /static/covers
+		if err := app.writeCover(r.Context(), book.Slug, cover); err != nil {
+			return nil, err
+		}
+	}
+
+	return after, nil
+}
+
+// bookConflict builds the error returned when an edit to a book is rejected
+func (app *application) bookConflict(ctx context.Context, id int) error {
+	current, err := app.models.Book.GetOneById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return &conflictError{name: "book", current: current, version: current.Version}
+}
+
+// deleteBook moves a book to the trash
+func (app *application) deleteBook(r *http.Request, id int) error {
+	return app.models.Transaction(r.Context(), func(tx data.Models) error {
+		before, err := tx.Book.GetOneById(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Book.DeleteByID(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "book.delete", "book", id, before, nil)
+	})
+}
+
+// restoreBook takes a book out of the trash
+func (app *application) restoreBook(r *http.Request, id int) (*data.Book, error) {
+	var after *data.Book
+	err := app.models.Transaction(r.Context(), func(tx data.Models) error {
+		err := tx.Book.Restore(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		after, err = tx.Book.GetOneById(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "book.restore", "book", id, nil, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// revertBook puts a book back to the state stored in one of its revisions. The revert is itself
+// recorded as a new revision, and written to the audit log in the same transaction. Like an edit,
+// it is rejected when the book is no longer at the version the client expected, if it sent one
+func (app *application) revertBook(r *http.Request, bookID, revisionID, payloadVersion int) (*data.Book, error) {
+	version, err := app.expectedVersion(r, payloadVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	revision, err := app.bookRevision(r.Context(), bookID, revisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	var after *data.Book
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		before, err := tx.Book.GetOneById(r.Context(), bookID)
+		if err != nil {
+			return err
+		}
+
+		book := revision.Book()
+		book.Version = version
+		if err := tx.Book.Update(r.Context(), &book); err != nil {
+			return err
+		}
+
+		if _, err := tx.BookRevision.Record(r.Context(), bookID, app.authenticatedUserID(r)); err != nil {
+			return err
+		}
+
+		if after, err = tx.Book.GetOneById(r.Context(), bookID); err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "book.revert", "book", bookID, before, after)
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return nil, app.bookConflict(r.Context(), bookID)
+		}
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// bookRevision gets one revision by id, making sure it belongs to the given book
+func (app *application) bookRevision(ctx context.Context, bookID, revisionID int) (*data.BookRevision, error) {
+	revision, err := app.models.BookRevision.GetOne(ctx, revisionID)
+	if err != nil {
+		return nil, err
+	}
+
+	if revision.BookID != bookID {
+		return nil, data.ErrRevisionMismatch
+	}
+
+	return revision, nil
+}
+
+// saveUser adds a new user when input has no id, or updates an existing one, and returns the
+// user as stored in the database. The password is only changed when one is supplied
+func (app *application) saveUser(r *http.Request, input data.User) (*data.User, error) {
+	if err := app.validateUser(r, input); err != nil {
+		return nil, err
+	}
+
+	if input.ID == 0 {
+		// add user
+		var after *data.User
+		err := app.models.Transaction(r.Context(), func(tx data.Models) error {
+			newID, err := tx.User.Insert(r.Context(), input)
+			if err != nil {
+				return err
+			}
+
+			after, err = tx.User.GetOne(r.Context(), newID)
+			if err != nil {
+				return err
+			}
+
+			return app.auditTx(r, tx, "user.create", "user", newID, nil, userSnapshot(after))
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return userSnapshot(after), nil
+	}
+
+	// editing user
+	u, err := app.models.User.GetOne(r.Context(), input.ID)
+	if err != nil {
+		return nil, err
+	}
+	before := userSnapshot(u)
+
+	u.Version, err = app.expectedVersion(r, input.Version)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Email = input.Email
+	u.FirstName = input.FirstName
+	u.LastName = input.LastName
+	u.Active = input.Active
+	u.Language = input.Language
+
+	// the edit, the new password and their audit log entries are saved together, so that none is
+	// kept if another fails
+	err = app.models.Transaction(r.Context(), func(tx data.Models) error {
+		if err := tx.User.Update(r.Context(), u); err != nil {
+			return err
+		}
+
+		if err := app.auditTx(r, tx, "user.update", "user", u.ID, before, userSnapshot(u)); err != nil {
+			return err
+		}
+
+		// if password != string, update password
+		if input.Password != "" {
+			if err := tx.User.ResetPassword(r.Context(), u.ID, input.Password); err != nil {
+				return err
+			}
+			return app.auditTx(r, tx, "user.password_reset", "user", u.ID, nil, nil)
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, data.ErrEditConflict) {
+			return nil, app.userConflict(r.Context(), input.ID)
+		}
+		return nil, err
+	}
+
+	return userSnapshot(u), nil
+}
+
+// userConflict builds the error returned when an edit to a user is rejected
+func (app *application) userConflict(ctx context.Context, id int) error {
+	current, err := app.models.User.GetOne(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return &conflictError{name: "user", current: userSnapshot(current), version: current.Version}
+}
+
+// deleteUser moves a user to the trash, logging them out
+func (app *application) deleteUser(r *http.Request, id int) error {
+	return app.models.Transaction(r.Context(), func(tx data.Models) error {
+		before, err := tx.User.GetOne(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.User.DeleteByID(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "user.delete", "user", id, userSnapshot(before), nil)
+	})
+}
+
+// restoreUser takes a user out of the trash
+func (app *application) restoreUser(r *http.Request, id int) (*data.User, error) {
+	var after *data.User
+	err := app.models.Transaction(r.Context(), func(tx data.Models) error {
+		err := tx.User.Restore(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		after, err = tx.User.GetOne(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "user.restore", "user", id, nil, userSnapshot(after))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userSnapshot(after), nil
+}
+
+// logUserOut sets a user to inactive, and deletes all of their tokens in the same transaction
+func (app *application) logUserOut(r *http.Request, id int) (*data.User, error) {
+	var user *data.User
+	err := app.models.Transaction(r.Context(), func(tx data.Models) error {
+		var err error
+		user, err = tx.User.GetOne(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		before := userSnapshot(user)
+
+		// the user is being locked out regardless of any other edits, so skip the version check
+		user.Version = 0
+		user.Active = 0
+		if err := tx.User.Update(r.Context(), user); err != nil {
+			return err
+		}
+
+		// delete tokens for user
+		if err := tx.Token.DeleteTokensForUser(r.Context(), id); err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "user.logout", "user", id, before, userSnapshot(user))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return userSnapshot(user), nil
+}
+
+// saveAuthor adds a new author when input has no id, or renames an existing one
+func (app *application) saveAuthor(r *http.Request, input data.Author) (*data.Author, error) {
+	if err := app.validate(r, authorRules{AuthorName: input.AuthorName}); err != nil {
+		return nil, err
+	}
+
+	var after *data.Author
+	err := app.models.Transaction(r.Context(), func(tx data.Models) error {
+		if input.ID == 0 {
+			newID, err := tx.Author.Insert(r.Context(), input)
+			if err != nil {
+				return err
+			}
+
+			after, err = tx.Author.GetOne(r.Context(), newID)
+			if err != nil {
+				return err
+			}
+
+			return app.auditTx(r, tx, "author.create", "author", newID, nil, after)
+		}
+
+		before, err := tx.Author.GetOne(r.Context(), input.ID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Author.Update(r.Context(), &input)
+		if err != nil {
+			return err
+		}
+
+		after, err = tx.Author.GetOne(r.Context(), input.ID)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "author.update", "author", input.ID, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// deleteAuthor deletes an author who has no books
+func (app *application) deleteAuthor(r *http.Request, id int) error {
+	return app.models.Transaction(r.Context(), func(tx data.Models) error {
+		before, err := tx.Author.GetOne(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Author.DeleteByID(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "author.delete", "author", id, before, nil)
+	})
+}
+
+// saveGenre adds a new genre when input has no id, or renames an existing one
+func (app *application) saveGenre(r *http.Request, input data.Genre) (*data.Genre, error) {
+	if err := app.validate(r, genreRules{GenreName: input.GenreName}); err != nil {
+		return nil, err
+	}
+
+	var after *data.Genre
+	err := app.models.Transaction(r.Context(), func(tx data.Models) error {
+		if input.ID == 0 {
+			newID, err := tx.Genre.Insert(r.Context(), input)
+			if err != nil {
+				return err
+			}
+
+			after, err = tx.Genre.GetOne(r.Context(), newID)
+			if err != nil {
+				return err
+			}
+
+			return app.auditTx(r, tx, "genre.create", "genre", newID, nil, after)
+		}
+
+		before, err := tx.Genre.GetOne(r.Context(), input.ID)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Genre.Update(r.Context(), &input)
+		if err != nil {
+			return err
+		}
+
+		after, err = tx.Genre.GetOne(r.Context(), input.ID)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "genre.update", "genre", input.ID, before, after)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return after, nil
+}
+
+// deleteGenre deletes a genre which is not assigned to any book
+func (app *application) deleteGenre(r *http.Request, id int) error {
+	return app.models.Transaction(r.Context(), func(tx data.Models) error {
+		before, err := tx.Genre.GetOne(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		err = tx.Genre.DeleteByID(r.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		return app.auditTx(r, tx, "genre.delete", "genre", id, before, nil)
+	})
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+	"vue-api/internal/data"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 500
+)
+
+// cliActor is the actor recorded in the audit log for changes made with the admin commands
+const cliActor = "cli"
+
+// auditCSVHeader is the first line of an exported audit log
+var auditCSVHeader = []string{"id", "created_at", "actor_id", "actor_email", "action", "entity", "entity_id", "before", "after", "ip", "request_id"}
+
+// auditTx appends an entry to the audit log describing a change made by the user making the
+// request. before and after are snapshots of the entity, and either may be nil. The entry is
+// written through tx, the models of the transaction making the change, so that one is never kept
+// without the other; errors are returned, for the transaction to be rolled back
+func (app *application) auditTx(r *http.Request, tx data.Models, action, entity string, entityID int, before, after interface{}) error {
+	entry := app.requestAuditEntry(r, action, entity, entityID)
+
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		return err
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		return err
+	}
+
+	return tx.Audit.Insert(r.Context(), entry)
+}
+
+// requestAuditEntry returns an audit log entry for a change made by the user making the request
+func (app *application) requestAuditEntry(r *http.Request, action, entity string, entityID int) data.AuditEntry {
+	entry := data.AuditEntry{
+		Action:    action,
+		Entity:    entity,
+		EntityID:  entityID,
+		IP:        remoteIP(r),
+		RequestID: requestIDFrom(r.Context()),
+	}
+
+	if user := app.authenticatedUser(r); user != nil {
+		entry.ActorID = user.ID
+		entry.ActorEmail = user.Email
+	}
+
+	return entry
+}
+
+// auditCommand appends an entry to the audit log describing a change made from the command line,
+// where there is no user to blame; the entry's actor is recorded as cliActor
+func (app *application) auditCommand(ctx context.Context, action, entity string, entityID int, before, after interface{}) {
+	entry := data.AuditEntry{
+		ActorEmail: cliActor,
+		Action:     action,
+		Entity:     entity,
+		EntityID:   entityID,
+	}
+
+	app.writeAudit(ctx, entry, before, after)
+}
+
+// writeAudit adds the snapshots to entry and stores it, logging any failure
+func (app *application) writeAudit(ctx context.Context, entry data.AuditEntry, before, after interface{}) {
+	var err error
+	if entry.Before, err = auditSnapshot(before); err != nil {
+		app.logger.ErrorContext(ctx, "could not encode audit snapshot", "error", err)
+	}
+	if entry.After, err = auditSnapshot(after); err != nil {
+		app.logger.ErrorContext(ctx, "could not encode audit snapshot", "error", err)
+	}
+
+	// the change being recorded has been made, so the entry is written even if the client has
+	// gone away in the meantime
+	if err := app.models.Audit.Insert(context.WithoutCancel(ctx), entry); err != nil {
+		app.logger.ErrorContext(ctx, "could not write audit log entry", "action", entry.Action, "entity", entry.Entity, "entity_id", entry.EntityID, "error", err)
+	}
+}
+
+// auditSnapshot encodes an entity as JSON for the audit log; nil values give an empty snapshot
+func auditSnapshot(v interface{}) (json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	out, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if string(out) == "null" {
+		return nil, nil
+	}
+
+	return out, nil
+}
+
+// userSnapshot returns a copy of a user that is safe to store in the audit log, without the password hash
+func userSnapshot(u *data.User) *data.User {
+	if u == nil {
+		return nil
+	}
+
+	snapshot := *u
+	snapshot.Password = ""
+	snapshot.Token = data.Token{}
+
+	return &snapshot
+}
+
+// remoteIP returns the address of the client making the request, without the port
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// writeAuditCSV writes audit log entries as CSV, preceded by a header line
+func writeAuditCSV(w io.Writer, entries []*data.AuditEntry) error {
+	cw := csv.NewWriter(w)
+
+	if err := cw.Write(auditCSVHeader); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		record := []string{
+			strconv.Itoa(e.ID),
+			e.CreatedAt.Format(time.RFC3339),
+			strconv.Itoa(e.ActorID),
+			e.ActorEmail,
+			e.Action,
+			e.Entity,
+			strconv.Itoa(e.EntityID),
+			string(e.Before),
+			string(e.After),
+			e.IP,
+			e.RequestID,
+		}
+
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+	"vue-api/internal/data"
+)
+
+func Test_writeAuditCSV(t *testing.T) {
+	entries := []*data.AuditEntry{
+		{
+			ID:         1,
+			ActorID:    2,
+			ActorEmail: "admin@example.com",
+			Action:     "book.update",
+			Entity:     "book",
+			EntityID:   3,
+			Before:     json.RawMessage(`{"title":"Old, title"}`),
+			After:      json.RawMessage(`{"title":"New title"}`),
+			IP:         "127.0.0.1",
+			RequestID:  "host/abc-000001",
+			CreatedAt:  time.Now(),
+		},
+	}
+
+	var buf bytes.Buffer
+	err := writeAuditCSV(&buf, entries)
+	if err != nil {
+		t.Fatal("failed to write csv: ", err)
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal("did not produce valid csv: ", err)
+	}
+
+	if len(records) != 2 {
+		t.Fatalf("expected header and one record, but got %d lines", len(records))
+	}
+
+	if records[1][7] != `{"title":"Old, title"}` {
+		t.Errorf("before snapshot not written correctly, got %s", records[1][7])
+	}
+}
+
+func Test_userSnapshot(t *testing.T) {
+	u := &data.User{ID: 1, Email: "me@here.com", Password: "$2a$12$hash"}
+
+	snapshot := userSnapshot(u)
+	if snapshot.Password != "" {
+		t.Error("password hash included in user snapshot")
+	}
+
+	if u.Password == "" {
+		t.Error("userSnapshot modified the original user")
+	}
+
+	if userSnapshot(nil) != nil {
+		t.Error("expected nil snapshot of nil user")
+	}
+
+	out, _ := auditSnapshot((*data.Book)(nil))
+	if out != nil {
+		t.Error("expected empty snapshot of nil book")
+	}
+}
+
+func Test_auditTx_rollsBack(t *testing.T) {
+	cfg := defaultConfig()
+	cfg.db.driver = "sqlite"
+	cfg.db.dsn = filepath.Join(t.TempDir(), "test.db")
+
+	store, err := openStorage(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	ctx := context.Background()
+	if _, err := store.migrator.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{config: cfg, logger: discardLogger(), models: store.models}
+
+	genreID, err := app.models.Genre.Insert(ctx, data.Genre{GenreName: "Poetry"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodDelete, "/", nil)
+	deletedID, err := app.models.Genre.Insert(ctx, data.Genre{GenreName: "Drama"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := app.deleteGenre(req, deletedID); err != nil {
+		t.Fatal(err)
+	}
+
+	// with nowhere to write the audit log, no change may be made
+	if _, err := store.db.ExecContext(ctx, "drop table audit_log"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.deleteGenre(req, genreID); err == nil {
+		t.Fatal("expected deleting the genre to fail")
+	}
+
+	if _, err := app.models.Genre.GetOne(ctx, genreID); err != nil {
+		t.Errorf("expected the genre to be kept when its audit entry could not be written, got %v", err)
+	}
+}
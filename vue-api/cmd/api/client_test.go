@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"vue-api/client"
+	"vue-api/internal/seed"
+)
+
+// Test_client runs the client against the real router, over http, with the data kept in memory
+func Test_client(t *testing.T) {
+	app, password := newMemoryTestApp(t)
+	srv := httptest.NewServer(app.routes())
+	defer srv.Close()
+
+	ctx := context.Background()
+	c := client.New(srv.URL, client.WithRetries(0, 0))
+
+	// the catalogue is public
+	books, err := c.ListBooks(ctx, nil)
+	if err != nil || len(books) != 3 {
+		t.Fatalf("expected the 3 seeded books, got %d and %v", len(books), err)
+	}
+	if page, err := c.ListBooks(ctx, &client.Page{Page: 1, PageSize: 2}); err != nil || len(page) != 2 {
+		t.Errorf("expected a page of 2 books, got %d and %v", len(page), err)
+	}
+
+	// changes are not
+	_, err = c.CreateBook(ctx, client.BookInput{Title: "Anonymous"})
+	var apiErr *client.Error
+	if !errors.As(err, &apiErr) || !errors.Is(err, client.ErrUnauthorized) || apiErr.Status != 401 {
+		t.Fatalf("expected ErrUnauthorized with status 401, got %v", err)
+	}
+
+	if _, err := c.Login(ctx, seed.AdminEmail, "not the password"); !errors.Is(err, client.ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	admin, err := c.Login(ctx, seed.AdminEmail, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if admin.Email != seed.AdminEmail || c.Token() == "" {
+		t.Fatalf("expected to be logged in as %s with a token, got %s", seed.AdminEmail, admin.Email)
+	}
+	if valid, err := c.ValidateToken(ctx, c.Token()); err != nil || !valid {
+		t.Errorf("expected the token to be valid, got %v and %v", valid, err)
+	}
+
+	// authors
+	author, err := c.CreateAuthor(ctx, "Ann Client")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if author, err = c.RenameAuthor(ctx, author.ID, "Ann B. Client"); err != nil || author.AuthorName != "Ann B. Client" {
+		t.Fatalf("expected the author to be renamed, got %+v and %v", author, err)
+	}
+	if _, err := c.GetAuthor(ctx, author.ID); err != nil {
+		t.Error(err)
+	}
+	if authors, err := c.ListAuthors(ctx); err != nil || len(authors) != 3 {
+		t.Errorf("expected 3 authors, got %d and %v", len(authors), err)
+	}
+
+	// books
+	_, err = c.CreateBook(ctx, client.BookInput{AuthorID: author.ID})
+	if !errors.Is(err, client.ErrValidationFailed) || !errors.As(err, &apiErr) || len(apiErr.Errors["title"]) == 0 {
+		t.Fatalf("expected ErrValidationFailed with a problem with the title, got %v", err)
+	}
+
+	input := client.BookInput{Title: "Typed Calls", AuthorID: author.ID, PublicationYear: 2024, Description: "On clients.", GenreIDs: []int{1}}
+	book, err := c.CreateBook(ctx, input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if book.ID == 0 || book.Slug != "typed-calls" || book.Author.AuthorName != "Ann B. Client" {
+		t.Fatalf("unexpected book %+v", book)
+	}
+
+	input.Description = "On typed clients."
+	input.Version = book.Version
+	if book, err = c.UpdateBook(ctx, book.ID, input); err != nil || book.Description != input.Description {
+		t.Fatalf("expected the book to be updated, got %+v and %v", book, err)
+	}
+
+	// the update above was based on the first version, which is gone now
+	if _, err := c.UpdateBook(ctx, book.ID, input); !errors.Is(err, client.ErrEditConflict) {
+		t.Errorf("expected ErrEditConflict for a stale version, got %v", err)
+	}
+
+	jpeg := []byte{0xff, 0xd8, 0xff, 0xe0, 'c', 'o', 'v', 'e', 'r'}
+	if _, err := c.UploadCover(ctx, book.ID, jpeg); err != nil {
+		t.Fatal(err)
+	}
+	if cover, err := os.ReadFile(app.coverPath(book.Slug)); err != nil || !bytes.Equal(cover, jpeg) {
+		t.Errorf("expected the cover to be written, got %v and %v", cover, err)
+	}
+
+	// and served from the static path it was written to
+	res, err := http.Get(srv.URL + "/static/covers/" + book.Slug + ".jpg")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+	if res.StatusCode != http.StatusOK || !bytes.Equal(served, jpeg) {
+		t.Errorf("expected the cover to be served, got status %d", res.StatusCode)
+	}
+
+	if err := c.DeleteBook(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBook(ctx, book.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a book in the trash, got %v", err)
+	}
+	if trash, err := c.ListDeletedBooks(ctx); err != nil || len(trash) != 1 || trash[0].ID != book.ID {
+		t.Errorf("expected the book in the trash, got %+v and %v", trash, err)
+	}
+	if _, err := c.RestoreBook(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetBook(ctx, book.ID); err != nil {
+		t.Errorf("expected the book to be back, got %v", err)
+	}
+
+	// users
+	user, err := c.CreateUser(ctx, client.UserInput{Email: "reader@example.com", FirstName: "Rea", LastName: "Der", Password: "correct horse battery", Active: 1, Language: "en"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user, err = c.UpdateUser(ctx, user.ID, client.UserInput{Email: user.Email, FirstName: "Reader", LastName: user.LastName, Active: 1, Language: "es"}); err != nil || user.FirstName != "Reader" {
+		t.Fatalf("expected the user to be updated, got %+v and %v", user, err)
+	}
+	if user, err = c.LogUserOut(ctx, user.ID); err != nil || user.Active != 0 {
+		t.Fatalf("expected the user to be deactivated, got %+v and %v", user, err)
+	}
+	if err := c.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.GetUser(ctx, user.ID); !errors.Is(err, client.ErrNotFound) {
+		t.Errorf("expected ErrNotFound for a user in the trash, got %v", err)
+	}
+	if _, err := c.RestoreUser(ctx, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if users, err := c.ListUsers(ctx); err != nil || len(users) != 3 {
+		t.Errorf("expected 3 users, got %d and %v", len(users), err)
+	}
+
+	// logging out revokes the token
+	token := c.Token()
+	if err := c.Logout(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if c.Token() != "" {
+		t.Error("expected the client to forget its token")
+	}
+	if valid, err := c.ValidateToken(ctx, token); err != nil || valid {
+		t.Errorf("expected the revoked token to be invalid, got %v and %v", valid, err)
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"vue-api/internal/migrate"
+)
+
+// errUsage is returned for a command which was not given the arguments it needs
+var errUsage = errors.New("usage")
+
+const commandsUsage = `commands (given after any flags; with none, the api is served):
+  migrate up              apply every pending migration
+  migrate down [steps]    revert the last steps migrations (default 1)
+  migrate to <version>    migrate up or down to version
+  migrate status          list the migrations, and when they were applied
+  migrate version         print the version the database is at
+  migrate force <version> record the database as being at version, running nothing
+  user create -email e -first-name f -last-name l [-password p] [-language l] [-inactive]
+                          add a user; a password is generated and printed when left out
+  user activate <email>   let a user log in
+  user deactivate <email> stop a user logging in, and revoke their tokens
+  user reset-password [-password p] <email>
+                          set a new password, printing a generated one, and revoke the tokens
+  tokens revoke <email>   log a user out everywhere
+  tokens revoke -all      log every user out
+  seed [-seed n] [-authors n] [-genres n] [-books n] [-users n] [-password p]
+                          add a made up catalogue with covers, and users starting with
+                          admin@example.com; the same seed always gives the same data
+  export [-o file] [-with-covers]
+                          write the catalogue as JSON
+  import [-dry-run] <file>
+                          add the authors, genres and books of an exported catalogue
+  covers gc [-dry-run]    remove the covers which no book uses`
+
+// runCommand runs the command named by args, writing its output to out
+func (app *application) runCommand(ctx context.Context, args []string, out io.Writer) error {
+	switch args[0] {
+	case "migrate":
+		return app.migrateCommand(ctx, args[1:], out)
+	case "user":
+		return app.userCommand(ctx, args[1:], out)
+	case "tokens":
+		return app.tokensCommand(ctx, args[1:], out)
+	case "seed":
+		return app.seedCommand(ctx, args[1:], out)
+	case "export":
+		return app.exportCommand(ctx, args[1:], out)
+	case "import":
+		return app.importCommand(ctx, args[1:], out)
+	case "covers":
+		return app.coversCommand(ctx, args[1:], out)
+	default:
+		return fmt.Errorf("%w: unknown command %q\n%s", errUsage, args[0], commandsUsage)
+	}
+}
+
+// migrateCommand runs one of the migrate sub-commands
+func (app *application) migrateCommand(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: migrate needs a sub-command\n%s", errUsage, commandsUsage)
+	}
+
+	if app.migrator == nil {
+		return errNoDatabase
+	}
+
+	// number returns the argument after the sub-command, which must be a whole number
+	number := func(fallback int) (int, error) {
+		if len(args) < 2 {
+			if fallback < 0 {
+				return 0, fmt.Errorf("%w: migrate %s needs a version\n%s", errUsage, args[0], commandsUsage)
+			}
+			return fallback, nil
+		}
+		n, err := strconv.Atoi(args[1])
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%w: %q is not a version or number of steps", errUsage, args[1])
+		}
+		return n, nil
+	}
+
+	switch args[0] {
+	case "up":
+		run, err := app.migrator.Up(ctx)
+		printMigrations(out, "applied", run)
+		return err
+
+	case "down":
+		steps, err := number(1)
+		if err != nil {
+			return err
+		}
+		run, err := app.migrator.Down(ctx, steps)
+		printMigrations(out, "reverted", run)
+		return err
+
+	case "to":
+		version, err := number(-1)
+		if err != nil {
+			return err
+		}
+		run, err := app.migrator.To(ctx, version)
+		printMigrations(out, "ran", run)
+		return err
+
+	case "force":
+		version, err := number(-1)
+		if err != nil {
+			return err
+		}
+		if err := app.migrator.Force(ctx, version); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "database recorded as being at version %d\n", version)
+		return nil
+
+	case "version":
+		version, err := app.migrator.Version(ctx)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "%d (latest %d)\n", version, app.migrator.Latest())
+		return nil
+
+	case "status":
+		statuses, err := app.migrator.Status(ctx)
+		if err != nil {
+			return err
+		}
+
+		tw := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "VERSION\tNAME\tAPPLIED")
+		for _, s := range statuses {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = s.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\n", s.Version, s.Name, applied)
+		}
+		return tw.Flush()
+
+	default:
+		return fmt.Errorf("%w: unknown migrate sub-command %q\n%s", errUsage, args[0], commandsUsage)
+	}
+}
+
+// printMigrations lists the migrations a command ran, or says that there were none
+func printMigrations(out io.Writer, verb string, run []migrate.Migration) {
+	if len(run) == 0 {
+		fmt.Fprintln(out, "nothing to do")
+		return
+	}
+	for _, m := range run {
+		fmt.Fprintf(out, "%s %d_%s\n", verb, m.Version, m.Name)
+	}
+}
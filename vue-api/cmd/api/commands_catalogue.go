@@ -0,0 +1,489 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"vue-api/internal/data"
+	"vue-api/internal/seed"
+
+	"github.com/mozillazg/go-slugify"
+)
+
+// catalogue is the file written by the export command and read by import. Authors and genres
+// are referred to by name rather than id, so that a catalogue can be loaded into any database
+type catalogue struct {
+	Authors []string        `json:"authors"`
+	Genres  []string        `json:"genres"`
+	Books   []catalogueBook `json:"books"`
+}
+
+// catalogueBook is one book in a catalogue file
+type catalogueBook struct {
+	Title           string   `json:"title"`
+	Author          string   `json:"author"`
+	PublicationYear int      `json:"publication_year"`
+	Description     string   `json:"description"`
+	Genres          []string `json:"genres"`
+	Cover           []byte   `json:"cover,omitempty"`
+}
+
+// importResult counts what loading a catalogue did
+type importResult struct {
+	authors, genres, books, skipped, covers int
+}
+
+// exportCommand writes the catalogue as JSON. Books in the trash, and the history of books, are
+// left out
+func (app *application) exportCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := newCommandFlags("export [-o file] [-with-covers]", out)
+	output := fs.String("o", "", "file to write the catalogue to, rather than standard output")
+	withCovers := fs.Bool("with-covers", false, "include the cover of each book, base64 encoded")
+
+	if err := parseCommandFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	c, err := app.exportCatalogue(ctx, *withCovers)
+	if err != nil {
+		return err
+	}
+
+	w := out
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "\t")
+	if err := enc.Encode(c); err != nil {
+		return err
+	}
+
+	if *output != "" {
+		fmt.Fprintf(out, "exported %d author(s), %d genre(s) and %d book(s) to %s\n", len(c.Authors), len(c.Genres), len(c.Books), *output)
+	}
+
+	return nil
+}
+
+// exportCatalogue reads the authors, genres and books in the database
+func (app *application) exportCatalogue(ctx context.Context, withCovers bool) (catalogue, error) {
+	var c catalogue
+
+	authors, err := app.models.Author.All(ctx)
+	if err != nil {
+		return c, err
+	}
+	for _, a := range authors {
+		c.Authors = append(c.Authors, a.AuthorName)
+	}
+
+	genres, err := app.models.Genre.All(ctx)
+	if err != nil {
+		return c, err
+	}
+	for _, g := range genres {
+		c.Genres = append(c.Genres, g.GenreName)
+	}
+
+	books, err := app.models.Book.GetAll(ctx)
+	if err != nil {
+		return c, err
+	}
+
+	for _, b := range books {
+		book := catalogueBook{
+			Title:           b.Title,
+			Author:          b.Author.AuthorName,
+			PublicationYear: b.PublicationYear,
+			Description:     b.Description,
+			Genres:          []string{},
+		}
+		for _, g := range b.Genres {
+			book.Genres = append(book.Genres, g.GenreName)
+		}
+
+		if withCovers {
+			cover, err := app.readCover(ctx, b.Slug)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return c, err
+			}
+			if len(cover) > 0 {
+				book.Cover = cover
+			}
+		}
+
+		c.Books = append(c.Books, book)
+	}
+
+	return c, nil
+}
+
+// importCommand loads a catalogue written by export
+func (app *application) importCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := newCommandFlags("import [-dry-run] <file>", out)
+	dryRun := fs.Bool("dry-run", false, "report what would be imported, without changing anything")
+
+	if err := parseCommandFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	c, err := readCatalogue(f)
+	if err != nil {
+		return fmt.Errorf("%s: %w", fs.Arg(0), err)
+	}
+
+	result, err := app.importCatalogue(ctx, c, *dryRun)
+	if err != nil {
+		return err
+	}
+
+	verb := "imported"
+	if *dryRun {
+		verb = "would import"
+	}
+	fmt.Fprintf(out, "%s %d author(s), %d genre(s) and %d book(s) with %d cover(s); %d book(s) were there already\n",
+		verb, result.authors, result.genres, result.books, result.covers, result.skipped)
+
+	return nil
+}
+
+// readCatalogue decodes a catalogue, making sure every book has a title and an author
+func readCatalogue(r io.Reader) (catalogue, error) {
+	var c catalogue
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return c, err
+	}
+
+	for i, b := range c.Books {
+		if strings.TrimSpace(b.Title) == "" || strings.TrimSpace(b.Author) == "" {
+			return c, fmt.Errorf("book %d needs a title and an author", i+1)
+		}
+	}
+
+	return c, nil
+}
+
+// importCatalogue adds the authors, genres and books of a catalogue which are not in the database
+// yet. Authors and genres are matched by name, and books by slug
+func (app *application) importCatalogue(ctx context.Context, c catalogue, dryRun bool) (importResult, error) {
+	var result importResult
+
+	authors, err := app.models.Author.All(ctx)
+	if err != nil {
+		return result, err
+	}
+	authorIDs := make(map[string]int)
+	for _, a := range authors {
+		authorIDs[a.AuthorName] = a.ID
+	}
+
+	genres, err := app.models.Genre.All(ctx)
+	if err != nil {
+		return result, err
+	}
+	genreIDs := make(map[string]int)
+	for _, g := range genres {
+		genreIDs[g.GenreName] = g.ID
+	}
+
+	books, err := app.models.Book.GetAll(ctx)
+	if err != nil {
+		return result, err
+	}
+	slugs := make(map[string]bool)
+	for _, b := range books {
+		slugs[b.Slug] = true
+	}
+
+	// authors and genres are named by books as well as listed, so add the names either way
+	names := func(listed []string, named func(catalogueBook) []string) []string {
+		all := append([]string{}, listed...)
+		for _, b := range c.Books {
+			all = append(all, named(b)...)
+		}
+		return all
+	}
+
+	for _, name := range names(c.Authors, func(b catalogueBook) []string { return []string{b.Author} }) {
+		if _, ok := authorIDs[name]; ok {
+			continue
+		}
+		result.authors++
+		if dryRun {
+			authorIDs[name] = 0
+			continue
+		}
+
+		id, err := app.models.Author.Insert(ctx, data.Author{AuthorName: name})
+		if err != nil {
+			return result, err
+		}
+		authorIDs[name] = id
+		app.auditCommand(ctx, "author.create", "author", id, nil, data.Author{ID: id, AuthorName: name})
+	}
+
+	for _, name := range names(c.Genres, func(b catalogueBook) []string { return b.Genres }) {
+		if _, ok := genreIDs[name]; ok {
+			continue
+		}
+		result.genres++
+		if dryRun {
+			genreIDs[name] = 0
+			continue
+		}
+
+		id, err := app.models.Genre.Insert(ctx, data.Genre{GenreName: name})
+		if err != nil {
+			return result, err
+		}
+		genreIDs[name] = id
+		app.auditCommand(ctx, "genre.create", "genre", id, nil, data.Genre{ID: id, GenreName: name})
+	}
+
+	for _, b := range c.Books {
+		bookSlug := slugify.Slugify(b.Title)
+		if slugs[bookSlug] {
+			result.skipped++
+			continue
+		}
+		slugs[bookSlug] = true
+
+		result.books++
+		if len(b.Cover) > 0 {
+			result.covers++
+		}
+		if dryRun {
+			continue
+		}
+
+		book := data.Book{
+			Title:           b.Title,
+			AuthorID:        authorIDs[b.Author],
+			PublicationYear: b.PublicationYear,
+			Description:     b.Description,
+		}
+		for _, g := range b.Genres {
+			book.GenreIDs = append(book.GenreIDs, genreIDs[g])
+		}
+
+		id, err := app.models.Book.Insert(ctx, book)
+		if err != nil {
+			return result, fmt.Errorf("importing %q: %w", b.Title, err)
+		}
+
+		if len(b.Cover) > 0 {
+			if err := app.writeCover(ctx, bookSlug, b.Cover); err != nil {
+				return result, err
+			}
+		}
+
+		if _, err := app.models.BookRevision.Record(ctx, id, 0); err != nil {
+			return result, err
+		}
+
+		created, err := app.models.Book.GetOneById(ctx, id)
+		if err != nil {
+			return result, err
+		}
+		app.auditCommand(ctx, "book.create", "book", id, nil, created)
+	}
+
+	return result, nil
+}
+
+// seedCommand fills the database with made up data: a catalogue with covers, and users who all
+// share one password, which is printed. The same -seed always makes up the same data, and what
+// is there already is left alone, so it is safe to run more than once
+func (app *application) seedCommand(ctx context.Context, args []string, out io.Writer) error {
+	defaults := seed.DefaultOptions()
+
+	var opts seed.Options
+	fs := newCommandFlags("seed [-seed n] [-authors n] [-genres n] [-books n] [-users n] [-password p]", out)
+	fs.Int64Var(&opts.Seed, "seed", defaults.Seed, "seed the data is made up from")
+	fs.IntVar(&opts.Authors, "authors", defaults.Authors, fmt.Sprintf("number of authors, at most %d", seed.MaxAuthors))
+	fs.IntVar(&opts.Genres, "genres", defaults.Genres, fmt.Sprintf("number of genres, at most %d", seed.MaxGenres))
+	fs.IntVar(&opts.Books, "books", defaults.Books, "number of books, each with a cover")
+	fs.IntVar(&opts.Users, "users", defaults.Users, "number of users, the first of which is "+seed.AdminEmail)
+	password := fs.String("password", "", "password of the users added; one is generated when left out")
+
+	if err := parseCommandFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		*password = generatePassword()
+	}
+	if err := app.checkPassword(*password); err != nil {
+		return err
+	}
+
+	result, added, err := app.seed(ctx, opts, *password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(out, "added %d author(s), %d genre(s) and %d book(s); %d book(s) were there already\n",
+		result.authors, result.genres, result.books, result.skipped)
+	fmt.Fprintf(out, "added %d user(s)\n", added)
+	if added > 0 && generated {
+		fmt.Fprintf(out, "password: %s\n", *password)
+	}
+
+	return nil
+}
+
+// seed makes up the data asked for by opts and adds whatever is not there yet, giving every
+// user added the same password. It returns what was imported and the number of users added
+func (app *application) seed(ctx context.Context, opts seed.Options, password string) (importResult, int, error) {
+	d, err := seed.Generate(opts)
+	if err != nil {
+		return importResult{}, 0, fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	result, err := app.importCatalogue(ctx, seedCatalogue(d), false)
+	if err != nil {
+		return result, 0, err
+	}
+
+	added := 0
+	for _, u := range d.Users {
+		_, err := app.models.User.GetByEmail(ctx, u.Email)
+		if err == nil {
+			continue
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return result, added, err
+		}
+
+		user := data.User{Email: u.Email, FirstName: u.FirstName, LastName: u.LastName, Password: password, Language: u.Language}
+		if u.Active {
+			user.Active = 1
+		}
+
+		id, err := app.models.User.Insert(ctx, user)
+		if err != nil {
+			return result, added, err
+		}
+
+		created, err := app.models.User.GetOne(ctx, id)
+		if err != nil {
+			return result, added, err
+		}
+		app.auditCommand(ctx, "user.create", "user", id, nil, userSnapshot(created))
+		added++
+	}
+
+	return result, added, nil
+}
+
+// seedCatalogue turns made up data into a catalogue, which can be imported
+func seedCatalogue(d *seed.Data) catalogue {
+	c := catalogue{Authors: d.Authors, Genres: d.Genres}
+
+	for _, b := range d.Books {
+		c.Books = append(c.Books, catalogueBook{
+			Title:           b.Title,
+			Author:          b.Author,
+			PublicationYear: b.PublicationYear,
+			Description:     b.Description,
+			Genres:          b.Genres,
+			Cover:           b.Cover,
+		})
+	}
+
+	return c
+}
+
+// coversCommand runs one of the covers sub-commands
+func (app *application) coversCommand(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "gc" {
+		return fmt.Errorf("%w: covers needs the gc sub-command\n%s", errUsage, commandsUsage)
+	}
+
+	fs := newCommandFlags("covers gc [-dry-run]", out)
+	dryRun := fs.Bool("dry-run", false, "list the covers which would be removed, without removing them")
+
+	if err := parseCommandFlags(fs, args[1:], 0); err != nil {
+		return err
+	}
+
+	// covers of books in the trash are kept, as the books may yet be restored
+	live, err := app.models.Book.GetAll(ctx)
+	if err != nil {
+		return err
+	}
+	trashed, err := app.models.Book.GetAllDeleted(ctx)
+	if err != nil {
+		return err
+	}
+
+	used := make(map[string]bool)
+	for _, b := range append(live, trashed...) {
+		used[b.Slug+".jpg"] = true
+	}
+
+	unused, err := unusedCovers(filepath.Join(app.config.staticPath, "covers"), used)
+	if err != nil {
+		return err
+	}
+
+	for _, name := range unused {
+		if *dryRun {
+			fmt.Fprintf(out, "would remove %s\n", name)
+			continue
+		}
+		if err := app.removeCover(ctx, name); err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "removed %s\n", name)
+	}
+
+	if len(unused) == 0 {
+		fmt.Fprintln(out, "nothing to do")
+	}
+
+	return nil
+}
+
+// unusedCovers returns the names of the files in dir which are not in used, sorted. Hidden files
+// and directories are left out
+func unusedCovers(dir string, used map[string]bool) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var unused []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || used[entry.Name()] {
+			continue
+		}
+		unused = append(unused, entry.Name())
+	}
+	sort.Strings(unused)
+
+	return unused, nil
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"vue-api/internal/data"
+	"vue-api/internal/migrate"
+	"vue-api/internal/seed"
+
+	"github.com/DATA-DOG/go-sqlmock"
+)
+
+// newMigratorTestApp returns an application whose migrator and models use a database of its own, so that
+// expectations do not get mixed up with those of the handler tests
+func newMigratorTestApp(t *testing.T) (*application, sqlmock.Sqlmock) {
+	t.Helper()
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	return &application{migrator: migrate.New(db), models: data.New(db), logger: discardLogger()}, mock
+}
+
+func Test_runCommand_usage(t *testing.T) {
+	app, _ := newMigratorTestApp(t)
+
+	for _, args := range [][]string{
+		{"frobnicate"},
+		{"migrate"},
+		{"migrate", "sideways"},
+		{"migrate", "to"},
+		{"migrate", "down", "-1"},
+		{"migrate", "force", "latest"},
+		{"user"},
+		{"user", "promote", "a@example.com"},
+		{"user", "deactivate"},
+		{"user", "create", "-first-name", "A", "-last-name", "B"},
+		{"user", "reset-password", "-password", "short", "a@example.com"},
+		{"tokens", "revoke"},
+		{"tokens", "revoke", "-all", "a@example.com"},
+		{"export", "extra"},
+		{"import"},
+		{"covers"},
+		{"covers", "gc", "-force"},
+		{"seed", "now"},
+		{"seed", "-books", "1", "-authors", "0"},
+		{"seed", "-password", "short"},
+	} {
+		err := app.runCommand(context.Background(), args, &bytes.Buffer{})
+		if !errors.Is(err, errUsage) {
+			t.Errorf("%v: expected a usage error but got %v", args, err)
+		}
+	}
+}
+
+func Test_runCommand_migrateVersion(t *testing.T) {
+	app, mock := newMigratorTestApp(t)
+
+	mock.ExpectQuery("select coalesce\\(max\\(version\\), 0\\) from schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(2))
+
+	var out bytes.Buffer
+	if err := app.runCommand(context.Background(), []string{"migrate", "version"}, &out); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := fmt.Sprintf("2 (latest %d)\n", app.migrator.Latest()); out.String() != expected {
+		t.Errorf("expected %q but got %q", expected, out.String())
+	}
+}
+
+func Test_checkMigrations(t *testing.T) {
+	app, mock := newMigratorTestApp(t)
+
+	mock.ExpectQuery("select coalesce").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(app.migrator.Latest()))
+	if err := app.checkMigrations(context.Background()); err != nil {
+		t.Errorf("expected an up to date schema to pass, got %v", err)
+	}
+
+	mock.ExpectQuery("select coalesce").WillReturnRows(sqlmock.NewRows([]string{"version"}).AddRow(1))
+	if err := app.checkMigrations(context.Background()); err == nil {
+		t.Error("expected a schema with pending migrations to fail")
+	}
+}
+
+func Test_describeValidation(t *testing.T) {
+	app, _ := newMigratorTestApp(t)
+
+	err := app.runCommand(context.Background(), []string{"user", "create", "-password", "secretpassword"}, &bytes.Buffer{})
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+
+	for _, field := range []string{"email", "first_name", "last_name"} {
+		if !strings.Contains(err.Error(), field) {
+			t.Errorf("expected the error to mention %s, got %q", field, err)
+		}
+	}
+}
+
+func Test_generatePassword(t *testing.T) {
+	a, b := generatePassword(), generatePassword()
+
+	if len(a) != generatedPasswordLength {
+		t.Errorf("expected %d characters but got %q", generatedPasswordLength, a)
+	}
+
+	if a == b {
+		t.Error("expected two passwords to differ")
+	}
+
+	if strings.Trim(a, passwordAlphabet) != "" {
+		t.Errorf("expected only characters from the alphabet, got %q", a)
+	}
+}
+
+func Test_unusedCovers(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"kept.jpg", "orphan.jpg", "another.jpg", ".readyz-123"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("x"), 0666); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "thumbs"), 0777); err != nil {
+		t.Fatal(err)
+	}
+
+	unused, err := unusedCovers(dir, map[string]bool{"kept.jpg": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(unused, ",") != "another.jpg,orphan.jpg" {
+		t.Errorf("expected another.jpg and orphan.jpg, got %v", unused)
+	}
+}
+
+func Test_readCatalogue(t *testing.T) {
+	d, err := seed.Generate(seed.Options{Seed: 1, Authors: 2, Genres: 3, Books: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	exported := seedCatalogue(d)
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(exported); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := readCatalogue(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(c, exported) {
+		t.Errorf("expected the catalogue to survive a round trip, got %+v", c)
+	}
+
+	var tests = []struct {
+		name     string
+		json     string
+		expected string
+	}{
+		{"unknown field", `{"books": [], "shelves": []}`, "unknown field"},
+		{"no author", `{"books": [{"title": "Untitled"}]}`, "needs a title and an author"},
+		{"bad cover", `{"books": [{"title": "A", "author": "B", "cover": "!!"}]}`, "illegal base64"},
+	}
+
+	for _, e := range tests {
+		_, err := readCatalogue(strings.NewReader(e.json))
+		if err == nil || !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected an error containing %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
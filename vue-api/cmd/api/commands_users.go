@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+	"vue-api/internal/data"
+	"vue-api/internal/i18n"
+)
+
+// generatedPasswordLength is the length of the passwords made up by the user commands
+const generatedPasswordLength = 16
+
+// passwordAlphabet leaves out characters which are easily mistaken for each other
+const passwordAlphabet = "abcdefghijkmnpqrstuvwxyzABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// userCommand runs one of the user sub-commands
+func (app *application) userCommand(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%w: user needs a sub-command\n%s", errUsage, commandsUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		return app.createUserCommand(ctx, args[1:], out)
+	case "activate":
+		return app.setUserActiveCommand(ctx, args[1:], out, 1)
+	case "deactivate":
+		return app.setUserActiveCommand(ctx, args[1:], out, 0)
+	case "reset-password":
+		return app.resetPasswordCommand(ctx, args[1:], out)
+	default:
+		return fmt.Errorf("%w: unknown user sub-command %q\n%s", errUsage, args[0], commandsUsage)
+	}
+}
+
+// createUserCommand adds a user. Without -password, one is made up and printed
+func (app *application) createUserCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := newCommandFlags("user create", out)
+	email := fs.String("email", "", "email address, which the user logs in with")
+	firstName := fs.String("first-name", "", "first name")
+	lastName := fs.String("last-name", "", "last name")
+	password := fs.String("password", "", "password; one is generated when left out")
+	language := fs.String("language", "", "preferred language of messages: "+strings.Join(i18n.Languages(), " or "))
+	inactive := fs.Bool("inactive", false, "create the user unable to log in")
+
+	if err := parseCommandFlags(fs, args, 0); err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		*password = generatePassword()
+	}
+
+	user := data.User{
+		Email:     *email,
+		FirstName: *firstName,
+		LastName:  *lastName,
+		Password:  *password,
+		Active:    1,
+		Language:  *language,
+	}
+	if *inactive {
+		user.Active = 0
+	}
+
+	if err := app.validateUserIn(ctx, i18n.NewPrinter(i18n.English), user); err != nil {
+		return describeValidation(err)
+	}
+
+	id, err := app.models.User.Insert(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	created, err := app.models.User.GetOne(ctx, id)
+	if err != nil {
+		return err
+	}
+	app.auditCommand(ctx, "user.create", "user", id, nil, userSnapshot(created))
+
+	fmt.Fprintf(out, "created user %d, %s\n", id, created.Email)
+	if generated {
+		fmt.Fprintf(out, "password: %s\n", *password)
+	}
+
+	return nil
+}
+
+// setUserActiveCommand lets a user log in, or stops them; deactivated users are logged out
+func (app *application) setUserActiveCommand(ctx context.Context, args []string, out io.Writer, active int) error {
+	name := "user activate"
+	if active == 0 {
+		name = "user deactivate"
+	}
+
+	fs := newCommandFlags(name+" <email>", out)
+	if err := parseCommandFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	user, err := app.models.User.GetByEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("no user with email %s: %w", fs.Arg(0), err)
+	}
+	before := userSnapshot(user)
+
+	user.Version = 0
+	user.Active = active
+	if err := app.models.User.Update(ctx, user); err != nil {
+		return err
+	}
+
+	app.auditCommand(ctx, "user.update", "user", user.ID, before, userSnapshot(user))
+
+	if active == 0 {
+		if err := app.models.Token.DeleteTokensForUser(ctx, user.ID); err != nil {
+			return err
+		}
+		app.auditCommand(ctx, "user.logout", "user", user.ID, nil, nil)
+
+		fmt.Fprintf(out, "deactivated %s and revoked their tokens\n", user.Email)
+		return nil
+	}
+
+	fmt.Fprintf(out, "activated %s\n", user.Email)
+	return nil
+}
+
+// resetPasswordCommand sets a new password for a user, and logs them out everywhere. Without
+// -password, one is made up and printed
+func (app *application) resetPasswordCommand(ctx context.Context, args []string, out io.Writer) error {
+	fs := newCommandFlags("user reset-password <email>", out)
+	password := fs.String("password", "", "the new password; one is generated when left out")
+
+	if err := parseCommandFlags(fs, args, 1); err != nil {
+		return err
+	}
+
+	generated := *password == ""
+	if generated {
+		*password = generatePassword()
+	}
+
+	if err := app.checkPassword(*password); err != nil {
+		return err
+	}
+
+	user, err := app.models.User.GetByEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("no user with email %s: %w", fs.Arg(0), err)
+	}
+
+	if err := app.models.User.ResetPassword(ctx, user.ID, *password); err != nil {
+		return err
+	}
+
+	if err := app.models.Token.DeleteTokensForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	app.auditCommand(ctx, "user.password_reset", "user", user.ID, nil, nil)
+
+	fmt.Fprintf(out, "reset the password of %s and revoked their tokens\n", user.Email)
+	if generated {
+		fmt.Fprintf(out, "password: %s\n", *password)
+	}
+
+	return nil
+}
+
+// tokensCommand runs one of the tokens sub-commands
+func (app *application) tokensCommand(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) == 0 || args[0] != "revoke" {
+		return fmt.Errorf("%w: tokens needs the revoke sub-command\n%s", errUsage, commandsUsage)
+	}
+
+	fs := newCommandFlags("tokens revoke [-all | <email>]", out)
+	all := fs.Bool("all", false, "revoke the tokens of every user")
+
+	if err := fs.Parse(args[1:]); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	// revoking everything must be asked for explicitly, rather than by forgetting the email
+	if *all == (fs.NArg() == 1) || fs.NArg() > 1 {
+		return fmt.Errorf("%w: tokens revoke needs either -all or one email address", errUsage)
+	}
+
+	if *all {
+		removed, err := app.models.Token.DeleteAll(ctx)
+		if err != nil {
+			return err
+		}
+		app.auditCommand(ctx, "token.revoke_all", "token", 0, nil, nil)
+		fmt.Fprintf(out, "revoked %d token(s); every user has been logged out\n", removed)
+		return nil
+	}
+
+	user, err := app.models.User.GetByEmail(ctx, fs.Arg(0))
+	if err != nil {
+		return fmt.Errorf("no user with email %s: %w", fs.Arg(0), err)
+	}
+
+	if err := app.models.Token.DeleteTokensForUser(ctx, user.ID); err != nil {
+		return err
+	}
+	app.auditCommand(ctx, "user.logout", "user", user.ID, nil, nil)
+
+	fmt.Fprintf(out, "revoked the tokens of %s\n", user.Email)
+	return nil
+}
+
+// checkPassword makes sure a password given on the command line follows the same rules as one
+// sent to the api
+func (app *application) checkPassword(password string) error {
+	rules := userRules{Email: "x@example.com", FirstName: "x", LastName: "x", Password: password}
+	if err := app.validateIn(i18n.NewPrinter(i18n.English), rules); err != nil {
+		return describeValidation(err)
+	}
+
+	return nil
+}
+
+// newCommandFlags returns the flag set for a command, which reports errors rather than exiting
+func newCommandFlags(name string, out io.Writer) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(out)
+	fs.Usage = func() {
+		fmt.Fprintf(out, "usage: vueapi %s\n", name)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseCommandFlags parses args, which must leave exactly positional arguments once the flags
+// have been read
+func parseCommandFlags(fs *flag.FlagSet, args []string, positional int) error {
+	if err := fs.Parse(args); err != nil {
+		return fmt.Errorf("%w: %v", errUsage, err)
+	}
+
+	if fs.NArg() != positional {
+		return fmt.Errorf("%w: vueapi %s", errUsage, fs.Name())
+	}
+
+	return nil
+}
+
+// describeValidation turns a validation error into one which lists the problem with each field
+func describeValidation(err error) error {
+	var invalid *validationError
+	if !errors.As(err, &invalid) {
+		return err
+	}
+
+	fields := make([]string, 0, len(invalid.errors))
+	for field := range invalid.errors {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	problems := make([]string, 0, len(fields))
+	for _, field := range fields {
+		problems = append(problems, fmt.Sprintf("%s %s", field, strings.Join(invalid.errors[field], ", ")))
+	}
+
+	return fmt.Errorf("%w: %s", errUsage, strings.Join(problems, "; "))
+}
+
+// generatePassword makes up a random password
+func generatePassword() string {
+	max := big.NewInt(int64(len(passwordAlphabet)))
+
+	b := make([]byte, generatedPasswordLength)
+	for i := range b {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			panic(err)
+		}
+		b[i] = passwordAlphabet[n.Int64()]
+	}
+
+	return string(b)
+}
@@ -0,0 +1,462 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vue-api/internal/i18n"
+
+	"gopkg.in/yaml.v3"
+)
+
+// config is the type for all application configuration. It is built by loadConfig, from the
+// defaults, an optional YAML config file, environment variables and command line flags
+type config struct {
+	port   int
+	env    string
+	server struct {
+		readTimeout       time.Duration
+		readHeaderTimeout time.Duration
+		writeTimeout      time.Duration
+		idleTimeout       time.Duration
+		shutdownTimeout   time.Duration
+		drainDelay        time.Duration
+	}
+	tls struct {
+		certFile       string
+		keyFile        string
+		redirectPort   int
+		hstsMaxAge     time.Duration
+		reloadInterval time.Duration
+	}
+	db struct {
+		driver          string
+		dsn             string
+		maxOpenConns    int
+		maxIdleConns    int
+		connMaxLifetime time.Duration
+		connMaxIdleTime time.Duration
+		timeout         time.Duration
+		autoMigrate     bool
+	}
+	cors struct {
+		allowedOrigins []string
+	}
+	tracing struct {
+		otlpEndpoint string
+	}
+	tokenTTL        time.Duration
+	staticPath      string
+	trashRetention  time.Duration
+	defaultLanguage string
+	logLevel        slog.Level
+}
+
+// defaultConfig returns the configuration used for anything which is not set elsewhere
+func defaultConfig() config {
+	var cfg config
+	cfg.port = 8082
+	cfg.env = "production"
+	cfg.server.readTimeout = 10 * time.Second
+	cfg.server.readHeaderTimeout = 5 * time.Second
+	cfg.server.writeTimeout = 30 * time.Second
+	cfg.server.idleTimeout = 2 * time.Minute
+	cfg.server.shutdownTimeout = 30 * time.Second
+	cfg.tls.hstsMaxAge = 180 * 24 * time.Hour
+	cfg.tls.reloadInterval = time.Minute
+	cfg.db.driver = "postgres"
+	cfg.db.maxOpenConns = 6
+	cfg.db.maxIdleConns = 6
+	cfg.db.connMaxLifetime = 6 * time.Minute
+	cfg.db.timeout = 3 * time.Second
+	cfg.cors.allowedOrigins = []string{"https://*", "http://*"}
+	cfg.tokenTTL = 24 * time.Hour
+	cfg.staticPath = "./static/"
+	cfg.trashRetention = 30 * 24 * time.Hour
+	cfg.defaultLanguage = i18n.Fallback
+	cfg.logLevel = slog.LevelInfo
+	return cfg
+}
+
+// setting is one configuration value, and the name it goes by in each place it can be set
+type setting struct {
+	key    string // in the config file, with nested keys separated by dots
+	flag   string
+	env    string
+	usage  string
+	secret bool
+	value  flag.Value
+}
+
+// settings lists everything which can be configured, bound to the fields of c
+func (c *config) settings() []setting {
+	return []setting{
+		{key: "port", flag: "port", env: "PORT", usage: "port to listen on", value: intValue{&c.port}},
+		{key: "env", flag: "env", env: "ENV", usage: "environment: development, or production when not set; any other value stops the api at start up", value: stringValue{&c.env}},
+		{key: "server.read_timeout", flag: "read-timeout", env: "READ_TIMEOUT", usage: "maximum time to read a whole request, including the body", value: durationValue{&c.server.readTimeout}},
+		{key: "server.read_header_timeout", flag: "read-header-timeout", env: "READ_HEADER_TIMEOUT", usage: "maximum time to read the headers of a request", value: durationValue{&c.server.readHeaderTimeout}},
+		{key: "server.write_timeout", flag: "write-timeout", env: "WRITE_TIMEOUT", usage: "maximum time to write a response", value: durationValue{&c.server.writeTimeout}},
+		{key: "server.idle_timeout", flag: "idle-timeout", env: "IDLE_TIMEOUT", usage: "how long an idle keep-alive connection is kept open", value: durationValue{&c.server.idleTimeout}},
+		{key: "server.shutdown_timeout", flag: "shutdown-timeout", env: "SHUTDOWN_TIMEOUT", usage: "how long to wait for requests in flight to finish when shutting down", value: durationValue{&c.server.shutdownTimeout}},
+		{key: "server.drain_delay", flag: "drain-delay", env: "DRAIN_DELAY", usage: "how long /readyz fails before the server stops accepting connections when shutting down", value: durationValue{&c.server.drainDelay}},
+		{key: "tls.cert_file", flag: "tls-cert-file", env: "TLS_CERT_FILE", usage: "PEM certificate file; serve https when set, together with tls-key-file", value: stringValue{&c.tls.certFile}},
+		{key: "tls.key_file", flag: "tls-key-file", env: "TLS_KEY_FILE", usage: "PEM private key file for the certificate", value: stringValue{&c.tls.keyFile}},
+		{key: "tls.redirect_port", flag: "tls-redirect-port", env: "TLS_REDIRECT_PORT", usage: "port on which plain http requests are redirected to https, 0 for none", value: intValue{&c.tls.redirectPort}},
+		{key: "tls.hsts_max_age", flag: "tls-hsts-max-age", env: "TLS_HSTS_MAX_AGE", usage: "max-age of the Strict-Transport-Security header, 0 to leave it out", value: durationValue{&c.tls.hstsMaxAge}},
+		{key: "tls.reload_interval", flag: "tls-reload-interval", env: "TLS_RELOAD_INTERVAL", usage: "how often the certificate files are checked for changes", value: durationValue{&c.tls.reloadInterval}},
+		{key: "db.driver", flag: "db-driver", env: "DB_DRIVER", usage: "where data is stored: postgres, sqlite, or memory to keep it in memory, seeded at start up", value: stringValue{&c.db.driver}},
+		{key: "db.dsn", flag: "dsn", env: "DSN", usage: "postgres connection string, or the path of the sqlite database file", secret: true, value: stringValue{&c.db.dsn}},
+		{key: "db.max_open_conns", flag: "db-max-open-conns", env: "DB_MAX_OPEN_CONNS", usage: "maximum number of open database connections", value: intValue{&c.db.maxOpenConns}},
+		{key: "db.max_idle_conns", flag: "db-max-idle-conns", env: "DB_MAX_IDLE_CONNS", usage: "maximum number of idle database connections", value: intValue{&c.db.maxIdleConns}},
+		{key: "db.conn_max_lifetime", flag: "db-conn-max-lifetime", env: "DB_CONN_MAX_LIFETIME", usage: "maximum time a database connection is reused, 0 for no limit", value: durationValue{&c.db.connMaxLifetime}},
+		{key: "db.conn_max_idle_time", flag: "db-conn-max-idle-time", env: "DB_CONN_MAX_IDLE_TIME", usage: "maximum time a database connection is kept idle, 0 for no limit", value: durationValue{&c.db.connMaxIdleTime}},
+		{key: "db.timeout", flag: "db-timeout", env: "DB_TIMEOUT", usage: "maximum time a database query may take", value: durationValue{&c.db.timeout}},
+		{key: "db.auto_migrate", flag: "auto-migrate", env: "AUTO_MIGRATE", usage: "apply pending database migrations when the api starts", value: boolValue{&c.db.autoMigrate}},
+		{key: "cors.allowed_origins", flag: "cors-allowed-origins", env: "CORS_ALLOWED_ORIGINS", usage: "comma separated origins allowed to call the api", value: listValue{&c.cors.allowedOrigins}},
+		{key: "tracing.otlp_endpoint", flag: "otlp-endpoint", env: "OTLP_ENDPOINT", usage: "URL of the OTLP/HTTP collector traces are exported to, such as http://localhost:4318; none are exported when empty", value: stringValue{&c.tracing.otlpEndpoint}},
+		{key: "token_ttl", flag: "token-ttl", env: "TOKEN_TTL", usage: "how long an authentication token stays valid", value: durationValue{&c.tokenTTL}},
+		{key: "static_path", flag: "static-path", env: "STATIC_PATH", usage: "directory holding static files, such as book covers", value: stringValue{&c.staticPath}},
+		{key: "trash_retention", flag: "trash-retention", env: "TRASH_RETENTION", usage: "how long deleted books and users are kept before being purged", value: durationValue{&c.trashRetention}},
+		{key: "default_language", flag: "default-language", env: "DEFAULT_LANGUAGE", usage: "language of messages for clients who ask for none that is supported", value: stringValue{&c.defaultLanguage}},
+		{key: "log_level", flag: "log-level", env: "LOG_LEVEL", usage: "least severe level which is logged: debug, info, warn or error", value: levelValue{&c.logLevel}},
+	}
+}
+
+// loadConfig builds the configuration from, in increasing order of precedence: the defaults,
+// the config file named by -config (or CONFIG_FILE), environment variables and command line
+// flags. args does not include the program name; the arguments left once the flags have been
+// parsed, which name a command to run instead of the server, are returned
+func loadConfig(args []string, getenv func(string) string) (config, []string, error) {
+	cfg := defaultConfig()
+	settings := cfg.settings()
+
+	fs := flag.NewFlagSet("vueapi", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: vueapi [flags] [command]\n\nflags:\n")
+		fs.PrintDefaults()
+		fmt.Fprintf(fs.Output(), "\n%s\n", commandsUsage)
+	}
+	configFile := fs.String("config", getenv("CONFIG_FILE"), "path to a YAML config file (env CONFIG_FILE)")
+
+	// flags are only collected here, and applied once the file and environment have been read
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		name := s.flag
+		collect := func(value string) error {
+			flagValues[name] = value
+			return nil
+		}
+
+		usage := fmt.Sprintf("%s (env %s)", s.usage, s.env)
+		if b, ok := s.value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			// so that -auto-migrate means -auto-migrate=true
+			fs.BoolFunc(name, usage, collect)
+		} else {
+			fs.Func(name, usage, collect)
+		}
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return cfg, nil, err
+	}
+
+	if *configFile != "" {
+		if err := loadConfigFile(*configFile, settings); err != nil {
+			return cfg, nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if value := getenv(s.env); value != "" {
+			if err := s.value.Set(value); err != nil {
+				return cfg, nil, fmt.Errorf("%s: %w", s.env, err)
+			}
+		}
+	}
+
+	for _, s := range settings {
+		if value, ok := flagValues[s.flag]; ok {
+			if err := s.value.Set(value); err != nil {
+				return cfg, nil, fmt.Errorf("-%s: %w", s.flag, err)
+			}
+		}
+	}
+
+	return cfg, fs.Args(), cfg.validate()
+}
+
+// loadConfigFile applies the settings found in a YAML config file. Keys which do not name a
+// setting are rejected, so that typos do not go unnoticed
+func loadConfigFile(path string, settings []setting) error {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var document map[string]interface{}
+	if err := yaml.Unmarshal(contents, &document); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	if err := flattenConfig("", document, values); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	byKey := make(map[string]setting)
+	for _, s := range settings {
+		byKey[s.key] = s
+	}
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			return fmt.Errorf("%s: unknown setting %s", path, key)
+		}
+
+		if err := s.value.Set(values[key]); err != nil {
+			return fmt.Errorf("%s: %s: %w", path, key, err)
+		}
+	}
+
+	return nil
+}
+
+// flattenConfig turns a decoded YAML document into a map of dotted keys to values. Lists are
+// joined with commas, the same way they are given in flags and environment variables
+func flattenConfig(prefix string, node interface{}, values map[string]string) error {
+	switch n := node.(type) {
+	case map[string]interface{}:
+		for key, child := range n {
+			if prefix != "" {
+				key = prefix + "." + key
+			}
+			if err := flattenConfig(key, child, values); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		items := make([]string, 0, len(n))
+		for _, item := range n {
+			switch item.(type) {
+			case map[string]interface{}, []interface{}:
+				return fmt.Errorf("%s must be a list of values", prefix)
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		values[prefix] = strings.Join(items, ",")
+	case nil:
+		// an empty key leaves the setting as it is
+	default:
+		values[prefix] = fmt.Sprint(n)
+	}
+
+	return nil
+}
+
+// validate checks that the configuration makes sense, and returns every problem found
+func (c config) validate() error {
+	var problems []error
+
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Errorf(format, args...))
+		}
+	}
+
+	check(c.port >= 1 && c.port <= 65535, "port must be between 1 and 65535")
+	check(c.env == "development" || c.env == "production", "env must be development or production")
+	check(c.server.readTimeout > 0, "server.read_timeout must be positive")
+	check(c.server.readHeaderTimeout > 0, "server.read_header_timeout must be positive")
+	check(c.server.writeTimeout > 0, "server.write_timeout must be positive")
+	check(c.server.idleTimeout > 0, "server.idle_timeout must be positive")
+	check(c.server.shutdownTimeout > 0, "server.shutdown_timeout must be positive")
+	check(c.server.drainDelay >= 0, "server.drain_delay must not be negative")
+	check((c.tls.certFile == "") == (c.tls.keyFile == ""), "tls.cert_file and tls.key_file must be set together")
+	check(c.tls.redirectPort == 0 || c.tlsEnabled(), "tls.redirect_port needs tls.cert_file and tls.key_file")
+	check(c.tls.redirectPort >= 0 && c.tls.redirectPort <= 65535 && c.tls.redirectPort != c.port, "tls.redirect_port must be between 0 and 65535, and differ from port")
+	check(c.tls.hstsMaxAge >= 0, "tls.hsts_max_age must not be negative")
+	check(c.tls.reloadInterval > 0, "tls.reload_interval must be positive")
+	check(c.db.driver == "postgres" || c.db.driver == "sqlite" || c.db.driver == "memory", "db.driver must be postgres, sqlite or memory")
+	check(c.db.dsn != "" || c.db.driver == "memory", "db.dsn must be set")
+	check(c.db.maxOpenConns >= 1, "db.max_open_conns must be at least 1")
+	check(c.db.maxIdleConns >= 0 && c.db.maxIdleConns <= c.db.maxOpenConns, "db.max_idle_conns must be between 0 and db.max_open_conns")
+	check(c.db.connMaxLifetime >= 0, "db.conn_max_lifetime must not be negative")
+	check(c.db.connMaxIdleTime >= 0, "db.conn_max_idle_time must not be negative")
+	check(c.db.timeout > 0, "db.timeout must be positive")
+	check(len(c.cors.allowedOrigins) > 0, "cors.allowed_origins must list at least one origin")
+	check(c.tracing.otlpEndpoint == "" || isHTTPURL(c.tracing.otlpEndpoint), "tracing.otlp_endpoint must be an http or https URL")
+	check(c.tokenTTL > 0, "token_ttl must be positive")
+	check(c.staticPath != "", "static_path must be set")
+	check(c.trashRetention > 0, "trash_retention must be positive")
+	check(i18n.Supported(c.defaultLanguage), "default_language must be one of %s", strings.Join(i18n.Languages(), ", "))
+
+	return errors.Join(problems...)
+}
+
+// tlsEnabled reports whether the api is served over https
+func (c config) tlsEnabled() bool {
+	return c.tls.certFile != "" && c.tls.keyFile != ""
+}
+
+// describe returns the effective configuration, one setting per line, with secrets redacted
+func (c *config) describe() []string {
+	var lines []string
+	for _, s := range c.settings() {
+		value := s.value.String()
+		if s.secret {
+			value = redact(value)
+		}
+		lines = append(lines, fmt.Sprintf("%s = %s", s.key, value))
+	}
+	return lines
+}
+
+// isHTTPURL reports whether s is an absolute http or https URL
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+var dsnPasswordRX = regexp.MustCompile(`(password=)('[^']*'|\S+)`)
+
+// redact hides the password in a connection string, which may be a URL or a list of key=value
+// pairs. Anything else which is not empty is hidden completely
+func redact(value string) string {
+	if value == "" {
+		return value
+	}
+
+	if u, err := url.Parse(value); err == nil && u.Scheme != "" {
+		if _, hasPassword := u.User.Password(); hasPassword {
+			u.User = url.UserPassword(u.User.Username(), "REDACTED")
+		}
+		return u.String()
+	}
+
+	if strings.Contains(value, "=") {
+		return dsnPasswordRX.ReplaceAllString(value, "${1}REDACTED")
+	}
+
+	return "REDACTED"
+}
+
+type intValue struct{ p *int }
+
+func (v intValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strconv.Itoa(*v.p)
+}
+
+func (v intValue) Set(s string) error {
+	n, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("%q is not a whole number", s)
+	}
+	*v.p = n
+	return nil
+}
+
+type boolValue struct{ p *bool }
+
+func (v boolValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strconv.FormatBool(*v.p)
+}
+
+func (v boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("%q is not true or false", s)
+	}
+	*v.p = b
+	return nil
+}
+
+func (v boolValue) IsBoolFlag() bool { return true }
+
+type stringValue struct{ p *string }
+
+func (v stringValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return *v.p
+}
+
+func (v stringValue) Set(s string) error {
+	*v.p = strings.TrimSpace(s)
+	return nil
+}
+
+type durationValue struct{ p *time.Duration }
+
+func (v durationValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return v.p.String()
+}
+
+func (v durationValue) Set(s string) error {
+	d, err := time.ParseDuration(strings.TrimSpace(s))
+	if err != nil {
+		return fmt.Errorf("%q is not a duration, such as 30s or 5m", s)
+	}
+	*v.p = d
+	return nil
+}
+
+type levelValue struct{ p *slog.Level }
+
+func (v levelValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strings.ToLower(v.p.String())
+}
+
+func (v levelValue) Set(s string) error {
+	if err := v.p.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return fmt.Errorf("%q is not a log level, such as info or debug", s)
+	}
+	return nil
+}
+
+type listValue struct{ p *[]string }
+
+func (v listValue) String() string {
+	if v.p == nil {
+		return ""
+	}
+	return strings.Join(*v.p, ",")
+}
+
+func (v listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v.p = items
+	return nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+// Book covers are kept as files named after the slug of their book, in the covers directory
+// under static_path. Every read, write and removal is traced
+
+// coverPath returns the file holding the cover of the book with the given slug
+func (app *application) coverPath(bookSlug string) string {
+	return filepath.Join(app.config.staticPath, "covers", bookSlug+".jpg")
+}
+
+// readCover returns the cover of the book with the given slug, with os.ErrNotExist if it has none
+func (app *application) readCover(ctx context.Context, bookSlug string) ([]byte, error) {
+	path := app.coverPath(bookSlug)
+	_, span := startSpan(ctx, "cover.read", semconv.FilePath(path))
+
+	cover, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		// a book without a cover is nothing to flag
+		endSpan(span, nil)
+	} else {
+		endSpan(span, err)
+	}
+
+	return cover, err
+}
+
+// writeCover stores the cover of the book with the given slug, replacing any it had
+func (app *application) writeCover(ctx context.Context, bookSlug string, cover []byte) error {
+	path := app.coverPath(bookSlug)
+	_, span := startSpan(ctx, "cover.write", semconv.FilePath(path))
+
+	err := os.WriteFile(path, cover, 0666)
+	endSpan(span, err)
+
+	return err
+}
+
+// removeCover deletes the file with the given name from the covers directory
+func (app *application) removeCover(ctx context.Context, name string) error {
+	path := filepath.Join(app.config.staticPath, "covers", name)
+	_, span := startSpan(ctx, "cover.remove", semconv.FilePath(path))
+
+	err := os.Remove(path)
+	endSpan(span, err)
+
+	return err
+}
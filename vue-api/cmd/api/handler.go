@@ -1,115 +1,159 @@
 package main
 
 import (
-	"encoding/base64"
-	"errors"
 	"fmt"
 	"net/http"
-	"os"
-	"strconv"
 	"time"
 	"vue-api/internal/data"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/mozillazg/go-slugify"
 )
 
-var staticPath = "./static/"
-
 // jsonResponse is the type used for generic JSON responses
 type jsonResponse struct {
-	Error   bool        `json:"error"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Error   bool                `json:"error"`
+	Message string              `json:"message"`
+	Data    interface{}         `json:"data,omitempty"`
+	Errors  map[string][]string `json:"errors,omitempty"`
 }
 
 type envelope map[string]interface{}
 
-// Login is the handler used to attempt to log a user into the api
-func (app *application) Login(w http.ResponseWriter, r *http.Request) {
-	type credentials struct {
-		UserName string `json:"email"`
-		Password string `json:"password"`
+// The request bodies of the handlers below. They are named, rather than declared inside each
+// handler, so that the OpenAPI document can describe them (see openapi.go)
+type (
+	// loginInput holds the credentials a user logs in with
+	loginInput struct {
+		UserName string `json:"email" validate:"required"`
+		Password string `json:"password" validate:"required"`
+	}
+
+	// tokenInput holds a plain text token, to validate or to log out with
+	tokenInput struct {
+		Token string `json:"token" validate:"required"`
+	}
+
+	// idInput names the user or book a change applies to
+	idInput struct {
+		ID int `json:"id" validate:"required,min=1"`
 	}
 
-	var creds credentials
+	// revisionDiffInput names the two revisions of a book to compare
+	revisionDiffInput struct {
+		From int `json:"from" validate:"required,min=1"`
+		To   int `json:"to" validate:"required,min=1"`
+	}
+
+	// revertInput names the revision a book is put back to
+	revertInput struct {
+		RevisionID int `json:"revision_id" validate:"required,min=1"`
+		Version    int `json:"version"`
+	}
+)
+
+// selectOption is an entry of a list which the Vue app shows in a select box
+type selectOption struct {
+	Value int    `json:"value"`
+	Text  string `json:"text"`
+}
+
+// Login is the handler used to attempt to log a user into the api
+func (app *application) Login(w http.ResponseWriter, r *http.Request) {
+	var creds loginInput
 	var payload jsonResponse
 
 	err := app.readJSON(w, r, &creds)
 	if err != nil {
-		app.errorLog.Println(err)
-		payload.Error = true
-		payload.Message = "invalid json supplied, or json missing entirely"
-		_ = app.writeJSON(w, http.StatusBadRequest, payload)
+		app.metrics.login(loginInvalidRequest)
+		app.errorJSON(w, r, errInvalidJSON)
+		return
+	}
+
+	err = app.validate(r, creds)
+	if err != nil {
+		app.metrics.login(loginInvalidRequest)
+		app.errorJSON(w, r, err)
+		return
 	}
 
 	// look up the user by email
-	user, err := app.models.User.GetByEmail(creds.UserName)
+	user, err := app.models.User.GetByEmail(r.Context(), creds.UserName)
 	if err != nil {
-		app.errorJSON(w, errors.New("invalid username/password"))
+		app.metrics.login(loginInvalidCredentials)
+		app.errorJSON(w, r, errInvalidCredentials)
 		return
 	}
 
 	// validate the user's password
 	validPassword, err := user.PasswordMatches(creds.Password)
 	if err != nil || !validPassword {
-		app.errorJSON(w, errors.New("invalid username/password"))
+		app.metrics.login(loginInvalidCredentials)
+		app.errorJSON(w, r, errInvalidCredentials)
 		return
 	}
 
 	// make sure user is active
 	if user.Active == 0 {
-		app.errorJSON(w, errors.New("user is not active"))
+		app.metrics.login(loginInactive)
+		app.errorJSON(w, r, errUserInactive)
 		return
 	}
 
 	// we have a valid user, so generate a token
-	token, err := app.models.Token.GenerateToken(user.ID, 24*time.Hour)
+	token, err := data.GenerateToken(user.ID, app.config.tokenTTL)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.metrics.login(loginError)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	// save it to the database
-	err = app.models.Token.Insert(*token, *user)
+	err = app.models.Token.Insert(r.Context(), *token, *user)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.metrics.login(loginError)
+		app.errorJSON(w, r, err)
 		return
 	}
 
+	app.metrics.login(loginSuccess)
+
 	// send back a response
 	payload = jsonResponse{
 		Error:   false,
-		Message: "logged in",
+		Message: printerForUser(r, user).Sprintf("message.logged_in"),
 		Data:    envelope{"token": token, "user": user},
 	}
 
 	err = app.writeJSON(w, http.StatusOK, payload)
 	if err != nil {
-		app.errorLog.Println(err)
+		app.logger.ErrorContext(r.Context(), "could not write response", "error", err)
 	}
 }
 
 func (app *application) Logout(w http.ResponseWriter, r *http.Request) {
-	var requestPayload struct {
-		Token string `json:"token"`
-	}
+	var requestPayload tokenInput
 
 	err := app.readJSON(w, r, &requestPayload)
 	if err != nil {
-		app.errorJSON(w, errors.New("invalid json"))
+		app.errorJSON(w, r, errInvalidJSON)
 		return
 	}
 
-	err = app.models.Token.DeleteByToken(requestPayload.Token)
+	err = app.validate(r, requestPayload)
 	if err != nil {
-		app.errorJSON(w, errors.New("invalid json"))
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	err = app.models.Token.DeleteByToken(r.Context(), requestPayload.Token)
+	if err != nil {
+		app.errorJSON(w, r, errInvalidJSON)
 		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "logged out",
+		Message: app.translate(r, "message.logged_out"),
 	}
 
 	_ = app.writeJSON(w, http.StatusOK, payload)
@@ -119,16 +163,15 @@ func (app *application) Logout(w http.ResponseWriter, r *http.Request) {
 // handler should be protected in the routes file, and require that
 // the user have a valid token
 func (app *application) AllUsers(w http.ResponseWriter, r *http.Request) {
-	var users data.User
-	all, err := users.GetAll()
+	all, err := app.models.User.GetAll(r.Context())
 	if err != nil {
-		app.errorLog.Println(err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "success",
+		Message: app.translate(r, "message.success"),
 		Data:    envelope{"users": all},
 	}
 
@@ -140,128 +183,131 @@ func (app *application) EditUser(w http.ResponseWriter, r *http.Request) {
 	var user data.User
 	err := app.readJSON(w, r, &user)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	if user.ID == 0 {
-		// add user
-		if _, err := app.models.User.Insert(user); err != nil {
-			app.errorJSON(w, err)
-			return
-		}
-	} else {
-		// editing user
-		u, err := app.models.User.GetOne(user.ID)
-		if err != nil {
-			app.errorJSON(w, err)
-			return
-		}
-
-		u.Email = user.Email
-		u.FirstName = user.FirstName
-		u.LastName = user.LastName
-		u.Active = user.Active
-
-		if err := u.Update(); err != nil {
-			app.errorJSON(w, err)
-			return
-		}
-
-		// if password != string, update password
-		if user.Password != "" {
-			err := u.ResetPassword(user.Password)
-			if err != nil {
-				app.errorJSON(w, err)
-				return
-			}
-		}
+	saved, err := app.saveUser(r, user)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
 	}
 
+	headers := make(http.Header)
+	headers.Set("ETag", etag(saved.Version))
+
 	payload := jsonResponse{
 		Error:   false,
-		Message: "Changes saved",
+		Message: app.translate(r, "message.changes_saved"),
 	}
 
-	_ = app.writeJSON(w, http.StatusAccepted, payload)
+	_ = app.writeJSON(w, http.StatusAccepted, payload, headers)
 }
 
 // GetUser returns one user as JSON
 func (app *application) GetUser(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	userID, err := idParam(r, "id")
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	user, err := app.models.User.GetOne(userID)
+	user, err := app.models.User.GetOne(r.Context(), userID)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	_ = app.writeJSON(w, http.StatusOK, user)
+	headers := make(http.Header)
+	headers.Set("ETag", etag(user.Version))
+
+	_ = app.writeJSON(w, http.StatusOK, user, headers)
 }
 
-// DeleteUser delets a user from the users table by the id given in the supplied JSON file
+// DeleteUser moves the user with the id given in the supplied JSON file to the trash
 func (app *application) DeleteUser(w http.ResponseWriter, r *http.Request) {
-	var requestPayload struct {
-		ID int `json:"id"`
+	var requestPayload idInput
+
+	err := app.readValidJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
 	}
 
-	err := app.readJSON(w, r, &requestPayload)
+	err = app.deleteUser(r, requestPayload.ID)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	err = app.models.User.DeleteByID(requestPayload.ID)
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.user_trashed"),
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// UsersTrash lists all users which have been deleted, but not yet purged
+func (app *application) UsersTrash(w http.ResponseWriter, r *http.Request) {
+	all, err := app.models.User.GetAllDeleted(r.Context())
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "User deleted",
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"users": all},
 	}
 
 	_ = app.writeJSON(w, http.StatusOK, payload)
 }
 
-// LogUserOutAndSetInactive sets the user specified by the id value in the supplied JSON
-// to inactive, and deletes any tokens associated with that user id from the tokens table
-// in the database
-func (app *application) LogUserOutAndSetInactive(w http.ResponseWriter, r *http.Request) {
-	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+// RestoreUser takes the user with the id given in the supplied JSON out of the trash
+func (app *application) RestoreUser(w http.ResponseWriter, r *http.Request) {
+	var requestPayload idInput
+
+	err := app.readValidJSON(w, r, &requestPayload)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	user, err := app.models.User.GetOne(userID)
+	_, err = app.restoreUser(r, requestPayload.ID)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	user.Active = 0
-	err = user.Update()
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.user_restored"),
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// LogUserOutAndSetInactive sets the user specified by the id value in the supplied JSON
+// to inactive, and deletes any tokens associated with that user id from the tokens table
+// in the database
+func (app *application) LogUserOutAndSetInactive(w http.ResponseWriter, r *http.Request) {
+	userID, err := idParam(r, "id")
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	// delete tokens for user
-	err = app.models.Token.DeleteTokensForUser(userID)
+	_, err = app.logUserOut(r, userID)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "user logged out and set to inactive",
+		Message: app.translate(r, "message.user_logged_out"),
 	}
 
 	_ = app.writeJSON(w, http.StatusAccepted, payload)
@@ -270,18 +316,16 @@ func (app *application) LogUserOutAndSetInactive(w http.ResponseWriter, r *http.
 // ValidateToken accepts a JSON payload with a plain text token, and returns
 // true if that token is valid, or false if it is not, as a JSON response
 func (app *application) ValidateToken(w http.ResponseWriter, r *http.Request) {
-	var requestPayload struct {
-		Token string `json:"token"`
-	}
+	var requestPayload tokenInput
 
-	err := app.readJSON(w, r, &requestPayload)
+	err := app.readValidJSON(w, r, &requestPayload)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	valid := false
-	valid, _ = app.models.Token.ValidToken(requestPayload.Token)
+	valid, _ = app.models.ValidToken(r.Context(), requestPayload.Token)
 
 	payload := jsonResponse{
 		Error: false,
@@ -293,15 +337,15 @@ func (app *application) ValidateToken(w http.ResponseWriter, r *http.Request) {
 
 // AllBooks returns all books as JSON
 func (app *application) AllBooks(w http.ResponseWriter, r *http.Request) {
-	books, err := app.models.Book.GetAll()
+	books, err := app.models.Book.GetAll(r.Context())
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "success",
+		Message: app.translate(r, "message.success"),
 		Data:    envelope{"books": books},
 	}
 
@@ -312,37 +356,35 @@ func (app *application) AllBooks(w http.ResponseWriter, r *http.Request) {
 func (app *application) OneBook(w http.ResponseWriter, r *http.Request) {
 	slug := chi.URLParam(r, "slug")
 
-	book, err := app.models.Book.GetOneBySlug(slug)
+	book, err := app.models.Book.GetOneBySlug(r.Context(), slug)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
+	headers := make(http.Header)
+	headers.Set("ETag", etag(book.Version))
+
 	payload := jsonResponse{
 		Error: false,
 		Data:  book,
 	}
 
-	app.writeJSON(w, http.StatusOK, payload)
+	app.writeJSON(w, http.StatusOK, payload, headers)
 }
 
 // AuthorsAll returns a list of all authors consisting of author id and author name, as JSON
 func (app *application) AuthorsAll(w http.ResponseWriter, r *http.Request) {
-	all, err := app.models.Author.All()
+	all, err := app.models.Author.All(r.Context())
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	type selectData struct {
-		Value int    `json:"value"`
-		Text  string `json:"text"`
-	}
-
-	var results []selectData
+	var results []selectOption
 
 	for _, x := range all {
-		author := selectData{
+		author := selectOption{
 			Value: x.ID,
 			Text:  x.AuthorName,
 		}
@@ -359,114 +401,266 @@ func (app *application) AuthorsAll(w http.ResponseWriter, r *http.Request) {
 }
 
 func (app *application) EditBook(w http.ResponseWriter, r *http.Request) {
-	var requestPayload struct {
-		ID              int    `json:"id"`
-		Title           string `json:"title"`
-		AuthorID        int    `json:"author_id"`
-		PublicationYear int    `json:"publication_year"`
-		Description     string `json:"description"`
-		CoverBase64     string `json:"cover"`
-		GenreIDs        []int  `json:"genre_ids"`
-	}
+	var requestPayload bookInput
 
 	err := app.readJSON(w, r, &requestPayload)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	book := data.Book{
-		ID:              requestPayload.ID,
-		Title:           requestPayload.Title,
-		AuthorID:        requestPayload.AuthorID,
-		PublicationYear: requestPayload.PublicationYear,
-		Description:     requestPayload.Description,
-		Slug:            slugify.Slugify(requestPayload.Title),
-		GenreIDs:        requestPayload.GenreIDs,
+	book, err := app.saveBook(r, requestPayload)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
 	}
 
-	if len(requestPayload.CoverBase64) > 0 {
-		// we have a cover
-		decoded, err := base64.StdEncoding.DecodeString(requestPayload.CoverBase64)
-		if err != nil {
-			app.errorJSON(w, err)
-			return
-		}
+	headers := make(http.Header)
+	headers.Set("ETag", etag(book.Version))
 
-		// write image to /static/covers
-		if err := os.WriteFile(fmt.Sprintf("%s/covers/%s.jpg", staticPath, book.Slug), decoded, 0666); err != nil {
-			app.errorJSON(w, err)
-			return
-		}
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.changes_saved"),
 	}
 
-	if book.ID == 0 {
-		// adding a book
-		_, err := app.models.Book.Insert(book)
-		if err != nil {
-			app.errorJSON(w, err)
-			return
-		}
-	} else {
-		// updating a book
-		err := book.Update()
-		if err != nil {
-			app.errorJSON(w, err)
-			return
-		}
+	app.writeJSON(w, http.StatusAccepted, payload, headers)
+}
+
+// BookRevisions returns all stored revisions of the book with the id given in the url, newest first
+func (app *application) BookRevisions(w http.ResponseWriter, r *http.Request) {
+	bookID, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	revisions, err := app.models.BookRevision.GetAllForBook(r.Context(), bookID)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"revisions": revisions},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// DiffBookRevisions compares two revisions of the book with the id given in the url, and
+// returns the fields which changed going from the first revision to the second
+func (app *application) DiffBookRevisions(w http.ResponseWriter, r *http.Request) {
+	bookID, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	var requestPayload revisionDiffInput
+
+	err = app.readValidJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	from, err := app.bookRevision(r.Context(), bookID, requestPayload.From)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	to, err := app.bookRevision(r.Context(), bookID, requestPayload.To)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"from": from, "to": to, "changes": from.Diff(to)},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// RevertBook restores the book with the id given in the url to the state stored in the
+// revision given in the supplied JSON. The revert is itself recorded as a new revision. When a
+// version is given too, the revert is rejected if the book has changed since
+func (app *application) RevertBook(w http.ResponseWriter, r *http.Request) {
+	bookID, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	var requestPayload revertInput
+
+	err = app.readValidJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	_, err = app.revertBook(r, bookID, requestPayload.RevisionID, requestPayload.Version)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "Changes saved",
+		Message: app.translate(r, "message.book_reverted"),
 	}
 
 	app.writeJSON(w, http.StatusAccepted, payload)
 }
 
 func (app *application) BookByID(w http.ResponseWriter, r *http.Request) {
-	bookID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	bookID, err := idParam(r, "id")
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	book, err := app.models.Book.GetOneById(bookID)
+	book, err := app.models.Book.GetOneById(r.Context(), bookID)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
+	headers := make(http.Header)
+	headers.Set("ETag", etag(book.Version))
+
 	payload := jsonResponse{
 		Error: false,
 		Data:  book,
 	}
 
-	app.writeJSON(w, http.StatusOK, payload)
+	app.writeJSON(w, http.StatusOK, payload, headers)
 }
 
 func (app *application) DeleteBook(w http.ResponseWriter, r *http.Request) {
-	var requestPayload struct {
-		ID int `json:"id"`
+	var requestPayload idInput
+
+	err := app.readValidJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
 	}
 
-	err := app.readJSON(w, r, &requestPayload)
+	err = app.deleteBook(r, requestPayload.ID)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.book_trashed"),
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+
+}
+
+// BooksTrash lists all books which have been deleted, but not yet purged
+func (app *application) BooksTrash(w http.ResponseWriter, r *http.Request) {
+	books, err := app.models.Book.GetAllDeleted(r.Context())
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
-	err = app.models.Book.DeleteByID(requestPayload.ID)
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"books": books},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// RestoreBook takes the book with the id given in the supplied JSON out of the trash
+func (app *application) RestoreBook(w http.ResponseWriter, r *http.Request) {
+	var requestPayload idInput
+
+	err := app.readValidJSON(w, r, &requestPayload)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	_, err = app.restoreBook(r, requestPayload.ID)
 	if err != nil {
-		app.errorJSON(w, err)
+		app.errorJSON(w, r, err)
 		return
 	}
 
 	payload := jsonResponse{
 		Error:   false,
-		Message: "Book deleted",
+		Message: app.translate(r, "message.book_restored"),
 	}
 
 	app.writeJSON(w, http.StatusOK, payload)
+}
 
+// AuditLog returns the audit log entries matching the filter given in the supplied JSON
+func (app *application) AuditLog(w http.ResponseWriter, r *http.Request) {
+	var filter data.AuditFilter
+
+	err := app.readValidJSON(w, r, &filter)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	if filter.PageSize <= 0 || filter.PageSize > maxAuditPageSize {
+		filter.PageSize = defaultAuditPageSize
+	}
+
+	entries, err := app.models.Audit.Filter(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"entries": entries},
+	}
+
+	app.writeJSON(w, http.StatusOK, payload)
+}
+
+// ExportAuditLog sends every audit log entry matching the filter given in the supplied JSON as a CSV file
+func (app *application) ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	var filter data.AuditFilter
+
+	err := app.readValidJSON(w, r, &filter)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	// the export is never paginated
+	filter.Page = 0
+	filter.PageSize = 0
+
+	entries, err := app.models.Audit.Filter(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-log-%s.csv"`, time.Now().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeAuditCSV(w, entries); err != nil {
+		app.logger.ErrorContext(r.Context(), "could not write audit log export", "error", err)
+	}
 }
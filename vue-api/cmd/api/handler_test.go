@@ -1,19 +1,38 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
+	"vue-api/internal/data"
 )
 
+// fakeUsers is a UserRepository returning fixed users. Calling a method it does not define
+// panics, through the nil interface it embeds
+type fakeUsers struct {
+	data.UserRepository
+	users []*data.User
+
+	// ctx is the context the last call was made with
+	ctx context.Context
+}
+
+func (f *fakeUsers) GetAll(ctx context.Context) ([]*data.User, error) {
+	f.ctx = ctx
+	return f.users, nil
+}
+
 func TestApplication_AllUsers(t *testing.T) {
 	// create some mock rows, and add one row
-	var mockedRows = mockedDB.NewRows([]string{"id", "email", "first_name", "last_name", "password", "active", "created_at", "updated_at", "has_token"})
-	mockedRows.AddRow("1", "you@gma.com", "Jack", "Smith", "abc123", "1", time.Now(), time.Now(), "0")
+	var mockedRows = mockedDB.NewRows([]string{"id", "email", "first_name", "last_name", "password", "user_active", "language", "created_at", "updated_at", "version", "has_token"})
+	mockedRows.AddRow("1", "you@gma.com", "Jack", "Smith", "abc123", "1", "", time.Now(), time.Now(), "1", "0")
 
 	// tell mock what queries we expect
-	mockedDB.ExpectQuery("select \\\\* ").WillReturnRows(mockedRows)
+	mockedDB.ExpectQuery("select id, email, first_name").WillReturnRows(mockedRows)
 
 	// create a test recorder which satisifies the requirements for a ResponseRedcorder
 	rr := httptest.NewRecorder()
@@ -30,4 +49,55 @@ func TestApplication_AllUsers(t *testing.T) {
 		t.Error("All users return wrong status code of: ", rr.Code)
 	}
 
+	if err := mockedDB.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplication_AllUsers_dbError(t *testing.T) {
+	mockedDB.ExpectQuery("select id, email, first_name").WillReturnError(errors.New("connection reset"))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/users", nil)
+
+	testApp.AllUsers(rr, req)
+
+	if rr.Code == http.StatusOK {
+		t.Error("expected the database error to be reported")
+	}
+
+	if ct := rr.Header().Get("Content-Type"); ct != problemContentType {
+		t.Errorf("expected a problem response but got %q", ct)
+	}
+
+	if err := mockedDB.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestApplication_AllUsers_fakeRepository(t *testing.T) {
+	users := &fakeUsers{users: []*data.User{{ID: 7, Email: "fake@example.com"}}}
+	app := &application{
+		config: defaultConfig(),
+		logger: discardLogger(),
+		models: data.Models{User: users},
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/admin/users", nil)
+	req = req.WithContext(context.WithValue(req.Context(), contextKeyRequestID, "abc123"))
+
+	app.AllUsers(rr, req)
+
+	if users.ctx == nil || requestIDFrom(users.ctx) != "abc123" {
+		t.Error("expected the repository to be called with the context of the request")
+	}
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status %d but got %d", http.StatusOK, rr.Code)
+	}
+
+	if !strings.Contains(rr.Body.String(), "fake@example.com") {
+		t.Errorf("expected the users from the fake repository, got %s", rr.Body.String())
+	}
 }
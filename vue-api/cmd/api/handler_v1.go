@@ -0,0 +1,896 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"vue-api/internal/data"
+	"vue-api/internal/validator"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// The handlers in this file serve the versioned, resource oriented api mounted at /api/v1. Reads
+// use GET and support conditional requests through ETag/If-None-Match, and changes use POST, PUT,
+// PATCH (as JSON merge patch) and DELETE on the resource itself. The changes are carried out by
+// the same functions as the original admin handlers (see actions.go), which stay in place for
+// the Vue app.
+
+// apiV1Prefix is the path all versioned api routes are mounted under
+const apiV1Prefix = "/api/v1"
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// V1ListBooks returns all books, optionally paginated with the page and page_size query parameters
+func (app *application) V1ListBooks(w http.ResponseWriter, r *http.Request) {
+	var books []*data.Book
+
+	page, pageSize, paginated, err := pagination(r)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	if paginated {
+		books, err = app.models.Book.GetAllPaginated(r.Context(), page, pageSize)
+	} else {
+		books, err = app.models.Book.GetAll(r.Context())
+	}
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"books": books},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1GetBook returns one book by id
+func (app *application) V1GetBook(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	book, err := app.models.Book.GetOneById(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	if app.notModified(w, r, book.Version) {
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "book", book, book.Version)
+}
+
+// V1CreateBook adds a new book
+func (app *application) V1CreateBook(w http.ResponseWriter, r *http.Request) {
+	var input bookInput
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = 0
+	book, err := app.saveBook(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/books/%d", apiV1Prefix, book.ID))
+	app.writeResource(w, http.StatusCreated, "book", book, book.Version)
+}
+
+// V1UpdateBook replaces the book with the id given in the url. A book sent without genre_ids has
+// no genres, as with any other field left out of a replacement
+func (app *application) V1UpdateBook(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	var input bookInput
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	if input.GenreIDs == nil {
+		input.GenreIDs = []int{}
+	}
+
+	input.ID = id
+	book, err := app.saveBook(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "book", book, book.Version)
+}
+
+// V1PatchBook applies a JSON merge patch (RFC 7396) to the book with the id given in the url.
+// Fields absent from the patch keep their current value, and fields set to null are cleared
+func (app *application) V1PatchBook(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	patch, err := app.readMergePatch(w, r)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	current, err := app.models.Book.GetOneById(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	document := bookInput{
+		Title:           current.Title,
+		AuthorID:        current.AuthorID,
+		PublicationYear: current.PublicationYear,
+		Description:     current.Description,
+		GenreIDs:        current.GenreIDs,
+		Version:         current.Version,
+	}
+
+	var input bookInput
+	err = patchDocument(document, patch, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	// genre_ids is always in the document, so if it is missing now the patch set it to null
+	if input.GenreIDs == nil {
+		input.GenreIDs = []int{}
+	}
+
+	input.ID = id
+	book, err := app.saveBook(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "book", book, book.Version)
+}
+
+// V1DeleteBook moves the book with the id given in the url to the trash
+func (app *application) V1DeleteBook(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	err = app.deleteBook(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// V1RestoreBook takes the book with the id given in the url out of the trash
+func (app *application) V1RestoreBook(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	book, err := app.restoreBook(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "book", book, book.Version)
+}
+
+// V1DiffBookRevisions compares the revisions given in the from and to query parameters
+func (app *application) V1DiffBookRevisions(w http.ResponseWriter, r *http.Request) {
+	bookID, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	v := validator.New(printerFor(r))
+
+	fromID, err := strconv.Atoi(r.URL.Query().Get("from"))
+	v.Check(err == nil && fromID > 0, "from", "validation.revision_id")
+
+	toID, err := strconv.Atoi(r.URL.Query().Get("to"))
+	v.Check(err == nil && toID > 0, "to", "validation.revision_id")
+
+	if !v.Valid() {
+		app.errorJSON(w, r, &validationError{errors: v.Errors})
+		return
+	}
+
+	from, err := app.bookRevision(r.Context(), bookID, fromID)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	to, err := app.bookRevision(r.Context(), bookID, toID)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"from": from, "to": to, "changes": from.Diff(to)},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1RevertBook puts the book with the id given in the url back to the given revision. An If-Match
+// header makes the revert fail with 409 Conflict when the book has changed since
+func (app *application) V1RevertBook(w http.ResponseWriter, r *http.Request) {
+	bookID, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	revisionID, err := idParam(r, "revisionID")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	book, err := app.revertBook(r, bookID, revisionID, 0)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "book", book, book.Version)
+}
+
+// V1ListUsers returns all users which are not in the trash
+func (app *application) V1ListUsers(w http.ResponseWriter, r *http.Request) {
+	all, err := app.models.User.GetAll(r.Context())
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	users := make([]*data.User, 0, len(all))
+	for _, u := range all {
+		users = append(users, userSnapshot(u))
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"users": users},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1GetUser returns one user by id, without the password hash
+func (app *application) V1GetUser(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	user, err := app.models.User.GetOne(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	if app.notModified(w, r, user.Version) {
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "user", userSnapshot(user), user.Version)
+}
+
+// V1CreateUser adds a new user
+func (app *application) V1CreateUser(w http.ResponseWriter, r *http.Request) {
+	var input data.User
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = 0
+	user, err := app.saveUser(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/users/%d", apiV1Prefix, user.ID))
+	app.writeResource(w, http.StatusCreated, "user", user, user.Version)
+}
+
+// V1UpdateUser replaces the user with the id given in the url
+func (app *application) V1UpdateUser(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	var input data.User
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = id
+	user, err := app.saveUser(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "user", user, user.Version)
+}
+
+// userDocument holds the fields of a user which can be changed with a merge patch
+type userDocument struct {
+	Email     string `json:"email"`
+	FirstName string `json:"first_name"`
+	LastName  string `json:"last_name"`
+	Active    int    `json:"active"`
+	Language  string `json:"language"`
+	Password  string `json:"password"`
+	Version   int    `json:"version"`
+}
+
+// V1PatchUser applies a JSON merge patch (RFC 7396) to the user with the id given in the url.
+// Fields absent from the patch keep their current value, and fields set to null are cleared. The
+// password is only changed when the patch contains one
+func (app *application) V1PatchUser(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	patch, err := app.readMergePatch(w, r)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	current, err := app.models.User.GetOne(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	document := userDocument{
+		Email:     current.Email,
+		FirstName: current.FirstName,
+		LastName:  current.LastName,
+		Active:    current.Active,
+		Language:  current.Language,
+		Version:   current.Version,
+	}
+
+	var patched userDocument
+	err = patchDocument(document, patch, &patched)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input := data.User{
+		ID:        id,
+		Email:     patched.Email,
+		FirstName: patched.FirstName,
+		LastName:  patched.LastName,
+		Active:    patched.Active,
+		Language:  patched.Language,
+		Password:  patched.Password,
+		Version:   patched.Version,
+	}
+
+	user, err := app.saveUser(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "user", user, user.Version)
+}
+
+// V1DeleteUser moves the user with the id given in the url to the trash
+func (app *application) V1DeleteUser(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	err = app.deleteUser(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// V1RestoreUser takes the user with the id given in the url out of the trash
+func (app *application) V1RestoreUser(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	user, err := app.restoreUser(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "user", user, user.Version)
+}
+
+// V1LogUserOut sets the user with the id given in the url to inactive, and revokes their tokens
+func (app *application) V1LogUserOut(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	user, err := app.logUserOut(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	app.writeResource(w, http.StatusOK, "user", user, user.Version)
+}
+
+// V1ListAuthors returns all authors
+func (app *application) V1ListAuthors(w http.ResponseWriter, r *http.Request) {
+	authors, err := app.models.Author.All(r.Context())
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"authors": authors},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1GetAuthor returns one author by id
+func (app *application) V1GetAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	author, err := app.models.Author.GetOne(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"author": author},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1CreateAuthor adds a new author
+func (app *application) V1CreateAuthor(w http.ResponseWriter, r *http.Request) {
+	var input data.Author
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = 0
+	author, err := app.saveAuthor(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/authors/%d", apiV1Prefix, author.ID))
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"author": author},
+	}
+
+	_ = app.writeJSON(w, http.StatusCreated, payload)
+}
+
+// V1UpdateAuthor replaces the author with the id given in the url
+func (app *application) V1UpdateAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	var input data.Author
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = id
+	author, err := app.saveAuthor(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"author": author},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1DeleteAuthor deletes the author with the id given in the url
+func (app *application) V1DeleteAuthor(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	err = app.deleteAuthor(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// V1ListGenres returns all genres
+func (app *application) V1ListGenres(w http.ResponseWriter, r *http.Request) {
+	genres, err := app.models.Genre.All(r.Context())
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"genres": genres},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1GetGenre returns one genre by id
+func (app *application) V1GetGenre(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	genre, err := app.models.Genre.GetOne(r.Context(), id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"genre": genre},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1CreateGenre adds a new genre
+func (app *application) V1CreateGenre(w http.ResponseWriter, r *http.Request) {
+	var input data.Genre
+
+	err := app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = 0
+	genre, err := app.saveGenre(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", fmt.Sprintf("%s/genres/%d", apiV1Prefix, genre.ID))
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"genre": genre},
+	}
+
+	_ = app.writeJSON(w, http.StatusCreated, payload)
+}
+
+// V1UpdateGenre replaces the genre with the id given in the url
+func (app *application) V1UpdateGenre(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	var input data.Genre
+
+	err = app.readJSON(w, r, &input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	input.ID = id
+	genre, err := app.saveGenre(r, input)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{"genre": genre},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1DeleteGenre deletes the genre with the id given in the url
+func (app *application) V1DeleteGenre(w http.ResponseWriter, r *http.Request) {
+	id, err := idParam(r, "id")
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	err = app.deleteGenre(r, id)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// V1AuditLog returns the audit log entries matching the filter given in the query string
+func (app *application) V1AuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	if filter.PageSize <= 0 || filter.PageSize > maxAuditPageSize {
+		filter.PageSize = defaultAuditPageSize
+	}
+
+	entries, err := app.models.Audit.Filter(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	payload := jsonResponse{
+		Error:   false,
+		Message: app.translate(r, "message.success"),
+		Data:    envelope{"entries": entries},
+	}
+
+	_ = app.writeJSON(w, http.StatusOK, payload)
+}
+
+// V1ExportAuditLog sends every audit log entry matching the filter given in the query string as CSV
+func (app *application) V1ExportAuditLog(w http.ResponseWriter, r *http.Request) {
+	filter, err := auditFilterFromQuery(r)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	filter.Page = 0
+	filter.PageSize = 0
+
+	entries, err := app.models.Audit.Filter(r.Context(), filter)
+	if err != nil {
+		app.errorJSON(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="audit-log-%s.csv"`, time.Now().Format("20060102-150405")))
+	w.WriteHeader(http.StatusOK)
+
+	if err := writeAuditCSV(w, entries); err != nil {
+		app.logger.ErrorContext(r.Context(), "could not write audit log export", "error", err)
+	}
+}
+
+// writeResource sends a single resource wrapped in the usual json response, along with its ETag
+func (app *application) writeResource(w http.ResponseWriter, status int, name string, resource interface{}, version int) {
+	headers := make(http.Header)
+	headers.Set("ETag", etag(version))
+	headers.Set("Cache-Control", "no-cache")
+
+	payload := jsonResponse{
+		Error: false,
+		Data:  envelope{name: resource},
+	}
+
+	_ = app.writeJSON(w, status, payload, headers)
+}
+
+// notModified answers a conditional GET with 304 Not Modified when the client already has the
+// current version of a resource, and reports whether it did so
+func (app *application) notModified(w http.ResponseWriter, r *http.Request, version int) bool {
+	tag := etag(version)
+
+	for _, candidate := range splitHeaderList(r.Header.Get("If-None-Match")) {
+		if candidate == "*" || candidate == tag || candidate == "W/"+tag {
+			w.Header().Set("ETag", tag)
+			w.WriteHeader(http.StatusNotModified)
+			return true
+		}
+	}
+
+	return false
+}
+
+// idParam reads a positive integer id from the named url parameter
+func idParam(r *http.Request, name string) (int, error) {
+	id, err := strconv.Atoi(chi.URLParam(r, name))
+	if err != nil || id < 1 {
+		return 0, badRequest(codeBadRequest, fmt.Errorf("invalid %s parameter", name))
+	}
+	return id, nil
+}
+
+// pagination reads the page and page_size query parameters; paginated is false when neither is given
+func pagination(r *http.Request) (page, pageSize int, paginated bool, err error) {
+	qs := r.URL.Query()
+	if qs.Get("page") == "" && qs.Get("page_size") == "" {
+		return 0, 0, false, nil
+	}
+
+	page, pageSize = 1, defaultPageSize
+	v := validator.New(printerFor(r))
+
+	if s := qs.Get("page"); s != "" {
+		page, err = strconv.Atoi(s)
+		v.Check(err == nil && page >= 1, "page", "validation.positive_number")
+	}
+
+	if s := qs.Get("page_size"); s != "" {
+		pageSize, err = strconv.Atoi(s)
+		v.Check(err == nil && pageSize >= 1 && pageSize <= maxPageSize, "page_size", "validation.between", 1, maxPageSize)
+	}
+
+	if !v.Valid() {
+		return 0, 0, false, &validationError{errors: v.Errors}
+	}
+
+	return page, pageSize, true, nil
+}
+
+// auditFilterFromQuery builds an audit log filter from the query string
+func auditFilterFromQuery(r *http.Request) (data.AuditFilter, error) {
+	var filter data.AuditFilter
+
+	qs := r.URL.Query()
+	v := validator.New(printerFor(r))
+
+	ints := map[string]*int{
+		"actor_id":  &filter.ActorID,
+		"entity_id": &filter.EntityID,
+		"page":      &filter.Page,
+		"page_size": &filter.PageSize,
+	}
+
+	for name, dest := range ints {
+		if s := qs.Get(name); s != "" {
+			n, err := strconv.Atoi(s)
+			v.Check(err == nil, name, "validation.number")
+			*dest = n
+		}
+	}
+
+	times := map[string]**time.Time{
+		"from": &filter.From,
+		"to":   &filter.To,
+	}
+
+	for name, dest := range times {
+		if s := qs.Get(name); s != "" {
+			t, err := time.Parse(time.RFC3339, s)
+			v.Check(err == nil, name, "validation.timestamp")
+			if err == nil {
+				*dest = &t
+			}
+		}
+	}
+
+	filter.Action = qs.Get("action")
+	filter.Entity = qs.Get("entity")
+
+	v.Struct(filter)
+	if !v.Valid() {
+		return filter, &validationError{errors: v.Errors}
+	}
+
+	return filter, nil
+}
+
+// splitHeaderList splits a comma separated header value, such as If-None-Match, into its elements
+func splitHeaderList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vue-api/internal/data"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func Test_notModified(t *testing.T) {
+	var tests = []struct {
+		name        string
+		ifNoneMatch string
+		expected    bool
+	}{
+		{"no header", "", false},
+		{"current version", `"3"`, true},
+		{"weak current version", `W/"3"`, true},
+		{"one of several", `"1", "3"`, true},
+		{"old version", `"2"`, false},
+		{"wildcard", "*", true},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("GET", "/", nil)
+		if e.ifNoneMatch != "" {
+			req.Header.Set("If-None-Match", e.ifNoneMatch)
+		}
+
+		rr := httptest.NewRecorder()
+		result := testApp.notModified(rr, req, 3)
+
+		if result != e.expected {
+			t.Errorf("%s: expected %t but got %t", e.name, e.expected, result)
+		}
+
+		if result && rr.Code != http.StatusNotModified {
+			t.Errorf("%s: expected status 304 but got %d", e.name, rr.Code)
+		}
+	}
+}
+
+func Test_idParam(t *testing.T) {
+	for value, valid := range map[string]bool{"12": true, "0": false, "-1": false, "abc": false} {
+		rctx := chi.NewRouteContext()
+		rctx.URLParams.Add("id", value)
+		req, _ := http.NewRequest("GET", "/", nil)
+		req = req.WithContext(context.WithValue(req.Context(), chi.RouteCtxKey, rctx))
+
+		_, err := idParam(req, "id")
+		if valid && err != nil {
+			t.Errorf("%s: unexpected error %v", value, err)
+		}
+		if !valid && err == nil {
+			t.Errorf("%s: expected an error", value)
+		}
+	}
+}
+
+func Test_pagination(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/books", nil)
+	_, _, paginated, err := pagination(req)
+	if err != nil || paginated {
+		t.Error("expected no pagination without query parameters")
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/books?page=2", nil)
+	page, pageSize, paginated, err := pagination(req)
+	if err != nil || !paginated || page != 2 || pageSize != defaultPageSize {
+		t.Errorf("unexpected pagination: page %d, size %d, err %v", page, pageSize, err)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/books?page_size=1000", nil)
+	_, _, _, err = pagination(req)
+	if err == nil {
+		t.Error("expected an error for a page size over the maximum")
+	}
+}
+
+func Test_auditFilterFromQuery(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/audit?actor_id=2&entity=book&from=2024-01-02T03:04:05Z", nil)
+
+	filter, err := auditFilterFromQuery(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if filter.ActorID != 2 || filter.Entity != "book" || filter.From == nil || filter.From.Year() != 2024 {
+		t.Errorf("filter not built from query string: %+v", filter)
+	}
+
+	req, _ = http.NewRequest("GET", "/api/v1/audit?to=yesterday", nil)
+	if _, err := auditFilterFromQuery(req); err == nil {
+		t.Error("expected an error for an invalid timestamp")
+	}
+}
+
+func Test_V1RevertBook(t *testing.T) {
+	app, password := newMemoryTestApp(t)
+	routes := app.routes()
+	token := login(t, routes, password)
+
+	var result struct {
+		Book      data.Book           `json:"book"`
+		Revisions []data.BookRevision `json:"revisions"`
+		Entries   []data.AuditEntry   `json:"entries"`
+	}
+
+	input := bookInput{Title: "No Genres Yet", AuthorID: 1, PublicationYear: 2020, Description: "Uncategorised."}
+	if status := call(t, routes, http.MethodPost, "/api/v1/books", token, input, &result); status != http.StatusCreated {
+		t.Fatalf("expected the book to be created, got status %d", status)
+	}
+	bookPath := fmt.Sprintf("/api/v1/books/%d", result.Book.ID)
+
+	input.GenreIDs = []int{1}
+	input.Version = result.Book.Version
+	if status := call(t, routes, http.MethodPut, bookPath, token, input, &result); status != http.StatusOK || len(result.Book.GenreIDs) != 1 {
+		t.Fatalf("expected the book to get a genre, got status %d and %v", status, result.Book.GenreIDs)
+	}
+
+	call(t, routes, http.MethodGet, bookPath+"/revisions", token, nil, &result)
+	first := result.Revisions[len(result.Revisions)-1]
+
+	var reverted struct {
+		Book data.Book `json:"book"`
+	}
+	revertPath := fmt.Sprintf("%s/revisions/%d/revert", bookPath, first.ID)
+	if status := call(t, routes, http.MethodPost, revertPath, token, nil, &reverted); status != http.StatusOK {
+		t.Fatalf("expected the book to be reverted, got status %d", status)
+	}
+	if len(reverted.Book.GenreIDs) != 0 {
+		t.Errorf("expected the revert to remove the genre the first revision did not have, got %v", reverted.Book.GenreIDs)
+	}
+
+	call(t, routes, http.MethodGet, "/api/v1/audit?action=book.revert", token, nil, &result)
+	if len(result.Entries) != 1 || result.Entries[0].EntityID != reverted.Book.ID {
+		t.Errorf("expected the revert in the audit log, got %+v", result.Entries)
+	}
+}
+
+func Test_V1RevertBook_conflict(t *testing.T) {
+	app, password := newMemoryTestApp(t)
+	routes := app.routes()
+	token := login(t, routes, password)
+
+	var result struct {
+		Book      data.Book           `json:"book"`
+		Revisions []data.BookRevision `json:"revisions"`
+	}
+	call(t, routes, http.MethodGet, "/api/v1/books/1/revisions", token, nil, &result)
+	revertPath := fmt.Sprintf("/api/v1/books/1/revisions/%d/revert", result.Revisions[0].ID)
+
+	call(t, routes, http.MethodGet, "/api/v1/books/1", token, nil, &result)
+	stale := result.Book.Version
+
+	// someone else edits the book
+	edit := bookInput{Title: "Edited Meanwhile", AuthorID: result.Book.AuthorID, PublicationYear: result.Book.PublicationYear, Description: result.Book.Description, GenreIDs: result.Book.GenreIDs}
+	if status := call(t, routes, http.MethodPut, "/api/v1/books/1", token, edit, nil); status != http.StatusOK {
+		t.Fatalf("expected the book to be edited, got status %d", status)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, revertPath, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("If-Match", etag(stale))
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusConflict {
+		t.Fatalf("expected a revert based on a stale version to be rejected, got status %d", rr.Code)
+	}
+
+	call(t, routes, http.MethodGet, "/api/v1/books/1", token, nil, &result)
+	if result.Book.Title != "Edited Meanwhile" {
+		t.Errorf("expected the edit to be kept, got %q", result.Book.Title)
+	}
+}
+
+func Test_V1UpdateBook_rejected(t *testing.T) {
+	app, password := newMemoryTestApp(t)
+	routes := app.routes()
+	token := login(t, routes, password)
+
+	// a book added before revisions were recorded has none
+	id, err := app.models.Book.Insert(context.Background(), data.Book{Title: "Unrevised", AuthorID: 1, PublicationYear: 2020})
+	if err != nil {
+		t.Fatal(err)
+	}
+	bookPath := fmt.Sprintf("/api/v1/books/%d", id)
+
+	input := bookInput{Title: "Stale", AuthorID: 1, PublicationYear: 2020, Description: "Out of date.", Version: 99}
+	if status := call(t, routes, http.MethodPut, bookPath, token, input, nil); status != http.StatusConflict {
+		t.Fatalf("expected status %d for a stale version, got %d", http.StatusConflict, status)
+	}
+
+	var result struct {
+		Revisions []data.BookRevision `json:"revisions"`
+	}
+	call(t, routes, http.MethodGet, bookPath+"/revisions", token, nil, &result)
+	if len(result.Revisions) != 0 {
+		t.Errorf("expected a rejected edit to record no revision, got %d", len(result.Revisions))
+	}
+
+	if status := call(t, routes, http.MethodPut, "/api/v1/books/1000", token, input, nil); status != http.StatusNotFound {
+		t.Errorf("expected status %d for a book which does not exist, got %d", http.StatusNotFound, status)
+	}
+}
+
+func Test_V1UpdateBook_replacesGenres(t *testing.T) {
+	app, password := newMemoryTestApp(t)
+	routes := app.routes()
+	token := login(t, routes, password)
+
+	var result struct {
+		Book data.Book `json:"book"`
+	}
+	input := bookInput{Title: "Genre Fluid", AuthorID: 1, PublicationYear: 2020, Description: "Hard to place.", GenreIDs: []int{1, 2}}
+	if status := call(t, routes, http.MethodPost, "/api/v1/books", token, input, &result); status != http.StatusCreated {
+		t.Fatalf("expected the book to be created, got status %d", status)
+	}
+	bookPath := fmt.Sprintf("/api/v1/books/%d", result.Book.ID)
+
+	// genre_ids is left out, so the book is replaced by one with no genres
+	input.GenreIDs = nil
+	input.Version = result.Book.Version
+	if status := call(t, routes, http.MethodPut, bookPath, token, input, nil); status != http.StatusOK {
+		t.Fatalf("expected the book to be replaced, got status %d", status)
+	}
+
+	book, err := app.models.Book.GetOneById(context.Background(), result.Book.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(book.GenreIDs) != 0 {
+		t.Errorf("expected a replacement without genre_ids to remove the genres, got %v", book.GenreIDs)
+	}
+}
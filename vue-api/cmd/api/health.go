@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// readinessTimeout bounds the time taken by each readiness check, so that a hung database makes
+// /readyz fail rather than hang
+const readinessTimeout = 2 * time.Second
+
+// errShuttingDown is reported by /readyz once the server has been asked to stop
+var errShuttingDown = errors.New("shutting down")
+
+// healthCheck is one thing the api needs in order to serve requests. /readyz is open to anyone,
+// so when the check fails it reports failure, which says what is wrong without any of the details
+// the error may hold, such as hosts or paths; the error itself is logged
+type healthCheck struct {
+	name    string
+	check   func(ctx context.Context) error
+	failure string
+}
+
+// checkResult is the outcome of a health check, as reported by /readyz
+type checkResult struct {
+	Status     string  `json:"status"`
+	Error      string  `json:"error,omitempty"`
+	DurationMS float64 `json:"duration_ms"`
+}
+
+// healthResponse is the body sent by /healthz and /readyz
+type healthResponse struct {
+	Status string                 `json:"status"`
+	Checks map[string]checkResult `json:"checks,omitempty"`
+}
+
+const (
+	healthOK          = "ok"
+	healthUnavailable = "unavailable"
+	healthError       = "error"
+)
+
+// Healthz reports that the process is up and serving http. It checks nothing else, so that an
+// orchestrator does not restart the api because the database is down
+func (app *application) Healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	_ = app.writeJSON(w, http.StatusOK, healthResponse{Status: healthOK})
+}
+
+// Readyz reports whether the api can serve requests: every readiness check must pass, and the
+// server must not be shutting down. The checks run concurrently, each with its own timeout
+func (app *application) Readyz(w http.ResponseWriter, r *http.Request) {
+	response := healthResponse{Status: healthOK, Checks: make(map[string]checkResult)}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, c := range app.checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.check(ctx)
+
+			result := checkResult{Status: healthOK, DurationMS: float64(time.Since(start).Microseconds()) / 1000}
+			if err != nil {
+				result.Status = healthError
+				result.Error = c.failure
+				app.logger.WarnContext(r.Context(), "readiness check failed", "check", c.name, "error", err)
+			}
+
+			mu.Lock()
+			response.Checks[c.name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if app.shuttingDown.Load() {
+		response.Checks["shutdown"] = checkResult{Status: healthError, Error: errShuttingDown.Error()}
+	}
+
+	status := http.StatusOK
+	for _, result := range response.Checks {
+		if result.Status != healthOK {
+			response.Status = healthUnavailable
+			status = http.StatusServiceUnavailable
+		}
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	_ = app.writeJSON(w, status, response)
+}
+
+// readinessChecks returns the checks run by /readyz: the database answers a ping, its schema
+// is up to date, and covers can be written to the static directory. ping is nil when the data
+// is kept in memory, which leaves only the last check
+func (app *application) readinessChecks(ping func(ctx context.Context) error) []healthCheck {
+	var checks []healthCheck
+	if ping != nil {
+		checks = append(checks,
+			healthCheck{name: "database", check: ping, failure: "the database cannot be reached"},
+			healthCheck{name: "migrations", check: app.checkMigrations, failure: "the database schema is not up to date"},
+		)
+	}
+
+	return append(checks, healthCheck{name: "static_path", check: app.checkCoversWritable, failure: "covers cannot be written"})
+}
+
+// checkMigrations makes sure the database has every migration this binary knows about applied
+func (app *application) checkMigrations(ctx context.Context) error {
+	version, err := app.migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+
+	if version != app.migrator.Latest() {
+		return fmt.Errorf("the schema is at version %d, expected %d", version, app.migrator.Latest())
+	}
+
+	return nil
+}
+
+// checkCoversWritable makes sure a file can be created in the covers directory
+func (app *application) checkCoversWritable(ctx context.Context) error {
+	f, err := os.CreateTemp(filepath.Join(app.config.staticPath, "covers"), ".readyz-*")
+	if err != nil {
+		return err
+	}
+
+	name := f.Name()
+	f.Close()
+
+	return os.Remove(name)
+}
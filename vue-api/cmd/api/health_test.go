@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func okCheck(context.Context) error { return nil }
+
+func failingCheck(context.Context) error { return errors.New("connection refused") }
+
+func readyzResponse(t *testing.T, app *application) (int, healthResponse) {
+	t.Helper()
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/readyz", nil)
+	app.Readyz(rr, req)
+
+	var response healthResponse
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	return rr.Code, response
+}
+
+func Test_Healthz(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/healthz", nil)
+
+	testApp.Healthz(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Errorf("expected status 200 but got %d", rr.Code)
+	}
+}
+
+func Test_Readyz(t *testing.T) {
+	var tests = []struct {
+		name         string
+		checks       []healthCheck
+		shuttingDown bool
+		status       int
+		failed       []string
+	}{
+		{"all passing", []healthCheck{{"database", okCheck, "down"}, {"static_path", okCheck, "unwritable"}}, false, http.StatusOK, nil},
+		{"database down", []healthCheck{{"database", failingCheck, "down"}, {"static_path", okCheck, "unwritable"}}, false, http.StatusServiceUnavailable, []string{"database"}},
+		{"shutting down", []healthCheck{{"database", okCheck, "down"}}, true, http.StatusServiceUnavailable, []string{"shutdown"}},
+	}
+
+	for _, e := range tests {
+		app := newServerTestApp(time.Second)
+		app.checks = e.checks
+		app.shuttingDown.Store(e.shuttingDown)
+
+		status, response := readyzResponse(t, app)
+
+		if status != e.status {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.status, status)
+		}
+
+		for _, name := range e.failed {
+			if response.Checks[name].Status != healthError || response.Checks[name].Error == "" {
+				t.Errorf("%s: expected %s to fail, got %+v", e.name, name, response.Checks)
+			}
+			if strings.Contains(response.Checks[name].Error, "connection refused") {
+				t.Errorf("%s: expected the error of %s to be kept from the client, got %q", e.name, name, response.Checks[name].Error)
+			}
+		}
+
+		if e.status == http.StatusOK && response.Status != healthOK {
+			t.Errorf("%s: expected status ok but got %s", e.name, response.Status)
+		}
+		if e.status != http.StatusOK && response.Status != healthUnavailable {
+			t.Errorf("%s: expected status unavailable but got %s", e.name, response.Status)
+		}
+	}
+}
+
+func Test_checkCoversWritable(t *testing.T) {
+	app := newServerTestApp(time.Second)
+	app.config.staticPath = t.TempDir()
+
+	if err := app.checkCoversWritable(context.Background()); err == nil {
+		t.Error("expected an error without a covers directory")
+	}
+
+	covers := filepath.Join(app.config.staticPath, "covers")
+	if err := os.Mkdir(covers, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.checkCoversWritable(context.Background()); err != nil {
+		t.Errorf("expected the covers directory to be writable, got %v", err)
+	}
+
+	if entries, _ := os.ReadDir(covers); len(entries) != 0 {
+		t.Errorf("expected the check to clean up after itself, found %d file(s)", len(entries))
+	}
+}
+
+func Test_serveUntilDone_readinessFailsWhileDraining(t *testing.T) {
+	app := newServerTestApp(5 * time.Second)
+	app.config.server.drainDelay = 500 * time.Millisecond
+	app.checks = []healthCheck{{"database", okCheck, "down"}}
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serveUntilDone(ctx, boundServer{srv: app.newServer(http.HandlerFunc(app.Readyz)), ln: ln})
+	}()
+
+	get := func() int {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	if status := get(); status != http.StatusOK {
+		t.Errorf("expected to be ready before shutting down, got %d", status)
+	}
+
+	cancel()
+
+	// the server keeps accepting connections during the drain delay, but is no longer ready
+	deadline := time.Now().Add(app.config.server.drainDelay / 2)
+	for !app.shuttingDown.Load() && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if status := get(); status != http.StatusServiceUnavailable {
+		t.Errorf("expected readiness to fail while draining, got %d", status)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown but got %v", err)
+	}
+}
@@ -3,9 +3,13 @@ package main
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"vue-api/internal/data"
 )
 
 func (app *application) readJSON(w http.ResponseWriter, r *http.Request, data interface{}) error {
@@ -16,12 +20,12 @@ func (app *application) readJSON(w http.ResponseWriter, r *http.Request, data in
 	dec := json.NewDecoder(r.Body)
 	err := dec.Decode(data)
 	if err != nil {
-		return err
+		return badRequest(codeInvalidJSON, err)
 	}
 
 	err = dec.Decode(&struct{}{})
 	if err != io.EOF {
-		return errors.New("body must have only a single json value ")
+		return badRequest(codeInvalidJSON, errors.New("body must have only a single json value"))
 	}
 
 	return nil
@@ -50,7 +54,9 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data interf
 		}
 	}
 
-	w.Header().Set("Content-Type", "application/json")
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "application/json")
+	}
 	w.WriteHeader(status)
 
 	_, err := w.Write(output)
@@ -61,35 +67,72 @@ func (app *application) writeJSON(w http.ResponseWriter, status int, data interf
 	return nil
 }
 
-// errorJSON takes an error, and optionally a response status code, and generates and sends a json error response
-func (app *application) errorJSON(w http.ResponseWriter, err error, status ...int) error {
-	statusCode := http.StatusBadRequest
+// errorJSON takes an error, and optionally a response status code, and sends it as an RFC 7807
+// problem+json response in the language of the request. Errors that are not recognised are sent
+// as an internal error, without saying what went wrong, unless the status code is that of a
+// client error
+func (app *application) errorJSON(w http.ResponseWriter, r *http.Request, err error, status ...int) error {
+	statusCode := http.StatusInternalServerError
 
 	if len(status) > 0 {
 		statusCode = status[0]
 	}
 
-	var customErr error
-
-	switch {
-	case strings.Contains(err.Error(), "SQLSTATE 23505"):
-		customErr = errors.New("duplicate value violates unique constraint")
-		statusCode = http.StatusForbidden
-	case strings.Contains(err.Error(), "SQLSTATE 22001"):
-		customErr = errors.New("the value you are trying to insert is too large")
-		statusCode = http.StatusForbidden
-	case strings.Contains(err.Error(), "SQLSTATE 23503"):
-		customErr = errors.New("foreign key violation")
-		statusCode = http.StatusForbidden
-	default:
-		customErr = err
+	payload, headers := problemFor(printerFor(r), err, statusCode)
+	headers.Set("Content-Type", problemContentType)
+
+	// client errors are part of normal operation, so they are only logged when debugging
+	level := slog.LevelDebug
+	if payload.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
 	}
+	app.logger.Log(r.Context(), level, "request failed", "code", payload.Code, "error", err)
 
-	var payload jsonResponse
-	payload.Error = true
-	payload.Message = customErr.Error()
+	return app.writeJSON(w, payload.Status, payload, headers)
+}
 
-	app.writeJSON(w, statusCode, payload)
+// authenticatedUser returns the user stored in the request context by AuthTokenMiddleware,
+// or nil if the request did not go through that middleware
+func (app *application) authenticatedUser(r *http.Request) *data.User {
+	user, ok := r.Context().Value(contextKeyUser).(*data.User)
+	if !ok {
+		return nil
+	}
+	return user
+}
 
-	return nil
+// authenticatedUserID returns the id of the user making the request, or 0 if unknown
+func (app *application) authenticatedUserID(r *http.Request) int {
+	user := app.authenticatedUser(r)
+	if user == nil {
+		return 0
+	}
+	return user.ID
+}
+
+// etag returns the entity tag sent to clients for a record at the given version
+func etag(version int) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// expectedVersion returns the version of a record the client based its edit on. An If-Match
+// header takes precedence over the version sent in the payload; 0 means the client did not
+// send one (or sent If-Match: *), and the edit should be applied unconditionally
+func (app *application) expectedVersion(r *http.Request, payloadVersion int) (int, error) {
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" {
+		return payloadVersion, nil
+	}
+
+	if ifMatch == "*" {
+		return 0, nil
+	}
+
+	tag := strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	version, err := strconv.Atoi(tag)
+	if err != nil || version < 1 {
+		return 0, errInvalidIfMatch
+	}
+
+	return version, nil
 }
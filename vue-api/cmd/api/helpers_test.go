@@ -2,11 +2,17 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"database/sql"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"vue-api/internal/data"
+
+	"github.com/jackc/pgconn"
 )
 
 func Test_readJSON(t *testing.T) {
@@ -63,26 +69,67 @@ func Test_writeJSON(t *testing.T) {
 }
 
 func Test_errorJSON(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+
 	rr := httptest.NewRecorder()
-	err := testApp.errorJSON(rr, errors.New("new error."))
+	err := testApp.errorJSON(rr, req, errors.New("new error."))
 	if err != nil {
 		t.Error(err)
 	}
 
 	testJSONPayload(t, rr)
 
-	errSlice := []string{
-		"(SQLSTATE 23505)",
-		"(SQLSTATE 22001)",
-		"(SQLSTATE 23503)",
+	var tests = []struct {
+		name           string
+		err            error
+		status         []int
+		expectedStatus int
+		expectedCode   string
+	}{
+		{"plain error", errors.New("new error."), nil, http.StatusInternalServerError, codeInternal},
+		{"plain error with status", errors.New("nope"), []int{http.StatusUnauthorized}, http.StatusUnauthorized, codeUnauthorized},
+		{"plain error with server status", errors.New("nope"), []int{http.StatusBadGateway}, http.StatusInternalServerError, codeInternal},
+		{"duplicate", data.ErrDuplicate, nil, http.StatusForbidden, codeDuplicateValue},
+		{"too long", fmt.Errorf("insert: %w", data.ErrTooLong), nil, http.StatusForbidden, codeValueTooLong},
+		{"invalid reference", data.ErrInvalidReference, []int{http.StatusUnauthorized}, http.StatusForbidden, codeForeignKeyViolation},
+		{"other postgres error", &pgconn.PgError{Code: "42P01", Message: "no table"}, nil, http.StatusInternalServerError, codeInternal},
+		{"not found", sql.ErrNoRows, nil, http.StatusNotFound, codeNotFound},
+		{"in use", data.ErrInUse, nil, http.StatusConflict, codeInUse},
+		{"not in trash", data.ErrNotInTrash, nil, http.StatusNotFound, codeNotInTrash},
+		{"unsupported patch", errUnsupportedPatch, nil, http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"invalid credentials", errInvalidCredentials, nil, http.StatusBadRequest, codeInvalidCredentials},
+		{"invalid json", badRequest(codeInvalidJSON, errors.New("unexpected EOF")), nil, http.StatusBadRequest, codeInvalidJSON},
+		{"validation", &validationError{errors: map[string][]string{"title": {"must be provided"}}}, nil, http.StatusUnprocessableEntity, codeValidationFailed},
 	}
 
-	for _, x := range errSlice {
-		customErr := testApp.errorJSON(rr, errors.New(x), http.StatusUnauthorized)
-		if customErr != nil {
-			t.Error(customErr)
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		_ = testApp.errorJSON(rr, req, e.err, e.status...)
+
+		if rr.Code != e.expectedStatus {
+			t.Errorf("%s: expected status %d but got %d", e.name, e.expectedStatus, rr.Code)
+		}
+
+		if rr.Header().Get("Content-Type") != problemContentType {
+			t.Errorf("%s: expected content type %s but got %s", e.name, problemContentType, rr.Header().Get("Content-Type"))
+		}
+
+		var p problem
+		if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+			t.Fatalf("%s: %v", e.name, err)
+		}
+
+		if p.Code != e.expectedCode || p.Type != problemTypeBase+e.expectedCode {
+			t.Errorf("%s: expected code %s but got %s (type %s)", e.name, e.expectedCode, p.Code, p.Type)
+		}
+
+		if p.Status != rr.Code || p.Title == "" || p.Message == "" || !p.Error {
+			t.Errorf("%s: incomplete problem %+v", e.name, p)
+		}
+
+		if p.Code == codeInternal && (p.Detail != "" || p.Message != p.Title) {
+			t.Errorf("%s: expected what went wrong to be kept from the client, got %+v", e.name, p)
 		}
-		testJSONPayload(t, rr)
 	}
 }
 
@@ -99,3 +146,82 @@ func testJSONPayload(t *testing.T, rr *httptest.ResponseRecorder) {
 	}
 
 }
+
+func Test_authenticatedUser(t *testing.T) {
+	req, _ := http.NewRequest("POST", "/", nil)
+
+	if testApp.authenticatedUserID(req) != 0 {
+		t.Error("expected user id 0 for a request without a user")
+	}
+
+	user := &data.User{ID: 7}
+	req = req.WithContext(context.WithValue(req.Context(), contextKeyUser, user))
+
+	if testApp.authenticatedUser(req) != user {
+		t.Error("did not get the user stored in the request context")
+	}
+
+	if testApp.authenticatedUserID(req) != 7 {
+		t.Error("wrong user id returned for authenticated request")
+	}
+}
+
+func Test_expectedVersion(t *testing.T) {
+	var tests = []struct {
+		name           string
+		ifMatch        string
+		payloadVersion int
+		expected       int
+		errorExpected  bool
+	}{
+		{"no header", "", 4, 4, false},
+		{"strong etag", `"7"`, 4, 7, false},
+		{"weak etag", `W/"7"`, 0, 7, false},
+		{"wildcard", "*", 4, 0, false},
+		{"garbage", `"abc"`, 4, 0, true},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("POST", "/", nil)
+		if e.ifMatch != "" {
+			req.Header.Set("If-Match", e.ifMatch)
+		}
+
+		version, err := testApp.expectedVersion(req, e.payloadVersion)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s: expected an error but did not get one", e.name)
+		}
+
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s: unexpected error: %v", e.name, err)
+		}
+
+		if version != e.expected {
+			t.Errorf("%s: expected version %d but got %d", e.name, e.expected, version)
+		}
+	}
+}
+
+func Test_editConflict(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	rr := httptest.NewRecorder()
+	_ = testApp.errorJSON(rr, req, &conflictError{name: "book", current: data.Book{ID: 1, Version: 3}, version: 3})
+
+	if rr.Code != http.StatusConflict {
+		t.Errorf("expected status 409 but got %d", rr.Code)
+	}
+
+	if rr.Header().Get("ETag") != `"3"` {
+		t.Errorf("expected ETag \"3\" but got %s", rr.Header().Get("ETag"))
+	}
+
+	var p problem
+	if err := json.NewDecoder(rr.Body).Decode(&p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Code != codeEditConflict || p.Data == nil {
+		t.Errorf("expected an edit_conflict problem holding the current book, got %+v", p)
+	}
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+)
+
+// trashPurgeInterval is how often we look for deleted records that are older than the retention period
+const trashPurgeInterval = time.Hour
+
+// purgeTrashEvery runs purgeTrash immediately, and then once every interval until ctx is cancelled
+func (app *application) purgeTrashEvery(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		app.purgeTrash(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// purgeTrash permanently removes books and users that have been in the trash for
+// longer than the configured retention period, and the covers of the books removed
+func (app *application) purgeTrash(ctx context.Context) {
+	before := time.Now().Add(-app.config.trashRetention)
+
+	slugs, err := app.models.Book.PurgeDeleted(ctx, before)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "could not purge books from trash", "error", err)
+	} else if len(slugs) > 0 {
+		app.logger.InfoContext(ctx, "purged books from trash", "count", len(slugs))
+		app.removePurgedCovers(ctx, slugs)
+	}
+
+	users, err := app.models.User.PurgeDeleted(ctx, before)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "could not purge users from trash", "error", err)
+	} else if users > 0 {
+		app.logger.InfoContext(ctx, "purged users from trash", "count", users)
+	}
+}
+
+// removePurgedCovers removes the covers of the books with the given slugs, once the books have
+// been purged. Slugs are not unique, so a cover is kept while any other book, in the catalogue or
+// in the trash, still has its slug
+func (app *application) removePurgedCovers(ctx context.Context, slugs []string) {
+	live, err := app.models.Book.GetAll(ctx)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "could not list books to remove the covers of purged ones", "error", err)
+		return
+	}
+	trashed, err := app.models.Book.GetAllDeleted(ctx)
+	if err != nil {
+		app.logger.ErrorContext(ctx, "could not list books to remove the covers of purged ones", "error", err)
+		return
+	}
+
+	used := make(map[string]bool)
+	for _, b := range append(live, trashed...) {
+		used[b.Slug] = true
+	}
+
+	for _, slug := range slugs {
+		if used[slug] {
+			continue
+		}
+
+		err := app.removeCover(ctx, slug+".jpg")
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			app.logger.ErrorContext(ctx, "could not remove the cover of a purged book", "slug", slug, "error", err)
+		}
+	}
+}
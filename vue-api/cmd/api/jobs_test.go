@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+)
+
+func Test_purgeTrash(t *testing.T) {
+	app, _ := newMemoryTestApp(t)
+	ctx := context.Background()
+
+	books, err := app.models.Book.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trashed, kept := books[0], books[1]
+
+	for _, b := range books[:2] {
+		if err := app.writeCover(ctx, b.Slug, []byte("cover")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := app.models.Book.DeleteByID(ctx, trashed.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	app.config.trashRetention = 0
+	app.purgeTrash(ctx)
+
+	if err := app.models.Book.Restore(ctx, trashed.ID); err == nil {
+		t.Error("expected the book to be purged")
+	}
+	if _, err := os.Stat(app.coverPath(trashed.Slug)); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the cover of the purged book to be removed, got %v", err)
+	}
+	if _, err := os.Stat(app.coverPath(kept.Slug)); err != nil {
+		t.Errorf("expected the cover of a live book to be kept, got %v", err)
+	}
+}
+
+func Test_removePurgedCovers_sharedSlug(t *testing.T) {
+	app, _ := newMemoryTestApp(t)
+	ctx := context.Background()
+
+	books, err := app.models.Book.GetAll(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book := books[0]
+
+	if err := app.writeCover(ctx, book.Slug, []byte("cover")); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.models.Book.DeleteByID(ctx, book.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// another book with the same slug was purged, while this one is still in the trash
+	app.removePurgedCovers(ctx, []string{book.Slug})
+
+	if _, err := os.Stat(app.coverPath(book.Slug)); err != nil {
+		t.Errorf("expected the cover of a book in the trash to be kept, got %v", err)
+	}
+}
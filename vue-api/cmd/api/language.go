@@ -0,0 +1,31 @@
+package main
+
+import (
+	"net/http"
+	"vue-api/internal/data"
+	"vue-api/internal/i18n"
+)
+
+// printerFor returns the printer for messages sent in response to r. The language is the
+// authenticated user's preference, if they have set one; otherwise it is negotiated from the
+// Accept-Language header, falling back to i18n.Fallback
+func printerFor(r *http.Request) i18n.Printer {
+	user, _ := r.Context().Value(contextKeyUser).(*data.User)
+	return printerForUser(r, user)
+}
+
+// printerForUser is printerFor for a user who is not (yet) stored in the request context, such
+// as one who is logging in
+func printerForUser(r *http.Request, user *data.User) i18n.Printer {
+	preferred := ""
+	if user != nil {
+		preferred = user.Language
+	}
+
+	return i18n.NewPrinter(i18n.Match(preferred, r.Header.Get("Accept-Language")))
+}
+
+// translate returns the message with the given key in the language of the request
+func (app *application) translate(r *http.Request, key string, args ...interface{}) string {
+	return printerFor(r).Sprintf(key, args...)
+}
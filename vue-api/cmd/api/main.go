@@ -1,66 +1,135 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"log"
-	"net/http"
+	"log/slog"
 	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"vue-api/internal/data"
-	"vue-api/internal/driver"
+	"vue-api/internal/i18n"
+	"vue-api/internal/migrate"
 )
 
-// config is the type for all application configuration
-type config struct {
-	port int
-}
-
 type application struct {
 	config      config
-	infoLog     *log.Logger
-	errorLog    *log.Logger
+	logger      *slog.Logger
+	metrics     *metrics
 	models      data.Models
+	migrator    *migrate.Migrator
 	environment string
+	wg          sync.WaitGroup
+
+	// checks are run by /readyz, which also fails once shuttingDown is set
+	checks       []healthCheck
+	shuttingDown atomic.Bool
 }
 
 // main is the main entry point for our application
 func main() {
-	var cfg config
-	cfg.port = 8082
+	cfg, args, err := loadConfig(os.Args[1:], os.Getenv)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// there is no configured logger yet, so this goes to the default one
+		slog.Error("invalid configuration", "error", err)
+		os.Exit(1)
+	}
+
+	logger := newLogger(os.Stdout, cfg.env, cfg.logLevel)
+	slog.SetDefault(logger)
 
-	infoLog := log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime)
-	errorLog := log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime|log.Lshortfile)
+	i18n.Fallback = cfg.defaultLanguage
+	data.SetTimeout(cfg.db.timeout)
 
-	dsn := os.Getenv("DSN")
-	environment := os.Getenv("ENV")
+	shutdownTracing, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		logger.Error("cannot set up tracing", "error", err)
+		os.Exit(1)
+	}
+	// flushTracing exports the spans not sent yet; os.Exit skips deferred calls, so it is called
+	// by hand on the way out
+	flushTracing := func() {
+		ctx, cancel := context.WithTimeout(context.Background(), cfg.server.shutdownTimeout)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("could not flush traces", "error", err)
+		}
+	}
 
-	db, err := driver.ConnectPostgres(dsn)
+	store, err := openStorage(cfg)
 	if err != nil {
-		log.Fatal("Cannot connect to database")
+		logger.Error("cannot connect to database", "error", err)
+		os.Exit(1)
 	}
-	defer db.SQL.Close()
 
 	app := &application{
 		config:      cfg,
-		infoLog:     infoLog,
-		errorLog:    errorLog,
-		models:      data.New(db.SQL),
-		environment: environment,
+		logger:      logger,
+		metrics:     newMetrics(store.db, cfg.db.driver),
+		models:      store.models,
+		migrator:    store.migrator,
+		environment: cfg.env,
 	}
+	app.checks = app.readinessChecks(store.ping())
 
-	err = app.serve()
-	if err != nil {
-		log.Fatal(err)
+	// SIGTERM is what the Makefile's stop target, and most process managers, send
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if len(args) > 0 {
+		err := app.runCommand(ctx, args, os.Stdout)
+		store.Close()
+		flushTracing()
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			if errors.Is(err, errUsage) {
+				os.Exit(2)
+			}
+			os.Exit(1)
+		}
+		return
 	}
-}
 
-// serve starts the web server
-func (app *application) serve() error {
-	app.infoLog.Println("API listening on port:", app.config.port)
+	logger.Info("effective configuration", "settings", cfg.describe())
+
+	if cfg.db.driver == "memory" {
+		if err := app.seedMemory(ctx); err != nil {
+			logger.Error("cannot seed the in-memory store", "error", err)
+			os.Exit(1)
+		}
+	} else if cfg.db.autoMigrate {
+		run, err := app.migrator.Up(ctx)
+		for _, m := range run {
+			logger.Info("applied migration", "version", m.Version, "name", m.Name)
+		}
+		if err != nil {
+			logger.Error("cannot migrate the database", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	app.background(func() {
+		app.purgeTrashEvery(ctx, trashPurgeInterval)
+	})
+
+	err = app.serve(ctx)
 
-	srv := &http.Server{
-		Addr:    fmt.Sprintf(":%d", app.config.port),
-		Handler: app.routes(),
+	if closeErr := store.Close(); closeErr != nil {
+		logger.Error("could not close the database pool", "error", closeErr)
+	}
+	flushTracing()
+
+	if err != nil {
+		logger.Error("server stopped with an error", "error", err)
+		os.Exit(1)
 	}
 
-	return srv.ListenAndServe()
+	logger.Info("stopped")
 }
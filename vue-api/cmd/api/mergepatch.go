@@ -0,0 +1,103 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+)
+
+// mergePatchContentType is the media type of a JSON Merge Patch document (RFC 7396)
+const mergePatchContentType = "application/merge-patch+json"
+
+// errUnsupportedPatch is returned when a PATCH request is not sent as a merge patch
+var errUnsupportedPatch = errors.New("PATCH requests must be sent as " + mergePatchContentType)
+
+// readMergePatch reads the body of a PATCH request, which must be a JSON object sent as
+// application/merge-patch+json (plain application/json is accepted too, for convenience)
+func (app *application) readMergePatch(w http.ResponseWriter, r *http.Request) ([]byte, error) {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || (mediaType != mergePatchContentType && mediaType != "application/json") {
+		return nil, errUnsupportedPatch
+	}
+
+	maxBytes := 1048576 //one megabyte
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, int64(maxBytes)))
+	if err != nil {
+		return nil, badRequest(codeInvalidJSON, err)
+	}
+
+	var patch map[string]json.RawMessage
+	if err := json.Unmarshal(body, &patch); err != nil || patch == nil {
+		return nil, badRequest(codeInvalidJSON, errors.New("merge patch must be a json object"))
+	}
+
+	return body, nil
+}
+
+// applyMergePatch applies a merge patch to a JSON document, as described in RFC 7396: members
+// of the patch replace members of the document, members set to null are removed, and objects
+// are merged recursively. Anything absent from the patch is left as it is
+func applyMergePatch(document, patch []byte) ([]byte, error) {
+	var doc, p interface{}
+
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(patch, &p); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(mergeValue(doc, p))
+}
+
+// mergeValue implements the MergePatch function from section 2 of RFC 7396
+func mergeValue(target, patch interface{}) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+			continue
+		}
+		targetObject[name] = mergeValue(targetObject[name], value)
+	}
+
+	return targetObject
+}
+
+// patchDocument applies a merge patch to current, a value holding every patchable field of a
+// record, and decodes the result into dst. Fields removed by the patch (set to null) are left at
+// their zero value in dst, and fields dst does not know about are rejected
+func patchDocument(current interface{}, patch []byte, dst interface{}) error {
+	document, err := json.Marshal(current)
+	if err != nil {
+		return err
+	}
+
+	merged, err := applyMergePatch(document, patch)
+	if err != nil {
+		return badRequest(codeInvalidJSON, err)
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(merged))
+	dec.DisallowUnknownFields()
+
+	// current was encoded by the api itself, so anything wrong here came with the patch
+	if err := dec.Decode(dst); err != nil {
+		return badRequest(codeInvalidJSON, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func Test_applyMergePatch(t *testing.T) {
+	// the examples from appendix A of RFC 7396
+	var tests = []struct {
+		original string
+		patch    string
+		expected string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"a":1,"e":null}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, e := range tests {
+		result, err := applyMergePatch([]byte(e.original), []byte(e.patch))
+		if err != nil {
+			t.Errorf("%s + %s: unexpected error %v", e.original, e.patch, err)
+			continue
+		}
+
+		if !jsonEqual(t, result, []byte(e.expected)) {
+			t.Errorf("%s + %s: expected %s but got %s", e.original, e.patch, e.expected, result)
+		}
+	}
+}
+
+func Test_patchDocument(t *testing.T) {
+	current := bookInput{Title: "My Book", Description: "Old", GenreIDs: []int{1, 2}, Version: 3}
+
+	var patched bookInput
+	err := patchDocument(current, []byte(`{"description":"New","genre_ids":null}`), &patched)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if patched.Title != "My Book" {
+		t.Error("absent field was not left as it was")
+	}
+
+	if patched.Description != "New" {
+		t.Error("present field was not replaced")
+	}
+
+	if patched.GenreIDs != nil {
+		t.Error("null field was not removed")
+	}
+
+	err = patchDocument(current, []byte(`{"slug":"my-book"}`), &patched)
+	if err == nil {
+		t.Error("expected an error patching an unknown field")
+	}
+}
+
+func Test_readMergePatch(t *testing.T) {
+	var tests = []struct {
+		contentType   string
+		body          string
+		errorExpected bool
+	}{
+		{mergePatchContentType, `{"title":"x"}`, false},
+		{"application/json; charset=utf-8", `{"title":"x"}`, false},
+		{"text/plain", `{"title":"x"}`, true},
+		{mergePatchContentType, `["title"]`, true},
+		{mergePatchContentType, `null`, true},
+	}
+
+	for _, e := range tests {
+		req, _ := http.NewRequest("PATCH", "/", bytes.NewReader([]byte(e.body)))
+		req.Header.Set("Content-Type", e.contentType)
+
+		_, err := testApp.readMergePatch(httptest.NewRecorder(), req)
+		if e.errorExpected && err == nil {
+			t.Errorf("%s %s: expected an error", e.contentType, e.body)
+		}
+		if !e.errorExpected && err != nil {
+			t.Errorf("%s %s: unexpected error %v", e.contentType, e.body, err)
+		}
+	}
+}
+
+func jsonEqual(t *testing.T, a, b []byte) bool {
+	var x, y interface{}
+	if err := json.Unmarshal(a, &x); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, &y); err != nil {
+		t.Fatal(err)
+	}
+
+	ax, _ := json.Marshal(x)
+	by, _ := json.Marshal(y)
+	return bytes.Equal(ax, by)
+}
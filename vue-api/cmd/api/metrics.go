@@ -0,0 +1,136 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// metricsNamespace prefixes the name of every metric the api exports
+const metricsNamespace = "vueapi"
+
+// unmatchedRoute is the route label of requests which did not match any route, so that scans
+// for random paths cannot blow up the number of series
+const unmatchedRoute = "unmatched"
+
+// results of a login attempt, as counted by metrics.logins
+const (
+	loginSuccess            = "success"
+	loginInvalidRequest     = "invalid_request"
+	loginInvalidCredentials = "invalid_credentials"
+	loginInactive           = "inactive"
+	loginError              = "error"
+)
+
+// metrics holds the prometheus collectors of the api, registered on a registry of its own
+// rather than the global one, so that tests can build as many as they like
+type metrics struct {
+	registry *prometheus.Registry
+
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+	logins           *prometheus.CounterVec
+	coverSize        prometheus.Histogram
+}
+
+// newMetrics creates and registers the collectors. The statistics of the connection pool db are
+// labelled with dbName, the driver in use; db may be nil, in which case none are exported
+func newMetrics(db *sql.DB, dbName string) *metrics {
+	m := &metrics{
+		registry: prometheus.NewRegistry(),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "Time taken to serve http requests, by route pattern, method and status.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method", "status"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Subsystem: "http",
+			Name:      "requests_in_flight",
+			Help:      "Number of http requests being served.",
+		}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "logins_total",
+			Help:      "Login attempts, by result.",
+		}, []string{"result"}),
+		coverSize: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "cover_upload_bytes",
+			Help:      "Size of uploaded book covers, once decoded.",
+			// 16KiB up to 8MiB
+			Buckets: prometheus.ExponentialBuckets(16*1024, 2, 10),
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.requestsInFlight,
+		m.logins,
+		m.coverSize,
+	)
+
+	if db != nil {
+		m.registry.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+	}
+
+	// every result shows up as 0 before the first login, so that rates can be taken at once
+	for _, result := range []string{loginSuccess, loginInvalidRequest, loginInvalidCredentials, loginInactive, loginError} {
+		m.logins.WithLabelValues(result)
+	}
+
+	return m
+}
+
+// handler serves the metrics in the prometheus text format
+func (m *metrics) handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// login counts a login attempt
+func (m *metrics) login(result string) {
+	m.logins.WithLabelValues(result).Inc()
+}
+
+// coverUploaded records the size of an uploaded cover
+func (m *metrics) coverUploaded(size int) {
+	m.coverSize.Observe(float64(size))
+}
+
+// instrument times every request. It must be used on the chi router, since the route label is
+// the pattern the request matched, such as /api/v1/books/{id}, and not the path itself
+func (app *application) instrument(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		app.metrics.requestsInFlight.Inc()
+		defer func() {
+			app.metrics.requestsInFlight.Dec()
+
+			// the pattern is only complete once the request has been routed
+			route := routePattern(r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+
+			app.metrics.requestDuration.
+				WithLabelValues(route, r.Method, strconv.Itoa(status)).
+				Observe(time.Since(start).Seconds())
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
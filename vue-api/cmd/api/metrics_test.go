@@ -0,0 +1,107 @@
+package main
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func Test_metrics(t *testing.T) {
+	// a fresh set of metrics, so that counts are not mixed up with those of other tests
+	app := &application{
+		config:  testApp.config,
+		logger:  testApp.logger,
+		models:  testApp.models,
+		metrics: newMetrics(nil, ""),
+	}
+	routes := app.routes()
+
+	var tests = []struct {
+		method string
+		target string
+		body   string
+		status int
+	}{
+		{"POST", "/users/login", "not json", http.StatusBadRequest},
+		{"POST", "/users/login", `{}`, http.StatusUnprocessableEntity},
+		{"GET", "/no/such/page", "", http.StatusNotFound},
+		{"GET", "/no/other/page", "", http.StatusNotFound},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest(e.method, e.target, strings.NewReader(e.body))
+		routes.ServeHTTP(rr, req)
+
+		if rr.Code != e.status {
+			t.Errorf("%s %s: expected status %d but got %d", e.method, e.target, e.status, rr.Code)
+		}
+	}
+
+	if n := testutil.ToFloat64(app.metrics.logins.WithLabelValues(loginInvalidRequest)); n != 2 {
+		t.Errorf("expected two invalid login requests to be counted, got %v", n)
+	}
+
+	if n := testutil.ToFloat64(app.metrics.logins.WithLabelValues(loginSuccess)); n != 0 {
+		t.Errorf("expected no successful logins, got %v", n)
+	}
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	routes.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 from /metrics but got %d", rr.Code)
+	}
+
+	body := rr.Body.String()
+	for _, expected := range []string{
+		`vueapi_http_request_duration_seconds_count{method="POST",route="/users/login",status="400"} 1`,
+		`vueapi_http_request_duration_seconds_count{method="POST",route="/users/login",status="422"} 1`,
+		`vueapi_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 2`,
+		`vueapi_logins_total{result="invalid_request"} 2`,
+		`vueapi_cover_upload_bytes_count 0`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Errorf("expected %s in the metrics", expected)
+		}
+	}
+
+	if strings.Contains(body, "/no/such/page") {
+		t.Error("raw paths should not be used as labels")
+	}
+}
+
+func Test_newMetrics_dbStats(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+
+	testApp.metrics.handler().ServeHTTP(rr, req)
+
+	for _, expected := range []string{"go_sql_max_open_connections", "go_sql_in_use_connections", "go_sql_wait_count_total"} {
+		if !strings.Contains(rr.Body.String(), expected) {
+			t.Errorf("expected the pool statistic %s in the metrics", expected)
+		}
+	}
+}
+
+func Test_newMetrics_dbName(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/metrics", nil)
+	newMetrics(db, "sqlite").handler().ServeHTTP(rr, req)
+
+	if body := rr.Body.String(); !strings.Contains(body, `go_sql_max_open_connections{db_name="sqlite"}`) {
+		t.Errorf("expected the pool statistics to be labelled with the driver, got %s", body)
+	}
+}
@@ -1,18 +1,132 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"runtime/debug"
+	"time"
+
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+type contextKey string
+
+// contextKeyUser is the request context key under which AuthTokenMiddleware stores the authenticated user
+const contextKeyUser = contextKey("user")
+
+// contextKeyRequestID is the request context key under which requestID stores the id of the request
+const contextKeyRequestID = contextKey("request_id")
+
+// requestIDHeader carries the id of a request, both ways
+const requestIDHeader = "X-Request-ID"
+
+// requestIDRX matches the request ids we accept from clients and proxies; anything else is
+// replaced, so that ids are safe to log and to send back
+var requestIDRX = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,64}$`)
 
 func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		_, err := app.models.Token.AuthenticateToken(r)
+		user, err := app.models.AuthenticateToken(r)
 		if err != nil {
-			payload := jsonResponse{
-				Error:   true,
-				Message: "invalid authentication credentials",
-			}
-			_ = app.writeJSON(w, http.StatusUnauthorized, payload)
+			app.errorJSON(w, r, errUnauthorized)
 			return
 		}
+
+		ctx := context.WithValue(r.Context(), contextKeyUser, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// requestID gives every request an id, which is sent back in the X-Request-ID header and added
+// to the logs and audit entries for the request. An id set by the client or a proxy is kept
+func (app *application) requestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(requestIDHeader)
+		if !requestIDRX.MatchString(id) {
+			id = newRequestID()
+		}
+
+		w.Header().Set(requestIDHeader, id)
+
+		ctx := context.WithValue(r.Context(), contextKeyRequestID, id)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// newRequestID returns a random id of 32 hex digits
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// requestIDFrom returns the id stored in ctx by requestID, or an empty string if there is none
+func requestIDFrom(ctx context.Context) string {
+	id, _ := ctx.Value(contextKeyRequestID).(string)
+	return id
+}
+
+// logRequests writes an access log entry for every request once it has been served. Server
+// errors are logged at the error level, so they stand out
+func (app *application) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			status := ww.Status()
+			if status == 0 {
+				// nothing was written, which net/http sends as 200 OK
+				status = http.StatusOK
+			}
+
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			app.logger.LogAttrs(r.Context(), level, "request",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.String("proto", r.Proto),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Float64("duration_ms", float64(time.Since(start).Microseconds())/1000),
+				slog.String("remote_ip", remoteIP(r)),
+			)
+		}()
+
+		next.ServeHTTP(ww, r)
+	})
+}
+
+// recoverPanic turns a panic in a handler into a 500 response, and logs it with its stack
+func (app *application) recoverPanic(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			rvr := recover()
+			if rvr == nil {
+				return
+			}
+			if rvr == http.ErrAbortHandler {
+				// the handler gave up on purpose, and net/http knows what to do about it
+				panic(rvr)
+			}
+
+			app.logger.ErrorContext(r.Context(), "panic serving request",
+				"panic", fmt.Sprint(rvr),
+				"stack", string(debug.Stack()),
+			)
+
+			w.Header().Set("Connection", "close")
+			app.errorJSON(w, r, errInternal)
+		}()
+
 		next.ServeHTTP(w, r)
 	})
 }
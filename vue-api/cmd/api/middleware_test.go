@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newLoggedTestApp returns an application which logs json to the returned buffer
+func newLoggedTestApp() (*application, *bytes.Buffer) {
+	var buf bytes.Buffer
+	app := &application{
+		config: defaultConfig(),
+		logger: newLogger(&buf, "production", slog.LevelDebug),
+	}
+	return app, &buf
+}
+
+// logLines decodes every json log line written to buf
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line is not json: %s", line)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func Test_requestID(t *testing.T) {
+	var tests = []struct {
+		name     string
+		incoming string
+		kept     bool
+	}{
+		{"none", "", false},
+		{"from proxy", "abc-123.4", true},
+		{"unsafe", "abc\ninjected", false},
+		{"too long", strings.Repeat("a", 65), false},
+	}
+
+	for _, e := range tests {
+		var seen string
+		handler := testApp.requestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			seen = requestIDFrom(r.Context())
+		}))
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", "/", nil)
+		if e.incoming != "" {
+			req.Header.Set(requestIDHeader, e.incoming)
+		}
+
+		handler.ServeHTTP(rr, req)
+
+		sent := rr.Header().Get(requestIDHeader)
+		if sent == "" || sent != seen {
+			t.Errorf("%s: expected the id in the context (%q) to be sent back, got %q", e.name, seen, sent)
+		}
+
+		if e.kept && sent != e.incoming {
+			t.Errorf("%s: expected %q to be kept but got %q", e.name, e.incoming, sent)
+		}
+
+		if !e.kept && sent == e.incoming {
+			t.Errorf("%s: expected %q to be replaced", e.name, e.incoming)
+		}
+	}
+}
+
+func Test_logRequests(t *testing.T) {
+	app, buf := newLoggedTestApp()
+
+	handler := app.requestID(app.logRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		app.logger.InfoContext(r.Context(), "inside handler")
+		w.WriteHeader(http.StatusTeapot)
+		_, _ = w.Write([]byte("short and stout"))
+	})))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/books?page=2", nil)
+	req.Header.Set(requestIDHeader, "req-1")
+
+	handler.ServeHTTP(rr, req)
+
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("expected two log lines but got %d: %s", len(lines), buf)
+	}
+
+	for _, line := range lines {
+		if line["request_id"] != "req-1" {
+			t.Errorf("expected the request id in every line, got %v", line)
+		}
+	}
+
+	access := lines[1]
+	if access["msg"] != "request" || access["method"] != "GET" || access["path"] != "/books" {
+		t.Errorf("unexpected access log entry: %v", access)
+	}
+
+	if access["status"] != float64(http.StatusTeapot) || access["bytes"] != float64(len("short and stout")) {
+		t.Errorf("expected the status and size in the access log, got %v", access)
+	}
+
+	if _, ok := access["duration_ms"].(float64); !ok {
+		t.Errorf("expected the latency in the access log, got %v", access)
+	}
+}
+
+func Test_recoverPanic(t *testing.T) {
+	app, buf := newLoggedTestApp()
+
+	handler := app.requestID(app.logRequests(app.recoverPanic(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("something broke")
+	}))))
+
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusInternalServerError {
+		t.Errorf("expected status 500 but got %d", rr.Code)
+	}
+
+	if strings.Contains(rr.Body.String(), "something broke") {
+		t.Errorf("the panic should not be sent to the client: %s", rr.Body)
+	}
+
+	var panicked, accessError bool
+	for _, line := range logLines(t, buf) {
+		if line["msg"] == "panic serving request" && line["panic"] == "something broke" && line["stack"] != "" {
+			panicked = true
+		}
+		if line["msg"] == "request" && line["level"] == "ERROR" {
+			accessError = true
+		}
+	}
+
+	if !panicked || !accessError {
+		t.Errorf("expected the panic and a failed request to be logged: %s", buf)
+	}
+}
+
+func Test_newLogger_text(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newLogger(&buf, "development", slog.LevelInfo)
+
+	logger.Debug("hidden")
+	logger.Info("shown", "count", 2)
+
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "msg=shown count=2") {
+		t.Errorf("expected text output at the info level, got %q", out)
+	}
+}
@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"vue-api/internal/data"
+)
+
+// The api is described by an OpenAPI 3 document, served at /openapi.json and browsable at /docs.
+// The operations are listed by hand in apiOperations, but the schemas of their bodies are derived
+// from the Go types the handlers read and write, so that they cannot drift apart. Every route in
+// routes() must have an entry; Test_openAPI_documentsEveryRoute fails otherwise
+
+const openAPIVersion = "3.0.3"
+
+// operation documents one method on one route of routes()
+type operation struct {
+	method  string
+	path    string // as given to chi, with parameters in braces
+	id      string // unique; the name of the handler, unless it serves more than one route
+	tag     string
+	summary string
+	auth    bool // whether a bearer token is required
+	query   []queryParam
+
+	// request is a value of the type of the body, and nil when there is none. requestType is
+	// its media type, when it is not application/json
+	request     interface{}
+	requestType string
+
+	// status is the status of a successful response, and response what it carries in the data
+	// member of a jsonResponse; nil when there is nothing. When raw is set, response is sent as
+	// it is, with the media type responseType if that is not application/json
+	status       int
+	response     interface{}
+	raw          bool
+	responseType string
+
+	// conditional is set on reads which answer If-None-Match with 304 Not Modified
+	conditional bool
+}
+
+// queryParam documents a query string parameter of an operation
+type queryParam struct {
+	name        string
+	kind        string // the OpenAPI type: integer, string and so on
+	format      string
+	description string
+}
+
+var paginationParams = []queryParam{
+	{name: "page", kind: "integer", description: "page to return, from 1; the whole list is returned when neither page nor page_size is given"},
+	{name: "page_size", kind: "integer", description: "number of items in a page, at most 100"},
+}
+
+var auditFilterParams = []queryParam{
+	{name: "actor_id", kind: "integer", description: "id of the user who made the change"},
+	{name: "action", kind: "string", description: "such as create, update or delete"},
+	{name: "entity", kind: "string", description: "kind of entity changed, such as book or user"},
+	{name: "entity_id", kind: "integer", description: "id of the entity changed"},
+	{name: "from", kind: "string", format: "date-time", description: "earliest time of a change, in RFC 3339 format"},
+	{name: "to", kind: "string", format: "date-time", description: "latest time of a change, in RFC 3339 format"},
+	{name: "page", kind: "integer", description: "page to return, from 1"},
+	{name: "page_size", kind: "integer", description: "number of entries in a page, at most 500"},
+}
+
+var revisionDiffParams = []queryParam{
+	{name: "from", kind: "integer", description: "id of the revision to compare from"},
+	{name: "to", kind: "integer", description: "id of the revision to compare to"},
+}
+
+// apiOperations lists every route of the api, in the order of routes()
+var apiOperations = []operation{
+	{method: "GET", path: "/metrics", id: "Metrics", tag: "operations", summary: "Prometheus metrics", status: http.StatusOK, response: "", raw: true, responseType: "text/plain"},
+	{method: "GET", path: "/healthz", id: "Healthz", tag: "operations", summary: "Report that the process is up", status: http.StatusOK, response: healthResponse{}, raw: true},
+	{method: "GET", path: "/readyz", id: "Readyz", tag: "operations", summary: "Report whether the api can serve requests; 503 when it cannot", status: http.StatusOK, response: healthResponse{}, raw: true},
+	{method: "GET", path: "/openapi.json", id: "OpenAPI", tag: "operations", summary: "This document", status: http.StatusOK, response: map[string]interface{}{}, raw: true},
+	{method: "GET", path: "/docs", id: "Docs", tag: "operations", summary: "Interactive documentation of the api", status: http.StatusOK, response: "", raw: true, responseType: "text/html"},
+
+	{method: "POST", path: "/users/login", id: "Login", tag: "authentication", summary: "Log in, and get a token to send as a bearer token", request: loginInput{}, status: http.StatusOK, response: envelope{"token": data.Token{}, "user": data.User{}}},
+	{method: "POST", path: "/users/logout", id: "Logout", tag: "authentication", summary: "Revoke a token", request: tokenInput{}, status: http.StatusOK},
+
+	{method: "POST", path: "/books", id: "AllBooksPost", tag: "catalogue", summary: "List all books", status: http.StatusOK, response: envelope{"books": []data.Book{}}},
+	{method: "GET", path: "/books", id: "AllBooks", tag: "catalogue", summary: "List all books", status: http.StatusOK, response: envelope{"books": []data.Book{}}},
+	{method: "GET", path: "/books/{slug}", id: "OneBook", tag: "catalogue", summary: "Get a book by slug", status: http.StatusOK, response: data.Book{}},
+
+	{method: "POST", path: "/validate-token", id: "ValidateToken", tag: "authentication", summary: "Check whether a token is valid", request: tokenInput{}, status: http.StatusOK, response: false},
+
+	{method: "POST", path: "/admin/users", id: "AllUsers", tag: "admin", summary: "List all users", auth: true, status: http.StatusOK, response: envelope{"users": []data.User{}}},
+	{method: "POST", path: "/admin/users/save", id: "EditUser", tag: "admin", summary: "Add a user, or update the one with the given id", auth: true, request: data.User{}, status: http.StatusAccepted},
+	{method: "POST", path: "/admin/users/get/{id}", id: "GetUser", tag: "admin", summary: "Get a user", auth: true, status: http.StatusOK, response: data.User{}, raw: true},
+	{method: "POST", path: "/admin/users/delete", id: "DeleteUser", tag: "admin", summary: "Move a user to the trash", auth: true, request: idInput{}, status: http.StatusOK},
+	{method: "POST", path: "/admin/users/trash", id: "AdminUsersTrash", tag: "admin", summary: "List the users in the trash", auth: true, status: http.StatusOK, response: envelope{"users": []data.User{}}},
+	{method: "POST", path: "/admin/users/restore", id: "RestoreUser", tag: "admin", summary: "Take a user out of the trash", auth: true, request: idInput{}, status: http.StatusOK},
+	{method: "POST", path: "/admin/log-user-out/{id}", id: "LogUserOutAndSetInactive", tag: "admin", summary: "Deactivate a user and revoke their tokens", auth: true, status: http.StatusAccepted},
+	{method: "POST", path: "/admin/authors/all", id: "AuthorsAll", tag: "admin", summary: "List all authors, as options of a select box", auth: true, status: http.StatusOK, response: []selectOption{}},
+	{method: "POST", path: "/admin/books/save", id: "EditBook", tag: "admin", summary: "Add a book, or update the one with the given id", auth: true, request: bookInput{}, status: http.StatusAccepted},
+	{method: "POST", path: "/admin/books/delete", id: "DeleteBook", tag: "admin", summary: "Move a book to the trash", auth: true, request: idInput{}, status: http.StatusOK},
+	{method: "POST", path: "/admin/books/trash", id: "AdminBooksTrash", tag: "admin", summary: "List the books in the trash", auth: true, status: http.StatusOK, response: envelope{"books": []data.Book{}}},
+	{method: "POST", path: "/admin/books/restore", id: "RestoreBook", tag: "admin", summary: "Take a book out of the trash", auth: true, request: idInput{}, status: http.StatusOK},
+	{method: "POST", path: "/admin/books/{id}", id: "BookByID", tag: "admin", summary: "Get a book", auth: true, status: http.StatusOK, response: data.Book{}},
+	{method: "POST", path: "/admin/books/{id}/revisions", id: "AdminBookRevisions", tag: "admin", summary: "List the revisions of a book, newest first", auth: true, status: http.StatusOK, response: envelope{"revisions": []data.BookRevision{}}},
+	{method: "POST", path: "/admin/books/{id}/revisions/diff", id: "DiffBookRevisions", tag: "admin", summary: "Compare two revisions of a book", auth: true, request: revisionDiffInput{}, status: http.StatusOK, response: envelope{"from": data.BookRevision{}, "to": data.BookRevision{}, "changes": []data.RevisionChange{}}},
+	{method: "POST", path: "/admin/books/{id}/revisions/revert", id: "RevertBook", tag: "admin", summary: "Put a book back to one of its revisions", auth: true, request: revertInput{}, status: http.StatusAccepted},
+	{method: "POST", path: "/admin/audit", id: "AuditLog", tag: "admin", summary: "List audit log entries", auth: true, request: data.AuditFilter{}, status: http.StatusOK, response: envelope{"entries": []data.AuditEntry{}}},
+	{method: "POST", path: "/admin/audit/export", id: "ExportAuditLog", tag: "admin", summary: "Export audit log entries as CSV", auth: true, request: data.AuditFilter{}, status: http.StatusOK, response: "", raw: true, responseType: "text/csv"},
+
+	{method: "GET", path: "/api/v1/books", id: "V1ListBooks", tag: "books", summary: "List books", query: paginationParams, status: http.StatusOK, response: envelope{"books": []data.Book{}}},
+	{method: "GET", path: "/api/v1/books/{id}", id: "V1GetBook", tag: "books", summary: "Get a book", status: http.StatusOK, response: envelope{"book": data.Book{}}, conditional: true},
+	{method: "GET", path: "/api/v1/authors", id: "V1ListAuthors", tag: "authors", summary: "List authors", status: http.StatusOK, response: envelope{"authors": []data.Author{}}},
+	{method: "GET", path: "/api/v1/authors/{id}", id: "V1GetAuthor", tag: "authors", summary: "Get an author", status: http.StatusOK, response: envelope{"author": data.Author{}}},
+	{method: "GET", path: "/api/v1/genres", id: "V1ListGenres", tag: "genres", summary: "List genres", status: http.StatusOK, response: envelope{"genres": []data.Genre{}}},
+	{method: "GET", path: "/api/v1/genres/{id}", id: "V1GetGenre", tag: "genres", summary: "Get a genre", status: http.StatusOK, response: envelope{"genre": data.Genre{}}},
+
+	{method: "POST", path: "/api/v1/books", id: "V1CreateBook", tag: "books", summary: "Add a book", auth: true, request: bookInput{}, status: http.StatusCreated, response: envelope{"book": data.Book{}}},
+	{method: "PUT", path: "/api/v1/books/{id}", id: "V1UpdateBook", tag: "books", summary: "Replace a book", auth: true, request: bookInput{}, status: http.StatusOK, response: envelope{"book": data.Book{}}},
+	{method: "PATCH", path: "/api/v1/books/{id}", id: "V1PatchBook", tag: "books", summary: "Change some fields of a book", auth: true, request: bookInput{}, requestType: mergePatchContentType, status: http.StatusOK, response: envelope{"book": data.Book{}}},
+	{method: "DELETE", path: "/api/v1/books/{id}", id: "V1DeleteBook", tag: "books", summary: "Move a book to the trash", auth: true, status: http.StatusNoContent},
+	{method: "GET", path: "/api/v1/books/trash", id: "BooksTrash", tag: "books", summary: "List the books in the trash", auth: true, status: http.StatusOK, response: envelope{"books": []data.Book{}}},
+	{method: "POST", path: "/api/v1/books/{id}/restore", id: "V1RestoreBook", tag: "books", summary: "Take a book out of the trash", auth: true, status: http.StatusOK, response: envelope{"book": data.Book{}}},
+	{method: "GET", path: "/api/v1/books/{id}/revisions", id: "BookRevisions", tag: "books", summary: "List the revisions of a book, newest first", auth: true, status: http.StatusOK, response: envelope{"revisions": []data.BookRevision{}}},
+	{method: "GET", path: "/api/v1/books/{id}/revisions/diff", id: "V1DiffBookRevisions", tag: "books", summary: "Compare two revisions of a book", auth: true, query: revisionDiffParams, status: http.StatusOK, response: envelope{"from": data.BookRevision{}, "to": data.BookRevision{}, "changes": []data.RevisionChange{}}},
+	{method: "POST", path: "/api/v1/books/{id}/revisions/{revisionID}/revert", id: "V1RevertBook", tag: "books", summary: "Put a book back to one of its revisions", auth: true, status: http.StatusOK, response: envelope{"book": data.Book{}}},
+
+	{method: "GET", path: "/api/v1/users", id: "V1ListUsers", tag: "users", summary: "List users", auth: true, status: http.StatusOK, response: envelope{"users": []data.User{}}},
+	{method: "POST", path: "/api/v1/users", id: "V1CreateUser", tag: "users", summary: "Add a user", auth: true, request: data.User{}, status: http.StatusCreated, response: envelope{"user": data.User{}}},
+	{method: "GET", path: "/api/v1/users/trash", id: "UsersTrash", tag: "users", summary: "List the users in the trash", auth: true, status: http.StatusOK, response: envelope{"users": []data.User{}}},
+	{method: "GET", path: "/api/v1/users/{id}", id: "V1GetUser", tag: "users", summary: "Get a user", auth: true, status: http.StatusOK, response: envelope{"user": data.User{}}, conditional: true},
+	{method: "PUT", path: "/api/v1/users/{id}", id: "V1UpdateUser", tag: "users", summary: "Replace a user", auth: true, request: data.User{}, status: http.StatusOK, response: envelope{"user": data.User{}}},
+	{method: "PATCH", path: "/api/v1/users/{id}", id: "V1PatchUser", tag: "users", summary: "Change some fields of a user", auth: true, request: userDocument{}, requestType: mergePatchContentType, status: http.StatusOK, response: envelope{"user": data.User{}}},
+	{method: "DELETE", path: "/api/v1/users/{id}", id: "V1DeleteUser", tag: "users", summary: "Move a user to the trash", auth: true, status: http.StatusNoContent},
+	{method: "POST", path: "/api/v1/users/{id}/restore", id: "V1RestoreUser", tag: "users", summary: "Take a user out of the trash", auth: true, status: http.StatusOK, response: envelope{"user": data.User{}}},
+	{method: "POST", path: "/api/v1/users/{id}/logout", id: "V1LogUserOut", tag: "users", summary: "Deactivate a user and revoke their tokens", auth: true, status: http.StatusOK, response: envelope{"user": data.User{}}},
+
+	{method: "POST", path: "/api/v1/authors", id: "V1CreateAuthor", tag: "authors", summary: "Add an author", auth: true, request: data.Author{}, status: http.StatusCreated, response: envelope{"author": data.Author{}}},
+	{method: "PUT", path: "/api/v1/authors/{id}", id: "V1UpdateAuthor", tag: "authors", summary: "Replace an author", auth: true, request: data.Author{}, status: http.StatusOK, response: envelope{"author": data.Author{}}},
+	{method: "DELETE", path: "/api/v1/authors/{id}", id: "V1DeleteAuthor", tag: "authors", summary: "Delete an author who has no books", auth: true, status: http.StatusNoContent},
+
+	{method: "POST", path: "/api/v1/genres", id: "V1CreateGenre", tag: "genres", summary: "Add a genre", auth: true, request: data.Genre{}, status: http.StatusCreated, response: envelope{"genre": data.Genre{}}},
+	{method: "PUT", path: "/api/v1/genres/{id}", id: "V1UpdateGenre", tag: "genres", summary: "Replace a genre", auth: true, request: data.Genre{}, status: http.StatusOK, response: envelope{"genre": data.Genre{}}},
+	{method: "DELETE", path: "/api/v1/genres/{id}", id: "V1DeleteGenre", tag: "genres", summary: "Delete a genre", auth: true, status: http.StatusNoContent},
+
+	{method: "GET", path: "/api/v1/audit", id: "V1AuditLog", tag: "audit", summary: "List audit log entries", auth: true, query: auditFilterParams, status: http.StatusOK, response: envelope{"entries": []data.AuditEntry{}}},
+	{method: "GET", path: "/api/v1/audit/export", id: "V1ExportAuditLog", tag: "audit", summary: "Export audit log entries as CSV", auth: true, query: auditFilterParams, status: http.StatusOK, response: "", raw: true, responseType: "text/csv"},
+
+	{method: "GET", path: "/static/*", id: "Static", tag: "catalogue", summary: "Static files, such as the cover of a book at /static/covers/{slug}.jpg", status: http.StatusOK, response: []byte{}, raw: true, responseType: "application/octet-stream"},
+}
+
+// OpenAPI serves the OpenAPI document describing the api
+func (app *application) OpenAPI(w http.ResponseWriter, r *http.Request) {
+	_ = app.writeJSON(w, http.StatusOK, openAPIDocument())
+}
+
+// Docs serves a page to browse the api, and try it out, from its OpenAPI document
+func (app *application) Docs(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(docsPage))
+}
+
+// docsPage shows the OpenAPI document with Swagger UI
+const docsPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>vue-api</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="docs"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    SwaggerUIBundle({ url: "/openapi.json", dom_id: "#docs" });
+  </script>
+</body>
+</html>
+`
+
+// openAPIDocument builds the OpenAPI document from apiOperations
+func openAPIDocument() map[string]interface{} {
+	s := make(schemas)
+	paths := make(map[string]map[string]interface{})
+
+	for _, op := range apiOperations {
+		path := openAPIPath(op.path)
+		if paths[path] == nil {
+			paths[path] = make(map[string]interface{})
+		}
+		paths[path][strings.ToLower(op.method)] = s.operation(op)
+	}
+
+	return map[string]interface{}{
+		"openapi": openAPIVersion,
+		"info": map[string]interface{}{
+			"title":       "vue-api",
+			"version":     "1",
+			"description": "The api of the book catalogue. Errors are sent as RFC 7807 problem details, whose code member clients should match on.",
+		},
+		"paths": paths,
+		"components": map[string]interface{}{
+			"schemas": s,
+			"securitySchemes": map[string]interface{}{
+				"bearerAuth": map[string]interface{}{"type": "http", "scheme": "bearer", "description": "a token from /users/login"},
+			},
+		},
+	}
+}
+
+var pathParamRX = regexp.MustCompile(`\{(\w+)\}`)
+
+// openAPIPath turns a chi route pattern into an OpenAPI path; the catch all * becomes {path}
+func openAPIPath(pattern string) string {
+	if strings.HasSuffix(pattern, "/*") {
+		return strings.TrimSuffix(pattern, "*") + "{path}"
+	}
+	return pattern
+}
+
+// schemas holds the schemas of the named types met while describing the api, by name, to be
+// listed under components
+type schemas map[string]interface{}
+
+// operation describes op, adding the schemas of its bodies
+func (s schemas) operation(op operation) map[string]interface{} {
+	var params []interface{}
+	for _, m := range pathParamRX.FindAllStringSubmatch(openAPIPath(op.path), -1) {
+		schema := map[string]interface{}{"type": "integer", "minimum": 1}
+		if m[1] == "slug" || m[1] == "path" {
+			schema = map[string]interface{}{"type": "string"}
+		}
+		params = append(params, map[string]interface{}{"name": m[1], "in": "path", "required": true, "schema": schema})
+	}
+	for _, q := range op.query {
+		schema := map[string]interface{}{"type": q.kind}
+		if q.format != "" {
+			schema["format"] = q.format
+		}
+		params = append(params, map[string]interface{}{"name": q.name, "in": "query", "description": q.description, "schema": schema})
+	}
+	if op.conditional {
+		params = append(params, map[string]interface{}{"name": "If-None-Match", "in": "header", "description": "ETag of the version the client has", "schema": map[string]interface{}{"type": "string"}})
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(op.status)}
+	if op.status != http.StatusNoContent {
+		mediaType := op.responseType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+
+		var schema map[string]interface{}
+		switch {
+		case op.raw:
+			schema = s.ofValue(op.response, mediaType)
+		case op.response == nil:
+			schema = s.of(reflect.TypeOf(jsonResponse{}))
+		default:
+			schema = map[string]interface{}{
+				"allOf": []interface{}{
+					s.of(reflect.TypeOf(jsonResponse{})),
+					map[string]interface{}{"type": "object", "properties": map[string]interface{}{"data": s.ofValue(op.response, mediaType)}},
+				},
+			}
+		}
+		success["content"] = map[string]interface{}{mediaType: map[string]interface{}{"schema": schema}}
+	}
+
+	problemContent := map[string]interface{}{problemContentType: map[string]interface{}{"schema": s.of(reflect.TypeOf(problem{}))}}
+	responses := map[string]interface{}{
+		strconv.Itoa(op.status): success,
+		"default":               map[string]interface{}{"description": "the request failed", "content": problemContent},
+	}
+	if op.conditional {
+		responses[strconv.Itoa(http.StatusNotModified)] = map[string]interface{}{"description": "the client has the current version"}
+	}
+	if op.auth {
+		responses[strconv.Itoa(http.StatusUnauthorized)] = map[string]interface{}{"description": "the bearer token is missing or invalid", "content": problemContent}
+	}
+
+	described := map[string]interface{}{
+		"operationId": op.id,
+		"tags":        []string{op.tag},
+		"summary":     op.summary,
+		"responses":   responses,
+	}
+	if len(params) > 0 {
+		described["parameters"] = params
+	}
+	if op.request != nil {
+		mediaType := op.requestType
+		if mediaType == "" {
+			mediaType = "application/json"
+		}
+		described["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{mediaType: map[string]interface{}{"schema": s.ofValue(op.request, mediaType)}},
+		}
+	}
+	if op.auth {
+		described["security"] = []interface{}{map[string]interface{}{"bearerAuth": []string{}}}
+	}
+
+	return described
+}
+
+var (
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// ofValue returns the schema of v, sent with the given media type. Envelopes are described member
+// by member, from the values they hold, and anything which is not json is a string
+func (s schemas) ofValue(v interface{}, mediaType string) map[string]interface{} {
+	if mediaType != "application/json" && mediaType != mergePatchContentType {
+		if _, binary := v.([]byte); binary {
+			return map[string]interface{}{"type": "string", "format": "binary"}
+		}
+		return map[string]interface{}{"type": "string"}
+	}
+
+	if e, ok := v.(envelope); ok {
+		properties := make(map[string]interface{})
+		var required []string
+		for name, value := range e {
+			properties[name] = s.ofValue(value, mediaType)
+			required = append(required, name)
+		}
+		sort.Strings(required)
+		return map[string]interface{}{"type": "object", "properties": properties, "required": required}
+	}
+
+	return s.of(reflect.TypeOf(v))
+}
+
+// of returns the schema of values of type t. Named structs are added to s, and referred to
+func (s schemas) of(t reflect.Type) map[string]interface{} {
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case rawMessageType:
+		return map[string]interface{}{"description": "any json value"}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return s.of(t.Elem())
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": s.of(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": s.of(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return s.object(t)
+		}
+		name := strings.ToUpper(t.Name()[:1]) + t.Name()[1:]
+		if _, seen := s[name]; !seen {
+			// claimed before the fields are described, so that a type which refers to itself
+			// does not recurse forever
+			s[name] = map[string]interface{}{}
+			s[name] = s.object(t)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+
+	// interface{}, which may hold anything
+	return map[string]interface{}{}
+}
+
+// object describes the json encoding of a struct: a property for each exported field, named by
+// its json tag. Fields validated as required are required
+func (s schemas) object(t reflect.Type) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		properties[name] = s.of(f.Type)
+		for _, rule := range strings.Split(f.Tag.Get("validate"), ",") {
+			if rule == "required" {
+				required = append(required, name)
+			}
+		}
+	}
+
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+)
+
+func Test_openAPI_documentsEveryRoute(t *testing.T) {
+	documented := make(map[string]bool)
+	ids := make(map[string]bool)
+	for _, op := range apiOperations {
+		key := op.method + " " + op.path
+		if documented[key] {
+			t.Errorf("%s is documented twice", key)
+		}
+		documented[key] = true
+
+		if ids[op.id] {
+			t.Errorf("operation id %s is used twice", op.id)
+		}
+		ids[op.id] = true
+	}
+
+	registered := make(map[string]bool)
+	_ = chi.Walk(testApp.routes().(chi.Router), func(method, route string, handler http.Handler, middlewares ...func(http.Handler) http.Handler) error {
+		// static files are served for every method, but only GET is of any use
+		if route == "/static/*" && method != "GET" {
+			return nil
+		}
+		registered[method+" "+route] = true
+		return nil
+	})
+
+	for route := range registered {
+		if !documented[route] {
+			t.Errorf("%s is not documented; add it to apiOperations", route)
+		}
+	}
+	for route := range documented {
+		if !registered[route] {
+			t.Errorf("%s is documented, but there is no such route", route)
+		}
+	}
+}
+
+func Test_OpenAPI(t *testing.T) {
+	routes := testApp.routes()
+
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest("GET", "/openapi.json", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", rr.Code)
+	}
+
+	var document struct {
+		OpenAPI    string                                       `json:"openapi"`
+		Paths      map[string]map[string]map[string]interface{} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]interface{} `json:"properties"`
+				Required   []string               `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(rr.Body.Bytes(), &document); err != nil {
+		t.Fatal(err)
+	}
+
+	if document.OpenAPI != openAPIVersion {
+		t.Errorf("expected openapi %s, got %q", openAPIVersion, document.OpenAPI)
+	}
+
+	if _, ok := document.Paths["/api/v1/books/{id}"]["patch"]["requestBody"]; !ok {
+		t.Error("expected the request body of PATCH /api/v1/books/{id} to be described")
+	}
+	if _, ok := document.Paths["/static/{path}"]["get"]; !ok {
+		t.Error("expected the static files to be documented as /static/{path}")
+	}
+	if _, ok := document.Paths["/api/v1/users"]["get"]["security"]; !ok {
+		t.Error("expected GET /api/v1/users to require a bearer token")
+	}
+
+	// schemas are derived from the Go types, with their json names
+	book := document.Components.Schemas["Book"]
+	for _, property := range []string{"id", "title", "slug", "author", "genres", "version"} {
+		if _, ok := book.Properties[property]; !ok {
+			t.Errorf("expected the Book schema to have a %s property", property)
+		}
+	}
+	if _, ok := document.Components.Schemas["Token"].Properties["TokenHash"]; ok {
+		t.Error("fields left out of the json encoding should not be documented")
+	}
+	if required := strings.Join(document.Components.Schemas["BookInput"].Required, ","); required != "title,author_id,publication_year" {
+		t.Errorf("expected the required fields of BookInput to come from its validate tags, got %s", required)
+	}
+
+	// every reference points at a schema which is there
+	for _, ref := range refsIn(rr.Body.String()) {
+		name := strings.TrimPrefix(ref, "#/components/schemas/")
+		if _, ok := document.Components.Schemas[name]; !ok {
+			t.Errorf("%s refers to no schema", ref)
+		}
+	}
+}
+
+// refsIn returns the targets of every $ref in a json document
+func refsIn(document string) []string {
+	var refs []string
+	for _, part := range strings.Split(document, `"$ref":`)[1:] {
+		part = strings.TrimSpace(part)
+		if end := strings.Index(part[1:], `"`); end >= 0 {
+			refs = append(refs, part[1:end+1])
+		}
+	}
+	return refs
+}
+
+func Test_Docs(t *testing.T) {
+	rr := httptest.NewRecorder()
+	testApp.routes().ServeHTTP(rr, httptest.NewRequest("GET", "/docs", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status 200 but got %d", rr.Code)
+	}
+	if !strings.HasPrefix(rr.Header().Get("Content-Type"), "text/html") {
+		t.Errorf("expected an html page, got %s", rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rr.Body.String(), "/openapi.json") {
+		t.Error("expected the page to load /openapi.json")
+	}
+}
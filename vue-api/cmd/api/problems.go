@@ -0,0 +1,171 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"net/http"
+	"vue-api/internal/data"
+	"vue-api/internal/i18n"
+)
+
+// Errors are sent to clients as RFC 7807 problem details. Every problem carries a code from the
+// list below; clients should match on the code rather than on the text of the message, which
+// is meant for people and translated from the i18n catalogues (under the key problem.<code>).
+// Once published, a code must never change its meaning
+
+const problemContentType = "application/problem+json"
+
+// problemTypeBase is prefixed to the code of a problem to build its type member
+const problemTypeBase = "urn:vue-api:problem:"
+
+const (
+	codeBadRequest           = "bad_request"
+	codeInvalidJSON          = "invalid_json"
+	codeValidationFailed     = "validation_failed"
+	codeInvalidPrecondition  = "invalid_precondition"
+	codeUnauthorized         = "unauthorized"
+	codeInvalidCredentials   = "invalid_credentials"
+	codeUserInactive         = "user_inactive"
+	codeForbidden            = "forbidden"
+	codeNotFound             = "not_found"
+	codeNotInTrash           = "not_in_trash"
+	codeRevisionMismatch     = "revision_mismatch"
+	codeEditConflict         = "edit_conflict"
+	codeInUse                = "in_use"
+	codeDuplicateValue       = "duplicate_value"
+	codeValueTooLong         = "value_too_long"
+	codeForeignKeyViolation  = "foreign_key_violation"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeInternal             = "internal_error"
+)
+
+// statusCodes holds the code used for an error which only has an http status to go on
+var statusCodes = map[int]string{
+	http.StatusBadRequest:           codeBadRequest,
+	http.StatusUnauthorized:         codeUnauthorized,
+	http.StatusForbidden:            codeForbidden,
+	http.StatusNotFound:             codeNotFound,
+	http.StatusConflict:             codeEditConflict,
+	http.StatusUnsupportedMediaType: codeUnsupportedMediaType,
+	http.StatusUnprocessableEntity:  codeValidationFailed,
+	http.StatusInternalServerError:  codeInternal,
+}
+
+// problem is an RFC 7807 problem details object. The error and message members are not part of
+// the RFC; they keep the responses readable by clients written against jsonResponse
+type problem struct {
+	Type    string              `json:"type"`
+	Title   string              `json:"title"`
+	Status  int                 `json:"status"`
+	Detail  string              `json:"detail,omitempty"`
+	Code    string              `json:"code"`
+	Errors  map[string][]string `json:"errors,omitempty"`
+	Data    interface{}         `json:"data,omitempty"`
+	Error   bool                `json:"error"`
+	Message string              `json:"message"`
+}
+
+// newProblem builds the problem for a code, with its title translated by printer. The detail is
+// optional, and explains what went wrong with this particular request
+func newProblem(printer i18n.Printer, code string, status int, detail string) problem {
+	title := printer.Sprintf("problem." + code)
+
+	return problem{
+		Type:    problemTypeBase + code,
+		Title:   title,
+		Status:  status,
+		Detail:  detail,
+		Code:    code,
+		Error:   true,
+		Message: title,
+	}
+}
+
+// appError is an error which is reported to the client with a specific code and status
+type appError struct {
+	code   string
+	status int
+	err    error
+}
+
+func (e *appError) Error() string {
+	if e.err != nil {
+		return e.err.Error()
+	}
+	return i18n.NewPrinter(i18n.English).Sprintf("problem." + e.code)
+}
+
+func (e *appError) Unwrap() error {
+	return e.err
+}
+
+var (
+	errInvalidCredentials = &appError{code: codeInvalidCredentials, status: http.StatusBadRequest}
+	errUserInactive       = &appError{code: codeUserInactive, status: http.StatusBadRequest}
+	errInvalidJSON        = &appError{code: codeInvalidJSON, status: http.StatusBadRequest}
+	errUnauthorized       = &appError{code: codeUnauthorized, status: http.StatusUnauthorized}
+	errInvalidIfMatch     = &appError{code: codeInvalidPrecondition, status: http.StatusBadRequest}
+	errInternal           = &appError{code: codeInternal, status: http.StatusInternalServerError}
+)
+
+// badRequest wraps an error so that it is reported with the given code and a 400 Bad Request status
+func badRequest(code string, err error) error {
+	return &appError{code: code, status: http.StatusBadRequest, err: err}
+}
+
+// problemFor works out the problem to send for an error. Errors which are not recognised are sent
+// as an internal error, unless status gives a client error to send them with instead; headers
+// holds any headers which should be sent with the problem
+func problemFor(printer i18n.Printer, err error, status int) (p problem, headers http.Header) {
+	headers = make(http.Header)
+
+	var conflict *conflictError
+	var invalid *validationError
+	var appErr *appError
+
+	switch {
+	case errors.As(err, &conflict):
+		headers.Set("ETag", etag(conflict.version))
+		p = newProblem(printer, codeEditConflict, http.StatusConflict, "")
+		p.Data = envelope{conflict.name: conflict.current}
+	case errors.As(err, &invalid):
+		p = newProblem(printer, codeValidationFailed, http.StatusUnprocessableEntity, "")
+		p.Errors = invalid.errors
+	case errors.As(err, &appErr):
+		detail := ""
+		if appErr.err != nil {
+			detail = appErr.err.Error()
+		}
+		p = newProblem(printer, appErr.code, appErr.status, detail)
+	case errors.Is(err, data.ErrEditConflict):
+		p = newProblem(printer, codeEditConflict, http.StatusConflict, "")
+	case errors.Is(err, data.ErrInUse):
+		p = newProblem(printer, codeInUse, http.StatusConflict, "")
+	case errors.Is(err, sql.ErrNoRows):
+		p = newProblem(printer, codeNotFound, http.StatusNotFound, "")
+	case errors.Is(err, data.ErrNotInTrash):
+		p = newProblem(printer, codeNotInTrash, http.StatusNotFound, "")
+	case errors.Is(err, data.ErrRevisionMismatch):
+		p = newProblem(printer, codeRevisionMismatch, http.StatusNotFound, "")
+	case errors.Is(err, errUnsupportedPatch):
+		p = newProblem(printer, codeUnsupportedMediaType, http.StatusUnsupportedMediaType, "")
+	case errors.Is(err, data.ErrDuplicate):
+		p = newProblem(printer, codeDuplicateValue, http.StatusForbidden, "")
+	case errors.Is(err, data.ErrTooLong):
+		p = newProblem(printer, codeValueTooLong, http.StatusForbidden, "")
+	case errors.Is(err, data.ErrInvalidReference):
+		p = newProblem(printer, codeForeignKeyViolation, http.StatusForbidden, "")
+	default:
+		code, ok := statusCodes[status]
+		if !ok || status >= http.StatusInternalServerError {
+			// the error may say anything about the inner workings of the api, such as the text
+			// of a query, so it is only logged
+			p = newProblem(printer, codeInternal, http.StatusInternalServerError, "")
+			break
+		}
+		p = newProblem(printer, code, status, err.Error())
+		p.Message = p.Detail
+	}
+
+	return p, headers
+}
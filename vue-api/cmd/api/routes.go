@@ -4,7 +4,6 @@ import (
 	"net/http"
 
 	"github.com/go-chi/chi/v5"
-	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
 )
 
@@ -14,16 +13,26 @@ import (
 // that is part of the standard library.
 func (app *application) routes() http.Handler {
 	mux := chi.NewRouter()
-	mux.Use(middleware.Recoverer)
+	mux.Use(app.requestID)
+	mux.Use(app.traceRequests)
+	mux.Use(app.logRequests)
+	mux.Use(app.recoverPanic)
+	mux.Use(app.instrument)
 	mux.Use(cors.Handler(cors.Options{
-		AllowedOrigins:   []string{"https://*", "http://*"},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
-		ExposedHeaders:   []string{"Link"},
+		AllowedOrigins:   app.config.cors.allowedOrigins,
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token", "If-Match", "If-None-Match", requestIDHeader},
+		ExposedHeaders:   []string{"Link", "ETag", "Location", requestIDHeader},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
 
+	mux.Method("GET", "/metrics", app.metrics.handler())
+	mux.Get("/healthz", app.Healthz)
+	mux.Get("/readyz", app.Readyz)
+	mux.Get("/openapi.json", app.OpenAPI)
+	mux.Get("/docs", app.Docs)
+
 	mux.Post("/users/login", app.Login)
 	mux.Post("/users/logout", app.Logout)
 
@@ -44,18 +53,75 @@ func (app *application) routes() http.Handler {
 		mux.Post("/users/save", app.EditUser)
 		mux.Post("/users/get/{id}", app.GetUser)
 		mux.Post("/users/delete", app.DeleteUser)
+		mux.Post("/users/trash", app.UsersTrash)
+		mux.Post("/users/restore", app.RestoreUser)
 		mux.Post("/log-user-out/{id}", app.LogUserOutAndSetInactive)
 
 		// admin book routes
 		mux.Post("/authors/all", app.AuthorsAll)
 		mux.Post("/books/save", app.EditBook)
 		mux.Post("/books/delete", app.DeleteBook)
+		mux.Post("/books/trash", app.BooksTrash)
+		mux.Post("/books/restore", app.RestoreBook)
 		mux.Post("/books/{id}", app.BookByID)
+		mux.Post("/books/{id}/revisions", app.BookRevisions)
+		mux.Post("/books/{id}/revisions/diff", app.DiffBookRevisions)
+		mux.Post("/books/{id}/revisions/revert", app.RevertBook)
+
+		// admin audit log routes
+		mux.Post("/audit", app.AuditLog)
+		mux.Post("/audit/export", app.ExportAuditLog)
+
+	})
+
+	// the versioned, resource oriented api; reads of the catalogue are public, everything
+	// else requires a valid token, just like the /admin routes
+	mux.Route(apiV1Prefix, func(mux chi.Router) {
+		mux.Get("/books", app.V1ListBooks)
+		mux.Get("/books/{id}", app.V1GetBook)
+		mux.Get("/authors", app.V1ListAuthors)
+		mux.Get("/authors/{id}", app.V1GetAuthor)
+		mux.Get("/genres", app.V1ListGenres)
+		mux.Get("/genres/{id}", app.V1GetGenre)
+
+		mux.Group(func(mux chi.Router) {
+			mux.Use(app.AuthTokenMiddleware)
+
+			mux.Post("/books", app.V1CreateBook)
+			mux.Put("/books/{id}", app.V1UpdateBook)
+			mux.Patch("/books/{id}", app.V1PatchBook)
+			mux.Delete("/books/{id}", app.V1DeleteBook)
+			mux.Get("/books/trash", app.BooksTrash)
+			mux.Post("/books/{id}/restore", app.V1RestoreBook)
+			mux.Get("/books/{id}/revisions", app.BookRevisions)
+			mux.Get("/books/{id}/revisions/diff", app.V1DiffBookRevisions)
+			mux.Post("/books/{id}/revisions/{revisionID}/revert", app.V1RevertBook)
+
+			mux.Get("/users", app.V1ListUsers)
+			mux.Post("/users", app.V1CreateUser)
+			mux.Get("/users/trash", app.UsersTrash)
+			mux.Get("/users/{id}", app.V1GetUser)
+			mux.Put("/users/{id}", app.V1UpdateUser)
+			mux.Patch("/users/{id}", app.V1PatchUser)
+			mux.Delete("/users/{id}", app.V1DeleteUser)
+			mux.Post("/users/{id}/restore", app.V1RestoreUser)
+			mux.Post("/users/{id}/logout", app.V1LogUserOut)
+
+			mux.Post("/authors", app.V1CreateAuthor)
+			mux.Put("/authors/{id}", app.V1UpdateAuthor)
+			mux.Delete("/authors/{id}", app.V1DeleteAuthor)
+
+			mux.Post("/genres", app.V1CreateGenre)
+			mux.Put("/genres/{id}", app.V1UpdateGenre)
+			mux.Delete("/genres/{id}", app.V1DeleteGenre)
 
+			mux.Get("/audit", app.V1AuditLog)
+			mux.Get("/audit/export", app.V1ExportAuditLog)
+		})
 	})
 
 	// static files
-	fileServer := http.FileServer(http.Dir("./static/"))
+	fileServer := http.FileServer(http.Dir(app.config.staticPath))
 	mux.Handle("/static/*", http.StripPrefix("/static", fileServer))
 
 	return mux
@@ -14,10 +14,31 @@ func Test_Routes_Exist(t *testing.T) {
 	// These routes must exist
 	routeExists(t, chiRoutes, "/users/login")
 	routeExists(t, chiRoutes, "/users/logout")
+	routeExists(t, chiRoutes, "/metrics")
+	routeExists(t, chiRoutes, "/healthz")
+	routeExists(t, chiRoutes, "/readyz")
 	routeExists(t, chiRoutes, "/admin/users/get/{id}")
 	routeExists(t, chiRoutes, "/admin/users/save")
 	routeExists(t, chiRoutes, "/admin/users")
 	routeExists(t, chiRoutes, "/admin/users/delete")
+	routeExists(t, chiRoutes, "/admin/users/trash")
+	routeExists(t, chiRoutes, "/admin/users/restore")
+	routeExists(t, chiRoutes, "/admin/books/trash")
+	routeExists(t, chiRoutes, "/admin/books/restore")
+	routeExists(t, chiRoutes, "/admin/books/{id}/revisions")
+	routeExists(t, chiRoutes, "/admin/books/{id}/revisions/diff")
+	routeExists(t, chiRoutes, "/admin/books/{id}/revisions/revert")
+	routeExists(t, chiRoutes, "/admin/audit")
+	routeExists(t, chiRoutes, "/admin/audit/export")
+
+	// versioned api
+	for _, resource := range []string{"books", "users", "authors", "genres"} {
+		routeExists(t, chiRoutes, "/api/v1/"+resource)
+		routeExists(t, chiRoutes, "/api/v1/"+resource+"/{id}")
+	}
+	routeExists(t, chiRoutes, "/api/v1/books/{id}/restore")
+	routeExists(t, chiRoutes, "/api/v1/users/{id}/logout")
+	routeExists(t, chiRoutes, "/api/v1/audit")
 
 }
 
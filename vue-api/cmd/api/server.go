@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+// errWorkersTimeout is returned by serve when background workers are still running once the
+// shutdown timeout has passed
+var errWorkersTimeout = errors.New("background workers did not stop in time")
+
+// newServer returns the http server for the api. The timeouts stop slow or idle clients from
+// holding connections open for ever
+func (app *application) newServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              fmt.Sprintf(":%d", app.config.port),
+		Handler:           handler,
+		ReadTimeout:       app.config.server.readTimeout,
+		ReadHeaderTimeout: app.config.server.readHeaderTimeout,
+		WriteTimeout:      app.config.server.writeTimeout,
+		IdleTimeout:       app.config.server.idleTimeout,
+		ErrorLog:          slog.NewLogLogger(app.logger.Handler(), slog.LevelError),
+	}
+}
+
+// boundServer is an http server together with the listener it accepts connections on. The
+// server speaks tls when it has a TLSConfig
+type boundServer struct {
+	srv *http.Server
+	ln  net.Listener
+}
+
+func (b boundServer) serve() error {
+	if b.srv.TLSConfig != nil {
+		// the certificate comes from TLSConfig.GetCertificate, so no files are passed
+		return b.srv.ServeTLS(b.ln, "", "")
+	}
+	return b.srv.Serve(b.ln)
+}
+
+// serve starts the web server, and runs until ctx is cancelled. When tls is configured the api
+// is served over https, with http/2, and plain http requests to the redirect port (if any) are
+// redirected to it
+func (app *application) serve(ctx context.Context) error {
+	handler := app.routes()
+
+	var certs *certReloader
+	if app.config.tlsEnabled() {
+		var err error
+		certs, err = newCertReloader(app.config.tls.certFile, app.config.tls.keyFile, app.logger)
+		if err != nil {
+			return err
+		}
+		handler = app.hsts(handler)
+	}
+
+	srv := app.newServer(handler)
+	if certs != nil {
+		srv.TLSConfig = tlsConfig(certs)
+	}
+
+	ln, err := net.Listen("tcp", srv.Addr)
+	if err != nil {
+		return err
+	}
+	servers := []boundServer{{srv: srv, ln: ln}}
+
+	if app.config.tls.redirectPort != 0 {
+		redirect := app.newServer(http.HandlerFunc(app.redirectToHTTPS))
+		redirect.Addr = fmt.Sprintf(":%d", app.config.tls.redirectPort)
+
+		redirectLn, err := net.Listen("tcp", redirect.Addr)
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		servers = append(servers, boundServer{srv: redirect, ln: redirectLn})
+
+		app.logger.Info("redirecting http to https", "port", app.config.tls.redirectPort)
+	}
+
+	if certs != nil {
+		app.background(func() {
+			certs.watch(ctx, app.config.tls.reloadInterval)
+		})
+	}
+	app.logger.Info("API listening", "port", app.config.port, "tls", certs != nil)
+
+	return app.serveUntilDone(ctx, servers...)
+}
+
+// serveUntilDone serves requests on every server until ctx is cancelled. It then stops accepting
+// new connections, and waits up to the shutdown timeout for requests in flight to finish and for
+// background workers (which are stopped by the same ctx) to return. If a server fails first, the
+// others are closed and its error is returned
+func (app *application) serveUntilDone(ctx context.Context, servers ...boundServer) error {
+	serveErr := make(chan error, len(servers))
+	for _, s := range servers {
+		go func() {
+			serveErr <- s.serve()
+		}()
+	}
+
+	select {
+	case err := <-serveErr:
+		// a server failed before we were asked to stop
+		for _, s := range servers {
+			s.srv.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
+
+	// readiness fails from now on, so load balancers stop sending requests our way; the drain
+	// delay gives them time to notice before connections are refused
+	app.shuttingDown.Store(true)
+	if app.config.server.drainDelay > 0 {
+		app.logger.Info("shutting down, draining", "delay", app.config.server.drainDelay)
+		time.Sleep(app.config.server.drainDelay)
+	}
+
+	app.logger.Info("shutting down, waiting for requests in flight to finish")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), app.config.server.shutdownTimeout)
+	defer cancel()
+
+	var err error
+	for _, s := range servers {
+		err = errors.Join(err, s.srv.Shutdown(shutdownCtx))
+	}
+
+	workersDone := make(chan struct{})
+	go func() {
+		app.wg.Wait()
+		close(workersDone)
+	}()
+
+	select {
+	case <-workersDone:
+	case <-shutdownCtx.Done():
+		err = errors.Join(err, errWorkersTimeout)
+	}
+
+	for range servers {
+		if e := <-serveErr; !errors.Is(e, http.ErrServerClosed) {
+			err = errors.Join(err, e)
+		}
+	}
+
+	return err
+}
+
+// background runs fn in its own goroutine, which serve waits for when shutting down. fn must
+// return once the context passed to serve is cancelled
+func (app *application) background(fn func()) {
+	app.wg.Add(1)
+
+	go func() {
+		defer app.wg.Done()
+		fn()
+	}()
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newServerTestApp returns an application with its own logs and wait group, so that tests of
+// serve do not share background workers with other tests
+func newServerTestApp(shutdownTimeout time.Duration) *application {
+	app := &application{
+		config: defaultConfig(),
+		logger: discardLogger(),
+	}
+	app.config.server.shutdownTimeout = shutdownTimeout
+	return app
+}
+
+func Test_newServer(t *testing.T) {
+	srv := testApp.newServer(http.NotFoundHandler())
+
+	if srv.Addr != ":8082" {
+		t.Errorf("expected address :8082 but got %s", srv.Addr)
+	}
+
+	if srv.ReadTimeout == 0 || srv.ReadHeaderTimeout == 0 || srv.WriteTimeout == 0 || srv.IdleTimeout == 0 {
+		t.Errorf("expected every timeout to be set: %+v", srv)
+	}
+}
+
+func Test_serveUntilDone_drainsRequests(t *testing.T) {
+	app := newServerTestApp(5 * time.Second)
+
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		time.Sleep(200 * time.Millisecond)
+		_, _ = w.Write([]byte("done"))
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var workerStopped atomic.Bool
+	ctx, cancel := context.WithCancel(context.Background())
+
+	app.background(func() {
+		<-ctx.Done()
+		time.Sleep(50 * time.Millisecond)
+		workerStopped.Store(true)
+	})
+
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serveUntilDone(ctx, boundServer{srv: app.newServer(handler), ln: ln})
+	}()
+
+	type result struct {
+		body string
+		err  error
+	}
+	response := make(chan result, 1)
+	go func() {
+		resp, err := http.Get("http://" + ln.Addr().String())
+		if err != nil {
+			response <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		response <- result{body: string(body), err: err}
+	}()
+
+	<-started
+	cancel()
+
+	r := <-response
+	if r.err != nil || r.body != "done" {
+		t.Errorf("expected the request in flight to finish, got %q, %v", r.body, r.err)
+	}
+
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown but got %v", err)
+	}
+
+	if !workerStopped.Load() {
+		t.Error("serve returned before the background worker stopped")
+	}
+
+	if _, err := http.Get("http://" + ln.Addr().String()); err == nil {
+		t.Error("expected new connections to be refused after shutdown")
+	}
+}
+
+func Test_serveUntilDone_deadline(t *testing.T) {
+	app := newServerTestApp(100 * time.Millisecond)
+
+	release := make(chan struct{})
+	defer close(release)
+
+	app.background(func() {
+		<-release
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = app.serveUntilDone(ctx, boundServer{srv: app.newServer(http.NotFoundHandler()), ln: ln})
+	if !errors.Is(err, errWorkersTimeout) {
+		t.Errorf("expected the shutdown to time out waiting for workers, got %v", err)
+	}
+}
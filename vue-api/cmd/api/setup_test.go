@@ -1,7 +1,8 @@
 package main
 
 import (
-	"log"
+	"io"
+	"log/slog"
 	"os"
 	"testing"
 	"vue-api/internal/data"
@@ -19,12 +20,17 @@ func TestMain(m *testing.M) {
 	defer testDB.Close()
 
 	testApp = application{
-		config:      config{},
-		infoLog:     log.New(os.Stdout, "INFO\t", log.Ldate|log.Ltime),
-		errorLog:    log.New(os.Stdout, "ERROR\t", log.Ldate|log.Ltime),
+		config:      defaultConfig(),
+		logger:      discardLogger(),
+		metrics:     newMetrics(testDB, "postgres"),
 		models:      data.New(testDB),
 		environment: "development",
 	}
 
 	os.Exit(m.Run())
 }
+
+// discardLogger returns a logger which writes nothing, to keep test output readable
+func discardLogger() *slog.Logger {
+	return newLogger(io.Discard, "development", slog.LevelDebug)
+}
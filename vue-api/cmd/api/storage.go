@@ -0,0 +1,104 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"os"
+	"vue-api/internal/data"
+	"vue-api/internal/driver"
+	"vue-api/internal/migrate"
+	"vue-api/internal/seed"
+)
+
+// errNoDatabase is returned by commands which need a database when the data is kept in memory
+var errNoDatabase = errors.New("there is no database: db.driver is memory")
+
+// storage is where the data is kept, as chosen by db.driver
+type storage struct {
+	models data.Models
+
+	// db and migrator are nil when the data is kept in memory
+	db       *sql.DB
+	migrator *migrate.Migrator
+}
+
+// openStorage connects to the postgres or sqlite database set up in cfg, or creates an empty
+// in-memory store
+func openStorage(cfg config) (*storage, error) {
+	if cfg.db.driver == "memory" {
+		return &storage{models: data.NewMemory()}, nil
+	}
+
+	opts := driver.PoolOptions{
+		MaxOpenConns:    cfg.db.maxOpenConns,
+		MaxIdleConns:    cfg.db.maxIdleConns,
+		ConnMaxLifetime: cfg.db.connMaxLifetime,
+		ConnMaxIdleTime: cfg.db.connMaxIdleTime,
+	}
+
+	if cfg.db.driver == "sqlite" {
+		db, err := driver.ConnectSQLite(cfg.db.dsn, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &storage{models: data.New(db.SQL), db: db.SQL, migrator: migrate.NewSQLite(db.SQL)}, nil
+	}
+
+	db, err := driver.ConnectPostgres(cfg.db.dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &storage{models: data.New(db.SQL), db: db.SQL, migrator: migrate.New(db.SQL)}, nil
+}
+
+// ping checks the database can be reached, and is nil when there is no database
+func (s *storage) ping() func(ctx context.Context) error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.PingContext
+}
+
+// Close closes the database pool, if there is one
+func (s *storage) Close() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+// seedMemory fills an in-memory store with the default sample data, so that the api has
+// something to serve, and tells which account to sign in with. The password is only printed
+// when someone is at the terminal to read it; otherwise it would end up wherever the logs are
+// kept, so it is only logged when debugging
+func (app *application) seedMemory(ctx context.Context) error {
+	password := generatePassword()
+
+	result, added, err := app.seed(ctx, seed.DefaultOptions(), password)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Info("seeded the in-memory store", "authors", result.authors, "genres", result.genres,
+		"books", result.books, "users", added)
+
+	if isTerminal(os.Stderr) {
+		fmt.Fprintf(os.Stderr, "sign in as %s with the password %s\n", seed.AdminEmail, password)
+		return nil
+	}
+
+	app.logger.Info("sign in as the administrator; the password is only logged at debug level, change it once signed in",
+		"email", seed.AdminEmail)
+	app.logger.Debug("password of the administrator", "email", seed.AdminEmail, "password", password)
+
+	return nil
+}
+
+// isTerminal reports whether f is a terminal, rather than a file or a pipe
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,178 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"vue-api/internal/data"
+	"vue-api/internal/seed"
+)
+
+// newMemoryTestApp returns an application which keeps its data in memory, seeded with a small
+// catalogue, and the password of the seeded users
+func newMemoryTestApp(t *testing.T) (*application, string) {
+	t.Helper()
+
+	cfg := defaultConfig()
+	cfg.db.driver = "memory"
+	cfg.staticPath = t.TempDir()
+	if err := os.Mkdir(filepath.Join(cfg.staticPath, "covers"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	app := &application{config: cfg, logger: discardLogger(), metrics: newMetrics(nil, ""), models: data.NewMemory(), environment: "development"}
+	app.checks = app.readinessChecks(nil)
+
+	password := generatePassword()
+	_, _, err := app.seed(context.Background(), seed.Options{Seed: 1, Authors: 2, Genres: 2, Books: 3, Users: 2}, password)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return app, password
+}
+
+// call sends a request through the routes of app, and decodes the data of the json response
+func call(t *testing.T, routes http.Handler, method, path, token string, body interface{}, result interface{}) int {
+	t.Helper()
+
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &payload)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, req)
+
+	if result != nil && rr.Body.Len() > 0 {
+		response := struct {
+			Data json.RawMessage `json:"data"`
+		}{}
+		if err := json.Unmarshal(rr.Body.Bytes(), &response); err != nil {
+			t.Fatalf("%s %s: could not decode %q: %v", method, path, rr.Body.String(), err)
+		}
+		if len(response.Data) > 0 {
+			if err := json.Unmarshal(response.Data, result); err != nil {
+				t.Fatalf("%s %s: could not decode %s: %v", method, path, response.Data, err)
+			}
+		}
+	}
+
+	return rr.Code
+}
+
+// login logs in through routes as the seeded administrator, and returns the token
+func login(t *testing.T, routes http.Handler, password string) string {
+	t.Helper()
+
+	var result struct {
+		Token data.Token `json:"token"`
+	}
+	credentials := map[string]string{"email": seed.AdminEmail, "password": password}
+	if status := call(t, routes, http.MethodPost, "/users/login", "", credentials, &result); status != http.StatusOK {
+		t.Fatalf("expected the seeded administrator to log in, got status %d", status)
+	}
+
+	return result.Token.Token
+}
+
+func TestMemoryStorage_endToEnd(t *testing.T) {
+	app, password := newMemoryTestApp(t)
+	routes := app.routes()
+
+	var list struct {
+		Books []data.Book `json:"books"`
+	}
+	if status := call(t, routes, http.MethodGet, "/api/v1/books", "", nil, &list); status != http.StatusOK || len(list.Books) != 3 {
+		t.Fatalf("expected the 3 seeded books, got status %d and %d book(s)", status, len(list.Books))
+	}
+
+	token := login(t, routes, password)
+
+	if status := call(t, routes, http.MethodPost, "/api/v1/books", "", nil, nil); status != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a token, got %d", http.StatusUnauthorized, status)
+	}
+
+	var created struct {
+		Book data.Book `json:"book"`
+	}
+	input := bookInput{Title: "A Memorable Book", AuthorID: list.Books[0].AuthorID, PublicationYear: 2001, Description: "Kept in memory."}
+	if status := call(t, routes, http.MethodPost, "/api/v1/books", token, input, &created); status != http.StatusCreated {
+		t.Fatalf("expected the book to be created, got status %d", status)
+	}
+
+	bookPath := fmt.Sprintf("/api/v1/books/%d", created.Book.ID)
+
+	var fetched struct {
+		Book data.Book `json:"book"`
+	}
+	call(t, routes, http.MethodGet, bookPath, "", nil, &fetched)
+	if fetched.Book.Slug != "a-memorable-book" || fetched.Book.Author.AuthorName != list.Books[0].Author.AuthorName {
+		t.Errorf("expected the created book with its author, got %+v", fetched.Book)
+	}
+
+	if status := call(t, routes, http.MethodDelete, bookPath, token, nil, nil); status != http.StatusNoContent {
+		t.Errorf("expected the book to be deleted, got status %d", status)
+	}
+
+	if status := call(t, routes, http.MethodGet, bookPath, "", nil, nil); status != http.StatusNotFound {
+		t.Errorf("expected status %d for a book in the trash, got %d", http.StatusNotFound, status)
+	}
+
+	if status := call(t, routes, http.MethodPost, bookPath+"/restore", token, nil, nil); status != http.StatusOK {
+		t.Errorf("expected the book to be restored, got status %d", status)
+	}
+
+	var health healthResponse
+	rr := httptest.NewRecorder()
+	routes.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	_ = json.Unmarshal(rr.Body.Bytes(), &health)
+	if rr.Code != http.StatusOK || len(health.Checks) != 1 {
+		t.Errorf("expected only the static_path check to run and pass, got status %d and %+v", rr.Code, health.Checks)
+	}
+}
+
+func Test_migrateCommand_memory(t *testing.T) {
+	app, _ := newMemoryTestApp(t)
+
+	var out bytes.Buffer
+	if err := app.runCommand(context.Background(), []string{"migrate", "up"}, &out); err != errNoDatabase {
+		t.Errorf("expected %v, got %v", errNoDatabase, err)
+	}
+}
+
+func Test_seedMemory_password(t *testing.T) {
+	if isTerminal(os.Stderr) {
+		t.Skip("the password is printed rather than logged when run from a terminal")
+	}
+
+	var logs bytes.Buffer
+	app := &application{config: defaultConfig(), logger: newLogger(&logs, "production", slog.LevelInfo), models: data.NewMemory()}
+	app.config.staticPath = t.TempDir()
+	if err := os.Mkdir(filepath.Join(app.config.staticPath, "covers"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := app.seedMemory(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(logs.String(), seed.AdminEmail) || strings.Contains(logs.String(), `"password"`) {
+		t.Errorf("expected the administrator to be named, but not their password, got %s", logs.String())
+	}
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeSelfSignedCert writes a self-signed certificate for localhost, with the given common
+// name, to cert.pem and key.pem in dir. The certificate is returned so that clients can trust it
+func writeSelfSignedCert(t *testing.T, dir, commonName string) (certFile, keyFile string, cert *x509.Certificate) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err = x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return certFile, keyFile, cert
+}
+
+// touch moves the modification time of the files forward, since a rewrite within the same
+// clock tick would otherwise go unnoticed
+func touch(t *testing.T, offset time.Duration, files ...string) {
+	t.Helper()
+
+	when := time.Now().Add(offset)
+	for _, f := range files {
+		if err := os.Chtimes(f, when, when); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func currentCommonName(t *testing.T, c *certReloader) string {
+	t.Helper()
+
+	cert, err := c.GetCertificate(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func Test_certReloader(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, _ := writeSelfSignedCert(t, dir, "first")
+
+	certs, err := newCertReloader(certFile, keyFile, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name := currentCommonName(t, certs); name != "first" {
+		t.Errorf("expected the first certificate but got %s", name)
+	}
+
+	if reloaded, err := certs.reload(); reloaded || err != nil {
+		t.Errorf("expected unchanged files to be left alone, got %t, %v", reloaded, err)
+	}
+
+	writeSelfSignedCert(t, dir, "second")
+	touch(t, time.Minute, certFile, keyFile)
+
+	if reloaded, err := certs.reload(); !reloaded || err != nil {
+		t.Errorf("expected the renewed certificate to be loaded, got %t, %v", reloaded, err)
+	}
+
+	if name := currentCommonName(t, certs); name != "second" {
+		t.Errorf("expected the second certificate but got %s", name)
+	}
+
+	// a half written renewal keeps the certificate being served
+	if err := os.WriteFile(keyFile, []byte("not a key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	touch(t, 2*time.Minute, keyFile)
+
+	if _, err := certs.reload(); err == nil {
+		t.Error("expected an error for a broken key file")
+	}
+
+	if name := currentCommonName(t, certs); name != "second" {
+		t.Errorf("expected the second certificate to be kept but got %s", name)
+	}
+}
+
+func Test_newCertReloader_missingFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := newCertReloader(filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem"), discardLogger()); err == nil {
+		t.Error("expected an error for missing certificate files")
+	}
+}
+
+func Test_serveUntilDone_tls(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile, cert := writeSelfSignedCert(t, dir, "localhost")
+
+	app := newServerTestApp(5 * time.Second)
+
+	certs, err := newCertReloader(certFile, keyFile, app.logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srv := app.newServer(app.hsts(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Proto))
+	})))
+	srv.TLSConfig = tlsConfig(certs)
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- app.serveUntilDone(ctx, boundServer{srv: srv, ln: ln})
+	}()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	client := &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{RootCAs: pool},
+			ForceAttemptHTTP2: true,
+		},
+	}
+
+	resp, err := client.Get("https://" + ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+
+	if resp.ProtoMajor != 2 || string(body) != "HTTP/2.0" {
+		t.Errorf("expected http/2 but got %s (%s)", resp.Proto, body)
+	}
+
+	if hsts := resp.Header.Get("Strict-Transport-Security"); hsts != "max-age=15552000" {
+		t.Errorf("expected an hsts header but got %q", hsts)
+	}
+
+	cancel()
+	client.CloseIdleConnections()
+
+	if err := <-served; err != nil {
+		t.Errorf("expected a clean shutdown but got %v", err)
+	}
+}
+
+func Test_hsts_plainHTTP(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/", nil)
+
+	testApp.hsts(http.NotFoundHandler()).ServeHTTP(rr, req)
+
+	if hsts := rr.Header().Get("Strict-Transport-Security"); hsts != "" {
+		t.Errorf("expected no hsts header over plain http but got %q", hsts)
+	}
+}
+
+func Test_redirectToHTTPS(t *testing.T) {
+	app := newServerTestApp(time.Second)
+
+	var tests = []struct {
+		name     string
+		port     int
+		host     string
+		target   string
+		location string
+	}{
+		{"custom port", 8443, "example.com:8080", "/books?page=2", "https://example.com:8443/books?page=2"},
+		{"default port", 443, "example.com", "/users/login", "https://example.com/users/login"},
+		{"ipv6", 8443, "[::1]:80", "/", "https://[::1]:8443/"},
+	}
+
+	for _, e := range tests {
+		app.config.port = e.port
+
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", e.target, nil)
+		req.Host = e.host
+
+		app.redirectToHTTPS(rr, req)
+
+		if rr.Code != http.StatusPermanentRedirect {
+			t.Errorf("%s: expected status 308 but got %d", e.name, rr.Code)
+		}
+
+		if location := rr.Header().Get("Location"); location != e.location {
+			t.Errorf("%s: expected %s but got %s", e.name, e.location, location)
+		}
+	}
+}
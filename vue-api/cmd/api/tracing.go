@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// serviceName is the name the api's traces are reported under
+const serviceName = "vue-api"
+
+// tracerName names the tracer of the api's own spans. It is looked up for every span rather than
+// once, so that spans go to whichever tracer provider is installed at the time
+const tracerName = "vue-api/cmd/api"
+
+// setupTracing installs the global tracer provider and propagator. Spans are exported over OTLP
+// when tracing.otlp_endpoint is set; when it is not, they are still made, so that the trace ids
+// in the logs tie together the lines of a request, but they go nowhere. The function returned
+// flushes the spans yet to be exported, and must be called before exiting
+func setupTracing(ctx context.Context, cfg config) (func(context.Context) error, error) {
+	var processors []sdktrace.SpanProcessor
+
+	if cfg.tracing.otlpEndpoint != "" {
+		exporter, err := otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(cfg.tracing.otlpEndpoint))
+		if err != nil {
+			return nil, err
+		}
+		processors = append(processors, sdktrace.NewBatchSpanProcessor(exporter))
+	}
+
+	provider := newTracerProvider(processors...)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// newTracerProvider returns a tracer provider for the api, which hands every span to processors.
+// Requests are sampled as their callers ask, and every one is when there is no caller's say
+func newTracerProvider(processors ...sdktrace.SpanProcessor) *sdktrace.TracerProvider {
+	opts := []sdktrace.TracerProviderOption{
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.AlwaysSample())),
+	}
+	for _, p := range processors {
+		opts = append(opts, sdktrace.WithSpanProcessor(p))
+	}
+
+	return sdktrace.NewTracerProvider(opts...)
+}
+
+// traceRequests starts a span for every request, continuing the trace of the caller if the
+// request carries a traceparent header. Like instrument, it must be used on the chi router: the
+// span is named after the method and the route pattern the request matched, such as
+// GET /api/v1/books/{id}, which is only known once the request has been routed
+func (app *application) traceRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := otel.Tracer(tracerName).Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				semconv.HTTPRequestMethodKey.String(r.Method),
+				semconv.URLPath(r.URL.Path),
+				attribute.String("request_id", requestIDFrom(ctx)),
+			))
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		defer func() {
+			route := routePattern(r)
+			span.SetName(r.Method + " " + route)
+			if route != unmatchedRoute {
+				span.SetAttributes(semconv.HTTPRoute(route))
+			}
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+			if status >= http.StatusInternalServerError {
+				span.SetStatus(codes.Error, http.StatusText(status))
+			}
+
+			span.End()
+		}()
+
+		next.ServeHTTP(ww, r.WithContext(ctx))
+	})
+}
+
+// routePattern returns the route pattern a request matched, or unmatchedRoute if it matched none
+// or has not been routed yet
+func routePattern(r *http.Request) string {
+	if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+		return rctx.RoutePattern()
+	}
+	return unmatchedRoute
+}
+
+// startSpan starts a span of the api's own, such as one around reading a cover, as a child of
+// the span in ctx
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endSpan ends span, marking it as failed if err is not nil
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans installs a tracer provider which keeps every span ended until the test is over, in
+// the exporter returned
+func recordSpans(t *testing.T) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	exporter := tracetest.NewInMemoryExporter()
+	provider := newTracerProvider(sdktrace.NewSimpleSpanProcessor(exporter))
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return exporter
+}
+
+// spanNamed returns the first span recorded with the given name, failing the test if there is none
+func spanNamed(t *testing.T, spans tracetest.SpanStubs, name string) tracetest.SpanStub {
+	t.Helper()
+
+	var names []string
+	for _, s := range spans {
+		if s.Name == name {
+			return s
+		}
+		names = append(names, s.Name)
+	}
+
+	t.Fatalf("expected a span named %q, but got %v", name, names)
+	return tracetest.SpanStub{}
+}
+
+// attributeOf returns the value of the attribute with the given key, as a string
+func attributeOf(s tracetest.SpanStub, key attribute.Key) string {
+	for _, kv := range s.Attributes {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+func Test_setupTracing_otlp(t *testing.T) {
+	exported := make(chan string, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case exported <- r.URL.Path:
+		default:
+		}
+	}))
+	defer collector.Close()
+
+	previousProvider, previousPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	defer func() {
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	}()
+
+	cfg := defaultConfig()
+	cfg.tracing.otlpEndpoint = collector.URL
+	shutdown, err := setupTracing(context.Background(), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, span := startSpan(context.Background(), "test")
+	span.End()
+
+	// spans are batched, and shutting down sends those left
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case path := <-exported:
+		if path != "/v1/traces" {
+			t.Errorf("expected spans to be sent to /v1/traces, got %s", path)
+		}
+	default:
+		t.Error("expected the span to be exported to the collector")
+	}
+}
+
+func Test_traceRequests(t *testing.T) {
+	exporter := recordSpans(t)
+
+	app, _ := newMemoryTestApp(t)
+	var logs bytes.Buffer
+	app.logger = newLogger(&logs, "development", slog.LevelInfo)
+	routes := app.routes()
+
+	// a request made as part of the caller's trace
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("GET", "/api/v1/books/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	routes.ServeHTTP(httptest.NewRecorder(), req)
+
+	span := spanNamed(t, exporter.GetSpans(), "GET /api/v1/books/{id}")
+	if span.SpanContext.TraceID().String() != traceID {
+		t.Errorf("expected the span to continue trace %s, but it is part of %s", traceID, span.SpanContext.TraceID())
+	}
+	if span.Parent.SpanID().String() != "00f067aa0ba902b7" {
+		t.Errorf("expected the caller's span to be the parent, got %s", span.Parent.SpanID())
+	}
+	if route := attributeOf(span, "http.route"); route != "/api/v1/books/{id}" {
+		t.Errorf("expected http.route /api/v1/books/{id}, got %q", route)
+	}
+	if status := attributeOf(span, "http.response.status_code"); status != "200" {
+		t.Errorf("expected http.response.status_code 200, got %q", status)
+	}
+	if attributeOf(span, "request_id") == "" {
+		t.Error("expected the request id to be recorded on the span")
+	}
+
+	if !strings.Contains(logs.String(), "trace_id="+traceID) {
+		t.Errorf("expected the trace id in the request log, got %s", logs.String())
+	}
+
+	// paths which match no route do not become span names
+	exporter.Reset()
+	routes.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/no/such/page", nil))
+
+	span = spanNamed(t, exporter.GetSpans(), "GET unmatched")
+	if attributeOf(span, "http.route") != "" {
+		t.Error("expected no http.route on a request which matched no route")
+	}
+	if status := attributeOf(span, "http.response.status_code"); status != "404" {
+		t.Errorf("expected http.response.status_code 404, got %q", status)
+	}
+}
+
+func Test_traceRequests_failure(t *testing.T) {
+	exporter := recordSpans(t)
+
+	app := &application{config: testApp.config, logger: discardLogger(), metrics: newMetrics(nil, "")}
+	handler := app.traceRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("POST", "/anything", nil))
+
+	spans := exporter.GetSpans()
+	if len(spans) != 1 {
+		t.Fatalf("expected one span, got %d", len(spans))
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Errorf("expected a 500 to mark the span as failed, got %v", spans[0].Status)
+	}
+}
+
+func Test_coverSpans(t *testing.T) {
+	exporter := recordSpans(t)
+
+	app, _ := newMemoryTestApp(t)
+	ctx := context.Background()
+	// leave out the covers written while seeding
+	exporter.Reset()
+
+	if err := app.writeCover(ctx, "a-book", []byte("jpeg")); err != nil {
+		t.Fatal(err)
+	}
+	if cover, err := app.readCover(ctx, "a-book"); err != nil || string(cover) != "jpeg" {
+		t.Fatalf("expected the cover to be read back, got %q and %v", cover, err)
+	}
+	if _, err := app.readCover(ctx, "no-such-book"); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected os.ErrNotExist for a book without a cover, got %v", err)
+	}
+	if err := app.removeCover(ctx, "a-book.jpg"); err != nil {
+		t.Fatal(err)
+	}
+	if err := app.removeCover(ctx, "a-book.jpg"); err == nil {
+		t.Fatal("expected removing a missing cover to fail")
+	}
+
+	spans := exporter.GetSpans()
+	var names []string
+	for _, s := range spans {
+		names = append(names, s.Name)
+	}
+	if strings.Join(names, ",") != "cover.write,cover.read,cover.read,cover.remove,cover.remove" {
+		t.Fatalf("unexpected spans %v", names)
+	}
+
+	if path := attributeOf(spans[0], "file.path"); path != app.coverPath("a-book") {
+		t.Errorf("expected file.path %s, got %q", app.coverPath("a-book"), path)
+	}
+
+	// a book without a cover is not a failure, but a missing file which should be there is
+	for i, expected := range []codes.Code{codes.Unset, codes.Unset, codes.Unset, codes.Unset, codes.Error} {
+		if spans[i].Status.Code != expected {
+			t.Errorf("%s: expected status %v, got %v", spans[i].Name, expected, spans[i].Status.Code)
+		}
+	}
+}
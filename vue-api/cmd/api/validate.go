@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+	"vue-api/internal/data"
+	"vue-api/internal/i18n"
+	"vue-api/internal/validator"
+)
+
+// validationError is returned when a request payload fails validation. errorJSON sends the
+// messages for each field back with a 422 Unprocessable Entity response
+type validationError struct {
+	errors map[string][]string
+}
+
+func (e *validationError) Error() string {
+	return "the supplied data is invalid"
+}
+
+// userRules holds the fields of a user which are checked before the user is saved
+type userRules struct {
+	Email     string `json:"email" validate:"required,email,max=255"`
+	FirstName string `json:"first_name" validate:"required,max=255"`
+	LastName  string `json:"last_name" validate:"required,max=255"`
+	Password  string `json:"password" validate:"omitempty,min=8,max_bytes=72"`
+	Active    int    `json:"active" validate:"oneof=0|1"`
+	Language  string `json:"language" validate:"omitempty,oneof=en|es"`
+}
+
+// authorRules holds the fields of an author which are checked before the author is saved
+type authorRules struct {
+	AuthorName string `json:"author_name" validate:"required,max=512"`
+}
+
+// genreRules holds the fields of a genre which are checked before the genre is saved
+type genreRules struct {
+	GenreName string `json:"genre_name" validate:"required,max=255"`
+}
+
+// validate checks input against the rules in its validate tags, then runs any further checks
+// which need more than the payload itself, such as looking records up in the database. It
+// returns a *validationError if any check failed, with messages in the language of the request
+func (app *application) validate(r *http.Request, input interface{}, checks ...func(v *validator.Validator) error) error {
+	return app.validateIn(printerFor(r), input, checks...)
+}
+
+// validateIn is validate for callers without a request, with messages in the language of printer
+func (app *application) validateIn(printer i18n.Printer, input interface{}, checks ...func(v *validator.Validator) error) error {
+	v := validator.New(printer)
+	v.Struct(input)
+
+	for _, check := range checks {
+		if err := check(v); err != nil {
+			return err
+		}
+	}
+
+	if !v.Valid() {
+		return &validationError{errors: v.Errors}
+	}
+
+	return nil
+}
+
+// readValidJSON decodes the request body into dst, and validates the result
+func (app *application) readValidJSON(w http.ResponseWriter, r *http.Request, dst interface{}) error {
+	err := app.readJSON(w, r, dst)
+	if err != nil {
+		return err
+	}
+
+	return app.validate(r, dst)
+}
+
+// validateBook checks a book before it is saved, including that its author and genres exist
+func (app *application) validateBook(r *http.Request, input bookInput) error {
+	return app.validate(r, input, func(v *validator.Validator) error {
+		v.Check(input.PublicationYear <= time.Now().Year()+1, "publication_year", "validation.future_year")
+
+		if input.CoverBase64 != "" {
+			_, err := base64.StdEncoding.DecodeString(input.CoverBase64)
+			v.Check(err == nil, "cover", "validation.base64_image")
+		}
+
+		if input.AuthorID > 0 {
+			_, err := app.models.Author.GetOne(r.Context(), input.AuthorID)
+			if errors.Is(err, sql.ErrNoRows) {
+				v.AddError("author_id", "validation.not_found")
+			} else if err != nil {
+				return err
+			}
+		}
+
+		for _, id := range input.GenreIDs {
+			_, err := app.models.Genre.GetOne(r.Context(), id)
+			if errors.Is(err, sql.ErrNoRows) {
+				v.AddError("genre_ids", "validation.genre_not_found")
+				break
+			} else if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// validateUser checks a user before it is saved. New users must be given a password
+func (app *application) validateUser(r *http.Request, input data.User) error {
+	return app.validateUserIn(r.Context(), printerFor(r), input)
+}
+
+// validateUserIn is validateUser for callers without a request
+func (app *application) validateUserIn(ctx context.Context, printer i18n.Printer, input data.User) error {
+	rules := userRules{
+		Email:     input.Email,
+		FirstName: input.FirstName,
+		LastName:  input.LastName,
+		Password:  input.Password,
+		Active:    input.Active,
+		Language:  input.Language,
+	}
+
+	return app.validateIn(printer, rules, func(v *validator.Validator) error {
+		if input.ID == 0 && input.Password == "" {
+			v.AddError("password", "validation.required")
+		}
+
+		if input.Email != "" {
+			existing, err := app.models.User.GetByEmail(ctx, input.Email)
+			if err == nil && existing.ID != input.ID {
+				v.AddError("email", "validation.email_in_use")
+			} else if err != nil && !errors.Is(err, sql.ErrNoRows) {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"vue-api/internal/data"
+)
+
+func Test_validationErrorResponse(t *testing.T) {
+	var tests = []struct {
+		name    string
+		handler http.HandlerFunc
+		body    string
+		fields  []string
+	}{
+		{"empty book", testApp.EditBook, `{"title": "", "publication_year": -5, "cover": "not base64!"}`, []string{"title", "author_id", "publication_year", "cover"}},
+		{"future book", testApp.EditBook, `{"title": "t", "publication_year": 3000}`, []string{"author_id", "publication_year"}},
+		{"empty login", testApp.Login, `{}`, []string{"email", "password"}},
+		{"missing id", testApp.DeleteBook, `{"id": 0}`, []string{"id"}},
+		{"negative id", testApp.RestoreUser, `{"id": -1}`, []string{"id"}},
+	}
+
+	for _, e := range tests {
+		rr := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/", strings.NewReader(e.body))
+
+		e.handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusUnprocessableEntity {
+			t.Errorf("%s: expected status 422 but got %d", e.name, rr.Code)
+			continue
+		}
+
+		var response jsonResponse
+		if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+			t.Fatal(err)
+		}
+
+		if !response.Error {
+			t.Errorf("%s: error should be true", e.name)
+		}
+
+		if len(response.Errors) != len(e.fields) {
+			t.Errorf("%s: expected errors for %v but got %v", e.name, e.fields, response.Errors)
+		}
+
+		for _, field := range e.fields {
+			if len(response.Errors[field]) == 0 {
+				t.Errorf("%s: expected an error for %s", e.name, field)
+			}
+		}
+	}
+}
+
+func Test_validateUser(t *testing.T) {
+	// a user without an email does not need to be looked up in the database
+	req, _ := http.NewRequest("POST", "/", nil)
+
+	err := testApp.validateUser(req, data.User{FirstName: "Jack", LastName: "Smith", Active: 2})
+
+	invalid, ok := err.(*validationError)
+	if !ok {
+		t.Fatalf("expected a validation error but got %v", err)
+	}
+
+	for _, field := range []string{"email", "password", "active"} {
+		if len(invalid.errors[field]) == 0 {
+			t.Errorf("expected an error for %s, got %v", field, invalid.errors)
+		}
+	}
+}
+
+func Test_checkPassword(t *testing.T) {
+	var tests = []struct {
+		name     string
+		password string
+		valid    bool
+	}{
+		{"72 bytes", strings.Repeat("a", 72), true},
+		{"73 bytes", strings.Repeat("a", 73), false},
+		// 40 characters, but 80 bytes, which is more than bcrypt takes
+		{"multibyte", strings.Repeat("é", 40), false},
+		{"too short", "abc", false},
+	}
+
+	for _, e := range tests {
+		err := testApp.checkPassword(e.password)
+		if e.valid && err != nil {
+			t.Errorf("%s: expected the password to be accepted, got %v", e.name, err)
+		}
+		if !e.valid && err == nil {
+			t.Errorf("%s: expected the password to be refused", e.name)
+		}
+	}
+}
+
+func Test_pagination_fieldErrors(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/api/v1/books?page=0&page_size=1000", nil)
+
+	_, _, _, err := pagination(req)
+
+	invalid, ok := err.(*validationError)
+	if !ok {
+		t.Fatalf("expected a validation error but got %v", err)
+	}
+
+	if len(invalid.errors["page"]) == 0 || len(invalid.errors["page_size"]) == 0 {
+		t.Errorf("expected errors for page and page_size, got %v", invalid.errors)
+	}
+}
+
+func Test_validationErrorResponse_translated(t *testing.T) {
+	rr := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/", strings.NewReader(`{}`))
+	req.Header.Set("Accept-Language", "es-ES,es;q=0.9,en;q=0.8")
+
+	testApp.Login(rr, req)
+
+	var response problem
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatal(err)
+	}
+
+	if response.Message != "los datos enviados no son válidos" {
+		t.Errorf("expected a Spanish message but got %q", response.Message)
+	}
+
+	if len(response.Errors["email"]) != 1 || response.Errors["email"][0] != "es obligatorio" {
+		t.Errorf("expected Spanish field errors but got %v", response.Errors)
+	}
+}
+
+func Test_printerFor(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/", nil)
+	req.Header.Set("Accept-Language", "es")
+
+	if lang := printerFor(req).Language(); lang != "es" {
+		t.Errorf("expected the Accept-Language header to give es but got %s", lang)
+	}
+
+	// a user's own preference wins over the header
+	user := &data.User{ID: 1, Language: "en"}
+	req = req.WithContext(context.WithValue(req.Context(), contextKeyUser, user))
+
+	if lang := printerFor(req).Language(); lang != "en" {
+		t.Errorf("expected the user's preference en but got %s", lang)
+	}
+}
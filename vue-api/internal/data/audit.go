@@ -0,0 +1,165 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// AuditEntry is one record in the audit log. Entries are only ever inserted; there is no
+// way to change or remove an entry once it has been written
+type AuditEntry struct {
+	ID         int             `json:"id"`
+	ActorID    int             `json:"actor_id"`
+	ActorEmail string          `json:"actor_email"`
+	Action     string          `json:"action"`
+	Entity     string          `json:"entity"`
+	EntityID   int             `json:"entity_id"`
+	Before     json.RawMessage `json:"before,omitempty"`
+	After      json.RawMessage `json:"after,omitempty"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	CreatedAt  time.Time       `json:"created_at"`
+}
+
+// AuditFilter holds the criteria used to search the audit log. Zero values are ignored, and
+// a PageSize of 0 returns every matching entry
+type AuditFilter struct {
+	ActorID  int        `json:"actor_id" validate:"min=0"`
+	Action   string     `json:"action"`
+	Entity   string     `json:"entity"`
+	EntityID int        `json:"entity_id" validate:"min=0"`
+	From     *time.Time `json:"from"`
+	To       *time.Time `json:"to"`
+	Page     int        `json:"page" validate:"min=0"`
+	PageSize int        `json:"page_size" validate:"min=0"`
+}
+
+// Insert appends one entry to the audit log
+func (s *sqlAudit) Insert(ctx context.Context, entry AuditEntry) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `insert into audit_log (actor_id, actor_email, action, entity, entity_id, before, after, ip, request_id, created_at)
+		values (nullif($1, 0), $2, $3, $4, $5, $6, $7, $8, $9, $10)`
+
+	_, err := s.db.ExecContext(ctx, stmt,
+		entry.ActorID,
+		entry.ActorEmail,
+		entry.Action,
+		entry.Entity,
+		entry.EntityID,
+		nullableJSON(entry.Before),
+		nullableJSON(entry.After),
+		entry.IP,
+		entry.RequestID,
+		time.Now(),
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Filter returns the audit log entries matching the given filter, newest first
+func (s *sqlAudit) Filter(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var where []string
+	var args []interface{}
+
+	addCondition := func(condition string, value interface{}) {
+		args = append(args, value)
+		where = append(where, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID > 0 {
+		addCondition("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		addCondition("action = $%d", filter.Action)
+	}
+	if filter.Entity != "" {
+		addCondition("entity = $%d", filter.Entity)
+	}
+	if filter.EntityID > 0 {
+		addCondition("entity_id = $%d", filter.EntityID)
+	}
+	if filter.From != nil {
+		addCondition("created_at >= $%d", *filter.From)
+	}
+	if filter.To != nil {
+		addCondition("created_at < $%d", *filter.To)
+	}
+
+	query := `select id, coalesce(actor_id, 0), actor_email, action, entity, entity_id,
+		coalesce(cast(before as text), ''), coalesce(cast(after as text), ''), ip, request_id, created_at
+		from audit_log`
+
+	if len(where) > 0 {
+		query += " where " + strings.Join(where, " and ")
+	}
+
+	query += " order by id desc"
+
+	if filter.PageSize > 0 {
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+		args = append(args, filter.PageSize, (page-1)*filter.PageSize)
+		query += fmt.Sprintf(" limit $%d offset $%d", len(args)-1, len(args))
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []*AuditEntry
+	for rows.Next() {
+		var entry AuditEntry
+		var before, after string
+		err := rows.Scan(
+			&entry.ID,
+			&entry.ActorID,
+			&entry.ActorEmail,
+			&entry.Action,
+			&entry.Entity,
+			&entry.EntityID,
+			&before,
+			&after,
+			&entry.IP,
+			&entry.RequestID,
+			&entry.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if before != "" {
+			entry.Before = json.RawMessage(before)
+		}
+		if after != "" {
+			entry.After = json.RawMessage(after)
+		}
+
+		entries = append(entries, &entry)
+	}
+
+	return entries, nil
+}
+
+// nullableJSON converts an empty snapshot into a database null
+func nullableJSON(snapshot json.RawMessage) sql.NullString {
+	if len(snapshot) == 0 {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: string(snapshot), Valid: true}
+}
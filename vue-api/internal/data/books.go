@@ -3,6 +3,7 @@ package data
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"time"
 
@@ -11,17 +12,19 @@ import (
 
 // Book is the definition of a single book
 type Book struct {
-	ID              int       `json:"id"`
-	Title           string    `json:"title"`
-	AuthorID        int       `json:"author_id"`
-	PublicationYear int       `json:"publication_year"`
-	Slug            string    `json:"slug"`
-	Author          Author    `json:"author"`
-	Description     string    `json:"description"`
-	Genres          []Genre   `json:"genres"`
-	CreatedAt       time.Time `json:"created_at"`
-	UpdatedAt       time.Time `json:"updated_at"`
-	GenreIDs        []int     `json:"genre_ids,omitempty"`
+	ID              int        `json:"id"`
+	Title           string     `json:"title"`
+	AuthorID        int        `json:"author_id"`
+	PublicationYear int        `json:"publication_year"`
+	Slug            string     `json:"slug"`
+	Author          Author     `json:"author"`
+	Description     string     `json:"description"`
+	Genres          []Genre    `json:"genres"`
+	CreatedAt       time.Time  `json:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at"`
+	DeletedAt       *time.Time `json:"deleted_at,omitempty"`
+	Version         int        `json:"version"`
+	GenreIDs        []int      `json:"genre_ids,omitempty"`
 }
 
 // Author is the definition of a single author
@@ -40,20 +43,21 @@ type Genre struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
-// GetAll returns a slice of all books
-func (b *Book) GetAll() ([]*Book, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// GetAll returns a slice of all books that have not been deleted
+func (s *sqlBooks) GetAll(ctx context.Context) ([]*Book, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at,
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, b.version,
 			a.id, a.author_name, a.created_at, a.updated_at
 			from books b
 			left join authors a on (b.author_id = a.id)
+			where b.deleted_at is null
 			order by b.title`
 
 	var books []*Book
 
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -70,6 +74,7 @@ func (b *Book) GetAll() ([]*Book, error) {
 			&book.Description,
 			&book.CreatedAt,
 			&book.UpdatedAt,
+			&book.Version,
 			&book.Author.ID,
 			&book.Author.AuthorName,
 			&book.Author.CreatedAt,
@@ -79,7 +84,7 @@ func (b *Book) GetAll() ([]*Book, error) {
 		}
 
 		// get genres
-		genres, ids, err := b.genresForBook(book.ID)
+		genres, ids, err := genresForBook(ctx, s.db, book.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -93,23 +98,24 @@ func (b *Book) GetAll() ([]*Book, error) {
 }
 
 // GetAllPaginated returns a slice of all books, paginated by limit and offset
-func (b *Book) GetAllPaginated(page, pageSize int) ([]*Book, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlBooks) GetAllPaginated(ctx context.Context, page, pageSize int) ([]*Book, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	limit := pageSize
 	offset := (page - 1) * pageSize
 
-	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at,
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, b.version,
 			a.id, a.author_name, a.created_at, a.updated_at
 			from books b
 			left join authors a on (b.author_id = a.id)
+			where b.deleted_at is null
 			order by b.title
 			limit $1 offset $2`
 
 	var books []*Book
 
-	rows, err := db.QueryContext(ctx, query, limit, offset)
+	rows, err := s.db.QueryContext(ctx, query, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -126,6 +132,7 @@ func (b *Book) GetAllPaginated(page, pageSize int) ([]*Book, error) {
 			&book.Description,
 			&book.CreatedAt,
 			&book.UpdatedAt,
+			&book.Version,
 			&book.Author.ID,
 			&book.Author.AuthorName,
 			&book.Author.CreatedAt,
@@ -135,7 +142,7 @@ func (b *Book) GetAllPaginated(page, pageSize int) ([]*Book, error) {
 		}
 
 		// get genres
-		genres, ids, err := b.genresForBook(book.ID)
+		genres, ids, err := genresForBook(ctx, s.db, book.ID)
 		if err != nil {
 			return nil, err
 		}
@@ -149,17 +156,17 @@ func (b *Book) GetAllPaginated(page, pageSize int) ([]*Book, error) {
 }
 
 // GetOneById returns one book by its id
-func (b *Book) GetOneById(id int) (*Book, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlBooks) GetOneById(ctx context.Context, id int) (*Book, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at,
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, b.version,
 			a.id, a.author_name, a.created_at, a.updated_at
 			from books b
 			left join authors a on (b.author_id = a.id)
-			where b.id = $1`
+			where b.id = $1 and b.deleted_at is null`
 
-	row := db.QueryRowContext(ctx, query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	var book Book
 
@@ -172,6 +179,7 @@ func (b *Book) GetOneById(id int) (*Book, error) {
 		&book.Description,
 		&book.CreatedAt,
 		&book.UpdatedAt,
+		&book.Version,
 		&book.Author.ID,
 		&book.Author.AuthorName,
 		&book.Author.CreatedAt,
@@ -181,7 +189,7 @@ func (b *Book) GetOneById(id int) (*Book, error) {
 	}
 
 	// get genres
-	genres, ids, err := b.genresForBook(book.ID)
+	genres, ids, err := genresForBook(ctx, s.db, book.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -192,17 +200,17 @@ func (b *Book) GetOneById(id int) (*Book, error) {
 }
 
 // GetOneBySlug returns one book by slug
-func (b *Book) GetOneBySlug(slug string) (*Book, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlBooks) GetOneBySlug(ctx context.Context, slug string) (*Book, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at,
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, b.version,
 			a.id, a.author_name, a.created_at, a.updated_at
 			from books b
 			left join authors a on (b.author_id = a.id)
-			where b.slug = $1`
+			where b.slug = $1 and b.deleted_at is null`
 
-	row := db.QueryRowContext(ctx, query, slug)
+	row := s.db.QueryRowContext(ctx, query, slug)
 
 	var book Book
 
@@ -215,6 +223,7 @@ func (b *Book) GetOneBySlug(slug string) (*Book, error) {
 		&book.Description,
 		&book.CreatedAt,
 		&book.UpdatedAt,
+		&book.Version,
 		&book.Author.ID,
 		&book.Author.AuthorName,
 		&book.Author.CreatedAt,
@@ -224,7 +233,7 @@ func (b *Book) GetOneBySlug(slug string) (*Book, error) {
 	}
 
 	// get genres
-	genres, ids, err := b.genresForBook(book.ID)
+	genres, ids, err := genresForBook(ctx, s.db, book.ID)
 	if err != nil {
 		return nil, err
 	}
@@ -235,8 +244,8 @@ func (b *Book) GetOneBySlug(slug string) (*Book, error) {
 }
 
 // genresForBook returns all genres for a given book id
-func (b *Book) genresForBook(id int) ([]Genre, []int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func genresForBook(ctx context.Context, db *tracedDB, id int) ([]Genre, []int, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	// get genres
@@ -270,15 +279,15 @@ func (b *Book) genresForBook(id int) ([]Genre, []int, error) {
 }
 
 // Insert saves one book to the database
-func (b *Book) Insert(book Book) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlBooks) Insert(ctx context.Context, book Book) (int, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	stmt := `insert into books (title, author_id, publication_year, slug, description, created_at, updated_at)
 			values ($1, $2, $3, $4, $5, $6, $7) returning id`
 
 	var newID int
-	err := db.QueryRowContext(ctx, stmt,
+	err := s.db.QueryRowContext(ctx, stmt,
 		book.Title,
 		book.AuthorID,
 		book.PublicationYear,
@@ -294,7 +303,7 @@ func (b *Book) Insert(book Book) (int, error) {
 	// update genres using genre ids
 	if len(book.GenreIDs) > 0 {
 		stmt = `delete from books_genres where book_id = $1`
-		_, err := db.ExecContext(ctx, stmt, book.ID)
+		_, err := s.db.ExecContext(ctx, stmt, book.ID)
 		if err != nil {
 			return newID, fmt.Errorf("book updated, but genres not: %s", err.Error())
 		}
@@ -303,7 +312,7 @@ func (b *Book) Insert(book Book) (int, error) {
 		for _, x := range book.GenreIDs {
 			stmt = `insert into books_genres (book_id, genre_id, created_at, updated_at)
 				values ($1, $2, $3, $4)`
-			_, err = db.ExecContext(ctx, stmt, newID, x, time.Now(), time.Now())
+			_, err = s.db.ExecContext(ctx, stmt, newID, x, time.Now(), time.Now())
 			if err != nil {
 				return newID, fmt.Errorf("book updated, but genres not: %s", err.Error())
 			}
@@ -313,74 +322,200 @@ func (b *Book) Insert(book Book) (int, error) {
 	return newID, nil
 }
 
-// Update updates one book in the database
-func (b *Book) Update() error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// Update updates one book in the database. If b has a version set, the update only happens if
+// the stored book is still at that version, and ErrEditConflict is returned otherwise; a version
+// of 0 updates the book regardless. sql.ErrNoRows is returned when there is no such book, or it
+// is in the trash. On success, b holds the new version. Genres are replaced by GenreIDs, unless
+// GenreIDs is nil
+func (s *sqlBooks) Update(ctx context.Context, b *Book) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	stmt := `update books set
-		title = $1,
-		author_id = $2,
-		publication_year = $3,
-        slug = $4,
-    	description = $5,
-		updated_at = $6
-		where id = $7`
-
-	_, err := db.ExecContext(ctx, stmt,
-		b.Title,
-		b.AuthorID,
-		b.PublicationYear,
-		slugify.Slugify(b.Title),
-		b.Description,
-		time.Now(),
-		b.ID)
-	if err != nil {
-		return err
-	}
-
-	// update genres using genre ids
-	if len(b.GenreIDs) > 0 {
-		stmt = `delete from books_genres where book_id = $1`
-		_, err := db.ExecContext(ctx, stmt, b.ID)
+	return s.db.inTx(ctx, func(tx *tracedDB) error {
+		stmt := `update books set
+			title = $1,
+			author_id = $2,
+			publication_year = $3,
+			slug = $4,
+			description = $5,
+			updated_at = $6,
+			version = version + 1
+			where id = $7 and deleted_at is null and ($8 = 0 or version = $8)
+			returning version`
+
+		err := tx.QueryRowContext(ctx, stmt,
+			b.Title,
+			b.AuthorID,
+			b.PublicationYear,
+			slugify.Slugify(b.Title),
+			b.Description,
+			time.Now(),
+			b.ID,
+			b.Version).Scan(&b.Version)
 		if err != nil {
-			return fmt.Errorf("book updated, but genres not: %s", err.Error())
+			if errors.Is(err, sql.ErrNoRows) {
+				return missedUpdate(ctx, tx, "books", b.ID)
+			}
+			return err
 		}
 
-		// add new genres
-		for _, x := range b.GenreIDs {
-			stmt = `insert into books_genres (book_id, genre_id, created_at, updated_at)
-				values ($1, $2, $3, $4)`
-			_, err = db.ExecContext(ctx, stmt, b.ID, x, time.Now(), time.Now())
+		// update genres using genre ids; a nil slice leaves them alone, and an empty one removes them all
+		if b.GenreIDs != nil {
+			stmt = `delete from books_genres where book_id = $1`
+			_, err := tx.ExecContext(ctx, stmt, b.ID)
 			if err != nil {
 				return fmt.Errorf("book updated, but genres not: %s", err.Error())
 			}
+
+			// add new genres
+			for _, x := range b.GenreIDs {
+				stmt = `insert into books_genres (book_id, genre_id, created_at, updated_at)
+					values ($1, $2, $3, $4)`
+				_, err = tx.ExecContext(ctx, stmt, b.ID, x, time.Now(), time.Now())
+				if err != nil {
+					return fmt.Errorf("book updated, but genres not: %s", err.Error())
+				}
+			}
 		}
+
+		return nil
+	})
+}
+
+// DeleteByID moves a book to the trash by setting deleted_at. The book, and its genres,
+// are kept until the trash is purged, so it can be restored
+func (s *sqlBooks) DeleteByID(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `update books set deleted_at = $1 where id = $2 and deleted_at is null`
+	result, err := s.db.ExecContext(ctx, stmt, time.Now(), id)
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return expectOneRow(result)
 }
 
-// DeleteByID deletes a book by id
-func (b *Book) DeleteByID(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// GetAllDeleted returns a slice of all books in the trash, most recently deleted first
+func (s *sqlBooks) GetAllDeleted(ctx context.Context) ([]*Book, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	stmt := `delete from books where id = $1`
-	_, err := db.ExecContext(ctx, stmt, id)
+	query := `select b.id, b.title, b.author_id, b.publication_year, b.slug, b.description, b.created_at, b.updated_at, b.version,
+			b.deleted_at, a.id, a.author_name, a.created_at, a.updated_at
+			from books b
+			left join authors a on (b.author_id = a.id)
+			where b.deleted_at is not null
+			order by b.deleted_at desc`
+
+	var books []*Book
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var book Book
+		err := rows.Scan(
+			&book.ID,
+			&book.Title,
+			&book.AuthorID,
+			&book.PublicationYear,
+			&book.Slug,
+			&book.Description,
+			&book.CreatedAt,
+			&book.UpdatedAt,
+			&book.Version,
+			&book.DeletedAt,
+			&book.Author.ID,
+			&book.Author.AuthorName,
+			&book.Author.CreatedAt,
+			&book.Author.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		books = append(books, &book)
+	}
+
+	return books, nil
+}
+
+// Restore takes a book out of the trash
+func (s *sqlBooks) Restore(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `update books set deleted_at = null, updated_at = $1 where id = $2 and deleted_at is not null`
+	result, err := s.db.ExecContext(ctx, stmt, time.Now(), id)
 	if err != nil {
 		return err
 	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return ErrNotInTrash
+	}
+
 	return nil
 }
 
+// PurgeDeleted permanently removes books which were moved to the trash before the given
+// time, along with their genres, and returns the slugs of the books removed, so that their
+// covers can be removed too
+func (s *sqlBooks) PurgeDeleted(ctx context.Context, before time.Time) ([]string, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var slugs []string
+	err := s.db.inTx(ctx, func(tx *tracedDB) error {
+		rows, err := tx.QueryContext(ctx, `select slug from books where deleted_at < $1`, before)
+		if err != nil {
+			return err
+		}
+
+		for rows.Next() {
+			var slug string
+			if err := rows.Scan(&slug); err != nil {
+				rows.Close()
+				return err
+			}
+			slugs = append(slugs, slug)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		stmt := `delete from books_genres where book_id in (select id from books where deleted_at < $1)`
+		if _, err := tx.ExecContext(ctx, stmt, before); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `delete from books where deleted_at < $1`, before)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return slugs, nil
+}
+
 // All returns a list of all authors
-func (a *Author) All() ([]*Author, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlAuthors) All(ctx context.Context) ([]*Author, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	query := `select id, author_name, created_at, updated_at  from authors order by author_name`
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, err
 	}
@@ -397,3 +532,201 @@ func (a *Author) All() ([]*Author, error) {
 	}
 	return authors, nil
 }
+
+// GetOne returns one author by id
+func (s *sqlAuthors) GetOne(ctx context.Context, id int) (*Author, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select id, author_name, created_at, updated_at from authors where id = $1`
+
+	var author Author
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&author.ID, &author.AuthorName, &author.CreatedAt, &author.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &author, nil
+}
+
+// Insert saves one author to the database, and returns the new id
+func (s *sqlAuthors) Insert(ctx context.Context, author Author) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `insert into authors (author_name, created_at, updated_at) values ($1, $2, $3) returning id`
+
+	var newID int
+	err := s.db.QueryRowContext(ctx, stmt, author.AuthorName, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// Update updates one author in the database
+func (s *sqlAuthors) Update(ctx context.Context, a *Author) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `update authors set author_name = $1, updated_at = $2 where id = $3`
+
+	result, err := s.db.ExecContext(ctx, stmt, a.AuthorName, time.Now(), a.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// DeleteByID deletes an author by id. Authors that still have books, including books
+// in the trash, cannot be deleted, and ErrInUse is returned
+func (s *sqlAuthors) DeleteByID(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var inUse bool
+	err := s.db.QueryRowContext(ctx, `select exists(select 1 from books where author_id = $1)`, id).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+
+	if inUse {
+		return ErrInUse
+	}
+
+	result, err := s.db.ExecContext(ctx, `delete from authors where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// All returns a list of all genres
+func (s *sqlGenres) All(ctx context.Context) ([]*Genre, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select id, genre_name, created_at, updated_at from genres order by genre_name`
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var genres []*Genre
+	for rows.Next() {
+		var genre Genre
+		err := rows.Scan(&genre.ID, &genre.GenreName, &genre.CreatedAt, &genre.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		genres = append(genres, &genre)
+	}
+
+	return genres, nil
+}
+
+// GetOne returns one genre by id
+func (s *sqlGenres) GetOne(ctx context.Context, id int) (*Genre, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select id, genre_name, created_at, updated_at from genres where id = $1`
+
+	var genre Genre
+	err := s.db.QueryRowContext(ctx, query, id).Scan(&genre.ID, &genre.GenreName, &genre.CreatedAt, &genre.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	return &genre, nil
+}
+
+// Insert saves one genre to the database, and returns the new id
+func (s *sqlGenres) Insert(ctx context.Context, genre Genre) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `insert into genres (genre_name, created_at, updated_at) values ($1, $2, $3) returning id`
+
+	var newID int
+	err := s.db.QueryRowContext(ctx, stmt, genre.GenreName, time.Now(), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// Update updates one genre in the database
+func (s *sqlGenres) Update(ctx context.Context, g *Genre) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	stmt := `update genres set genre_name = $1, updated_at = $2 where id = $3`
+
+	result, err := s.db.ExecContext(ctx, stmt, g.GenreName, time.Now(), g.ID)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// DeleteByID deletes a genre by id. Genres that are still assigned to a book cannot be
+// deleted, and ErrInUse is returned
+func (s *sqlGenres) DeleteByID(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	var inUse bool
+	err := s.db.QueryRowContext(ctx, `select exists(select 1 from books_genres where genre_id = $1)`, id).Scan(&inUse)
+	if err != nil {
+		return err
+	}
+
+	if inUse {
+		return ErrInUse
+	}
+
+	result, err := s.db.ExecContext(ctx, `delete from genres where id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	return expectOneRow(result)
+}
+
+// missedUpdate works out why a versioned update of the record with the given id in table changed
+// nothing: sql.ErrNoRows when the record does not exist or is in the trash, and ErrEditConflict
+// when it is at another version
+func missedUpdate(ctx context.Context, db *tracedDB, table string, id int) error {
+	var exists bool
+	query := `select exists(select 1 from ` + table + ` where id = $1 and deleted_at is null)`
+	if err := db.QueryRowContext(ctx, query, id).Scan(&exists); err != nil {
+		return err
+	}
+
+	if !exists {
+		return sql.ErrNoRows
+	}
+
+	return ErrEditConflict
+}
+
+// expectOneRow returns sql.ErrNoRows if a statement did not change anything
+func expectOneRow(result sql.Result) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return sql.ErrNoRows
+	}
+
+	return nil
+}
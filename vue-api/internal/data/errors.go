@@ -0,0 +1,55 @@
+package data
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jackc/pgconn"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// SQLSTATE codes reported by postgres which are turned into errors of this package
+const (
+	pgUniqueViolation     = "23505"
+	pgStringTooLong       = "22001"
+	pgForeignKeyViolation = "23503"
+)
+
+// dbError turns an error reported by postgres or sqlite for a broken constraint into one which
+// matches ErrDuplicate, ErrTooLong or ErrInvalidReference, whatever the database. The original
+// error is wrapped along with it, so that it can still be logged. Other errors are returned as
+// they are
+func dbError(err error) error {
+	var pgErr *pgconn.PgError
+	var sqliteErr *sqlite.Error
+
+	var kind error
+	switch {
+	case err == nil:
+		return nil
+	case errors.As(err, &pgErr):
+		switch pgErr.Code {
+		case pgUniqueViolation:
+			kind = ErrDuplicate
+		case pgStringTooLong:
+			kind = ErrTooLong
+		case pgForeignKeyViolation:
+			kind = ErrInvalidReference
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			kind = ErrDuplicate
+		case sqlite3.SQLITE_TOOBIG:
+			kind = ErrTooLong
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			kind = ErrInvalidReference
+		}
+	}
+
+	if kind == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", kind, err)
+}
@@ -0,0 +1,75 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jackc/pgconn"
+)
+
+func Test_dbError(t *testing.T) {
+	other := errors.New("connection refused")
+
+	var tests = []struct {
+		name     string
+		err      error
+		expected error
+	}{
+		{"unique violation", &pgconn.PgError{Code: "23505"}, ErrDuplicate},
+		{"string too long", fmt.Errorf("insert: %w", &pgconn.PgError{Code: "22001"}), ErrTooLong},
+		{"foreign key violation", &pgconn.PgError{Code: "23503"}, ErrInvalidReference},
+		{"other postgres error", &pgconn.PgError{Code: "42P01"}, nil},
+		{"other error", other, nil},
+	}
+
+	for _, e := range tests {
+		err := dbError(e.err)
+
+		if !errors.Is(err, e.err) {
+			t.Errorf("%s: expected the original error to be kept, got %v", e.name, err)
+		}
+
+		for _, sentinel := range []error{ErrDuplicate, ErrTooLong, ErrInvalidReference} {
+			if errors.Is(err, sentinel) != (sentinel == e.expected) {
+				t.Errorf("%s: unexpected match of %v with %v", e.name, err, sentinel)
+			}
+		}
+	}
+
+	if dbError(nil) != nil {
+		t.Error("expected no error to stay no error")
+	}
+}
+
+func Test_dbError_sqlite(t *testing.T) {
+	pool, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pool.Close()
+
+	ctx := context.Background()
+	db := newTracedDB(pool)
+
+	if _, err := db.ExecContext(ctx, "create table names (name text primary key)"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.ExecContext(ctx, "insert into names (name) values ('ann')"); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = db.ExecContext(ctx, "insert into names (name) values ('ann')")
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate inserting a name twice, got %v", err)
+	}
+
+	var name string
+	err = db.QueryRowContext(ctx, "insert into names (name) values ('ann') returning name").Scan(&name)
+	if !errors.Is(err, ErrDuplicate) {
+		t.Errorf("expected ErrDuplicate from a row, got %v", err)
+	}
+}
@@ -0,0 +1,968 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/mozillazg/go-slugify"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memoryStore holds the data of the in-memory repositories. A single lock guards all of it,
+// since some changes, such as deleting a user along with their tokens, span several collections.
+// Within a transaction the lock is held throughout, and the store of the transaction's models
+// takes none of its own
+type memoryStore struct {
+	mu sync.Locker
+	*memoryData
+	inTx bool
+}
+
+// memoryData is the content of a memoryStore
+type memoryData struct {
+	users      map[int]*User
+	tokens     map[int]*Token
+	books      map[int]*Book
+	bookGenres map[int][]int
+	authors    map[int]*Author
+	genres     map[int]*Genre
+	revisions  map[int]*BookRevision
+	audit      []*AuditEntry
+	lastID     map[string]int
+}
+
+// The in-memory implementation of the repositories, which all share one store
+type (
+	memUsers     struct{ s *memoryStore }
+	memTokens    struct{ s *memoryStore }
+	memBooks     struct{ s *memoryStore }
+	memAuthors   struct{ s *memoryStore }
+	memGenres    struct{ s *memoryStore }
+	memRevisions struct{ s *memoryStore }
+	memAudit     struct{ s *memoryStore }
+)
+
+// NewMemory returns models which keep their data in memory, behaving like the SQL ones.
+// Everything is lost when the process exits, so they are meant for tests and demos
+func NewMemory() Models {
+	s := &memoryStore{
+		mu: &sync.Mutex{},
+		memoryData: &memoryData{
+			users:      make(map[int]*User),
+			tokens:     make(map[int]*Token),
+			books:      make(map[int]*Book),
+			bookGenres: make(map[int][]int),
+			authors:    make(map[int]*Author),
+			genres:     make(map[int]*Genre),
+			revisions:  make(map[int]*BookRevision),
+			lastID:     make(map[string]int),
+		},
+	}
+
+	return s.models()
+}
+
+// models returns the repositories backed by s
+func (s *memoryStore) models() Models {
+	return Models{
+		User:         &memUsers{s},
+		Token:        &memTokens{s},
+		Book:         &memBooks{s},
+		Author:       &memAuthors{s},
+		Genre:        &memGenres{s},
+		BookRevision: &memRevisions{s},
+		Audit:        &memAudit{s},
+		transaction:  s.transaction,
+	}
+}
+
+// transaction runs fn with models which make their changes while s stays locked, so that nobody
+// else sees them before fn is done, and puts the data back the way it was when fn fails
+func (s *memoryStore) transaction(ctx context.Context, fn func(tx Models) error) error {
+	if s.inTx {
+		return fn(s.models())
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := s.memoryData.clone()
+	tx := &memoryStore{mu: noLock{}, memoryData: s.memoryData, inTx: true}
+
+	if err := fn(tx.models()); err != nil {
+		*s.memoryData = *saved
+		return err
+	}
+
+	return nil
+}
+
+// noLock is the lock of a store within a transaction, whose lock is already held
+type noLock struct{}
+
+func (noLock) Lock()   {}
+func (noLock) Unlock() {}
+
+// clone returns a copy of d which shares nothing that the repositories change in place
+func (d *memoryData) clone() *memoryData {
+	return &memoryData{
+		users:      cloneRecords(d.users),
+		tokens:     cloneRecords(d.tokens),
+		books:      cloneRecords(d.books),
+		bookGenres: cloneMap(d.bookGenres),
+		authors:    cloneRecords(d.authors),
+		genres:     cloneRecords(d.genres),
+		revisions:  cloneRecords(d.revisions),
+		audit:      append([]*AuditEntry(nil), d.audit...),
+		lastID:     cloneMap(d.lastID),
+	}
+}
+
+// cloneRecords copies m along with the records it points to
+func cloneRecords[T any](m map[int]*T) map[int]*T {
+	c := make(map[int]*T, len(m))
+	for id, record := range m {
+		copied := *record
+		c[id] = &copied
+	}
+	return c
+}
+
+// cloneMap copies m; its values are replaced rather than changed, so they are shared
+func cloneMap[K comparable, V any](m map[K]V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = v
+	}
+	return c
+}
+
+// nextID returns a new id for a record of the given collection. Like the identity columns of
+// the database, each collection counts from 1
+func (s *memoryStore) nextID(collection string) int {
+	s.lastID[collection]++
+	return s.lastID[collection]
+}
+
+// sortedValues returns the values of m ordered by less, falling back to the order of the ids
+func sortedValues[T any](m map[int]*T, less func(a, b *T) bool) []*T {
+	ids := make([]int, 0, len(m))
+	for id := range m {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+
+	values := make([]*T, 0, len(ids))
+	for _, id := range ids {
+		values = append(values, m[id])
+	}
+	sort.SliceStable(values, func(i, j int) bool { return less(values[i], values[j]) })
+
+	return values
+}
+
+// GetAll returns a slice of all users that have not been deleted, sorted by last name. The
+// Token.ID of each user is 1 when they have a token which has not expired, and 0 otherwise
+func (r *memUsers) GetAll(ctx context.Context) ([]*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []*User
+	for _, u := range sortedValues(r.s.users, func(a, b *User) bool { return a.LastName < b.LastName }) {
+		if u.DeletedAt != nil {
+			continue
+		}
+
+		user := *u
+		for _, t := range r.s.tokens {
+			if t.UserID == u.ID && t.Expiry.After(time.Now()) {
+				user.Token.ID = 1
+			}
+		}
+		users = append(users, &user)
+	}
+
+	return users, nil
+}
+
+// GetByEmail returns the user with the given email address
+func (r *memUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, u := range sortedValues(r.s.users, func(a, b *User) bool { return false }) {
+		if u.Email == email && u.DeletedAt == nil {
+			user := *u
+			return &user, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// GetOne returns one user by id
+func (r *memUsers) GetOne(ctx context.Context, id int) (*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.liveUser(id)
+}
+
+// liveUser returns a copy of the user with the given id, unless the user is in the trash
+func (s *memoryStore) liveUser(id int) (*User, error) {
+	u, ok := s.users[id]
+	if !ok || u.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	user := *u
+	return &user, nil
+}
+
+// Insert adds a user, hashing their password, and returns the new id
+func (r *memUsers) Insert(ctx context.Context, user User) (int, error) {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
+	if err != nil {
+		return 0, err
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	stored := User{
+		ID:        r.s.nextID("users"),
+		Email:     user.Email,
+		FirstName: user.FirstName,
+		LastName:  user.LastName,
+		Password:  string(hashedPassword),
+		Active:    user.Active,
+		Language:  user.Language,
+		CreatedAt: now,
+		UpdatedAt: now,
+		Version:   1,
+	}
+	r.s.users[stored.ID] = &stored
+
+	return stored.ID, nil
+}
+
+// Update updates one user, with the same version checks as the SQL implementation
+func (r *memUsers) Update(ctx context.Context, u *User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.users[u.ID]
+	if !ok || stored.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	if u.Version != 0 && u.Version != stored.Version {
+		return ErrEditConflict
+	}
+
+	stored.Email = u.Email
+	stored.FirstName = u.FirstName
+	stored.LastName = u.LastName
+	stored.Active = u.Active
+	stored.Language = u.Language
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+
+	u.Version = stored.Version
+	return nil
+}
+
+// ResetPassword sets a new password for the user with the given id
+func (r *memUsers) ResetPassword(ctx context.Context, id int, password string) error {
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	if u, ok := r.s.users[id]; ok {
+		u.Password = string(hashedPassword)
+	}
+
+	return nil
+}
+
+// DeleteByID moves a user to the trash, and removes their tokens
+func (r *memUsers) DeleteByID(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok || u.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now()
+	u.DeletedAt = &now
+	r.s.deleteTokens(func(t *Token) bool { return t.UserID == id })
+
+	return nil
+}
+
+// GetAllDeleted returns a slice of all users in the trash, most recently deleted first
+func (r *memUsers) GetAllDeleted(ctx context.Context) ([]*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var users []*User
+	for _, u := range sortedValues(r.s.users, func(a, b *User) bool { return deletedAfter(a.DeletedAt, b.DeletedAt) }) {
+		if u.DeletedAt != nil {
+			user := *u
+			users = append(users, &user)
+		}
+	}
+
+	return users, nil
+}
+
+// Restore takes a user out of the trash
+func (r *memUsers) Restore(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	u, ok := r.s.users[id]
+	if !ok || u.DeletedAt == nil {
+		return ErrNotInTrash
+	}
+
+	u.DeletedAt = nil
+	u.UpdatedAt = time.Now()
+	return nil
+}
+
+// PurgeDeleted permanently removes users which were moved to the trash before the given time
+func (r *memUsers) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var purged int64
+	for id, u := range r.s.users {
+		if u.DeletedAt != nil && u.DeletedAt.Before(before) {
+			r.s.deleteTokens(func(t *Token) bool { return t.UserID == id })
+			delete(r.s.users, id)
+			purged++
+		}
+	}
+
+	return purged, nil
+}
+
+// deletedAfter orders trashed records most recently deleted first
+func deletedAfter(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return b == nil && a != nil
+	}
+	return a.After(*b)
+}
+
+// GetByToken returns the token with the given plain text
+func (r *memTokens) GetByToken(ctx context.Context, plainText string) (*Token, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, t := range r.s.tokens {
+		if t.Token == plainText {
+			token := *t
+			return &token, nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// GetUserForToken returns the user a token belongs to, unless the user is in the trash
+func (r *memTokens) GetUserForToken(ctx context.Context, token Token) (*User, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.liveUser(token.UserID)
+}
+
+// Insert stores a token for a user, replacing any tokens they had
+func (r *memTokens) Insert(ctx context.Context, token Token, u User) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.deleteTokens(func(t *Token) bool { return t.UserID == token.UserID })
+
+	now := time.Now()
+	token.ID = r.s.nextID("tokens")
+	token.Email = u.Email
+	token.CreatedAt = now
+	token.UpdatedAt = now
+	r.s.tokens[token.ID] = &token
+
+	return nil
+}
+
+// DeleteByToken deletes a token, by plain text token
+func (r *memTokens) DeleteByToken(ctx context.Context, plainText string) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.deleteTokens(func(t *Token) bool { return t.Token == plainText })
+	return nil
+}
+
+// DeleteTokensForUser deletes every token of the user with the given id
+func (r *memTokens) DeleteTokensForUser(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	r.s.deleteTokens(func(t *Token) bool { return t.UserID == id })
+	return nil
+}
+
+// DeleteAll removes every token, and returns how many were removed
+func (r *memTokens) DeleteAll(ctx context.Context) (int64, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.deleteTokens(func(t *Token) bool { return true }), nil
+}
+
+// deleteTokens removes the tokens matching match, and returns how many were removed
+func (s *memoryStore) deleteTokens(match func(t *Token) bool) int64 {
+	var removed int64
+	for id, t := range s.tokens {
+		if match(t) {
+			delete(s.tokens, id)
+			removed++
+		}
+	}
+	return removed
+}
+
+// GetAll returns a slice of all books that have not been deleted, sorted by title
+func (r *memBooks) GetAll(ctx context.Context) ([]*Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	return r.s.liveBooks(), nil
+}
+
+// GetAllPaginated returns one page of the books returned by GetAll
+func (r *memBooks) GetAllPaginated(ctx context.Context, page, pageSize int) ([]*Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	books := r.s.liveBooks()
+
+	offset := (page - 1) * pageSize
+	if offset < 0 {
+		offset = 0
+	}
+	if offset >= len(books) {
+		return nil, nil
+	}
+
+	end := offset + pageSize
+	if end > len(books) || pageSize < 0 {
+		end = len(books)
+	}
+
+	return books[offset:end], nil
+}
+
+// liveBooks returns copies of the books which are not in the trash, sorted by title
+func (s *memoryStore) liveBooks() []*Book {
+	var books []*Book
+	for _, b := range sortedValues(s.books, func(a, b *Book) bool { return a.Title < b.Title }) {
+		if b.DeletedAt == nil {
+			books = append(books, s.fullBook(b, true))
+		}
+	}
+	return books
+}
+
+// fullBook returns a copy of a stored book with its author, and its genres when withGenres is set
+func (s *memoryStore) fullBook(b *Book, withGenres bool) *Book {
+	book := *b
+	book.Genres = nil
+	book.GenreIDs = nil
+
+	if a, ok := s.authors[b.AuthorID]; ok {
+		book.Author = *a
+	}
+
+	if withGenres {
+		book.Genres, book.GenreIDs = s.genresForBook(b.ID)
+	}
+
+	return &book
+}
+
+// genresForBook returns the genres of a book sorted by name, and their ids in the same order
+func (s *memoryStore) genresForBook(id int) ([]Genre, []int) {
+	var genres []Genre
+	for _, genreID := range s.bookGenres[id] {
+		if g, ok := s.genres[genreID]; ok {
+			genres = append(genres, *g)
+		}
+	}
+	sort.SliceStable(genres, func(i, j int) bool { return genres[i].GenreName < genres[j].GenreName })
+
+	var ids []int
+	for _, g := range genres {
+		ids = append(ids, g.ID)
+	}
+
+	return genres, ids
+}
+
+// GetOneById returns one book by its id
+func (r *memBooks) GetOneById(ctx context.Context, id int) (*Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	b, ok := r.s.books[id]
+	if !ok || b.DeletedAt != nil {
+		return nil, sql.ErrNoRows
+	}
+
+	return r.s.fullBook(b, true), nil
+}
+
+// GetOneBySlug returns one book by slug
+func (r *memBooks) GetOneBySlug(ctx context.Context, slug string) (*Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, b := range sortedValues(r.s.books, func(a, b *Book) bool { return false }) {
+		if b.Slug == slug && b.DeletedAt == nil {
+			return r.s.fullBook(b, true), nil
+		}
+	}
+
+	return nil, sql.ErrNoRows
+}
+
+// Insert adds a book, with a slug made from its title, and returns the new id
+func (r *memBooks) Insert(ctx context.Context, book Book) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	stored := Book{
+		ID:              r.s.nextID("books"),
+		Title:           book.Title,
+		AuthorID:        book.AuthorID,
+		PublicationYear: book.PublicationYear,
+		Slug:            slugify.Slugify(book.Title),
+		Description:     book.Description,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+		Version:         1,
+	}
+	r.s.books[stored.ID] = &stored
+
+	if len(book.GenreIDs) > 0 {
+		r.s.bookGenres[stored.ID] = append([]int{}, book.GenreIDs...)
+	}
+
+	return stored.ID, nil
+}
+
+// Update updates one book, with the same version checks as the SQL implementation.
+// Genres are replaced by GenreIDs, unless GenreIDs is nil
+func (r *memBooks) Update(ctx context.Context, b *Book) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.books[b.ID]
+	if !ok || stored.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+	if b.Version != 0 && b.Version != stored.Version {
+		return ErrEditConflict
+	}
+
+	stored.Title = b.Title
+	stored.AuthorID = b.AuthorID
+	stored.PublicationYear = b.PublicationYear
+	stored.Slug = slugify.Slugify(b.Title)
+	stored.Description = b.Description
+	stored.UpdatedAt = time.Now()
+	stored.Version++
+
+	if b.GenreIDs != nil {
+		r.s.bookGenres[b.ID] = append([]int{}, b.GenreIDs...)
+	}
+
+	b.Version = stored.Version
+	return nil
+}
+
+// DeleteByID moves a book to the trash
+func (r *memBooks) DeleteByID(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	b, ok := r.s.books[id]
+	if !ok || b.DeletedAt != nil {
+		return sql.ErrNoRows
+	}
+
+	now := time.Now()
+	b.DeletedAt = &now
+
+	return nil
+}
+
+// GetAllDeleted returns a slice of all books in the trash, most recently deleted first. Like the
+// SQL implementation, it leaves out their genres
+func (r *memBooks) GetAllDeleted(ctx context.Context) ([]*Book, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var books []*Book
+	for _, b := range sortedValues(r.s.books, func(a, b *Book) bool { return deletedAfter(a.DeletedAt, b.DeletedAt) }) {
+		if b.DeletedAt != nil {
+			books = append(books, r.s.fullBook(b, false))
+		}
+	}
+
+	return books, nil
+}
+
+// Restore takes a book out of the trash
+func (r *memBooks) Restore(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	b, ok := r.s.books[id]
+	if !ok || b.DeletedAt == nil {
+		return ErrNotInTrash
+	}
+
+	b.DeletedAt = nil
+	b.UpdatedAt = time.Now()
+	return nil
+}
+
+// PurgeDeleted permanently removes books which were moved to the trash before the given time,
+// and returns their slugs
+func (r *memBooks) PurgeDeleted(ctx context.Context, before time.Time) ([]string, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var slugs []string
+	for id, b := range r.s.books {
+		if b.DeletedAt != nil && b.DeletedAt.Before(before) {
+			delete(r.s.books, id)
+			delete(r.s.bookGenres, id)
+			slugs = append(slugs, b.Slug)
+		}
+	}
+
+	return slugs, nil
+}
+
+// All returns a list of all authors, sorted by name
+func (r *memAuthors) All(ctx context.Context) ([]*Author, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var authors []*Author
+	for _, a := range sortedValues(r.s.authors, func(a, b *Author) bool { return a.AuthorName < b.AuthorName }) {
+		author := *a
+		authors = append(authors, &author)
+	}
+
+	return authors, nil
+}
+
+// GetOne returns one author by id
+func (r *memAuthors) GetOne(ctx context.Context, id int) (*Author, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	a, ok := r.s.authors[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	author := *a
+	return &author, nil
+}
+
+// Insert adds an author, and returns the new id
+func (r *memAuthors) Insert(ctx context.Context, author Author) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	stored := Author{ID: r.s.nextID("authors"), AuthorName: author.AuthorName, CreatedAt: now, UpdatedAt: now}
+	r.s.authors[stored.ID] = &stored
+
+	return stored.ID, nil
+}
+
+// Update renames an author
+func (r *memAuthors) Update(ctx context.Context, a *Author) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.authors[a.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	stored.AuthorName = a.AuthorName
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+// DeleteByID deletes an author, unless they still have books, including books in the trash
+func (r *memAuthors) DeleteByID(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, b := range r.s.books {
+		if b.AuthorID == id {
+			return ErrInUse
+		}
+	}
+
+	if _, ok := r.s.authors[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(r.s.authors, id)
+	return nil
+}
+
+// All returns a list of all genres, sorted by name
+func (r *memGenres) All(ctx context.Context) ([]*Genre, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var genres []*Genre
+	for _, g := range sortedValues(r.s.genres, func(a, b *Genre) bool { return a.GenreName < b.GenreName }) {
+		genre := *g
+		genres = append(genres, &genre)
+	}
+
+	return genres, nil
+}
+
+// GetOne returns one genre by id
+func (r *memGenres) GetOne(ctx context.Context, id int) (*Genre, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	g, ok := r.s.genres[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	genre := *g
+	return &genre, nil
+}
+
+// Insert adds a genre, and returns the new id
+func (r *memGenres) Insert(ctx context.Context, genre Genre) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	now := time.Now()
+	stored := Genre{ID: r.s.nextID("genres"), GenreName: genre.GenreName, CreatedAt: now, UpdatedAt: now}
+	r.s.genres[stored.ID] = &stored
+
+	return stored.ID, nil
+}
+
+// Update renames a genre
+func (r *memGenres) Update(ctx context.Context, g *Genre) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	stored, ok := r.s.genres[g.ID]
+	if !ok {
+		return sql.ErrNoRows
+	}
+
+	stored.GenreName = g.GenreName
+	stored.UpdatedAt = time.Now()
+	return nil
+}
+
+// DeleteByID deletes a genre, unless it is still assigned to a book, including books in the trash
+func (r *memGenres) DeleteByID(ctx context.Context, id int) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, genreIDs := range r.s.bookGenres {
+		for _, genreID := range genreIDs {
+			if genreID == id {
+				return ErrInUse
+			}
+		}
+	}
+
+	if _, ok := r.s.genres[id]; !ok {
+		return sql.ErrNoRows
+	}
+
+	delete(r.s.genres, id)
+	return nil
+}
+
+// Record stores the current state of a book, in the trash or not, as a new revision
+func (r *memRevisions) Record(ctx context.Context, bookID, userID int) (int, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	b, ok := r.s.books[bookID]
+	if !ok {
+		return 0, sql.ErrNoRows
+	}
+
+	_, genreIDs := r.s.genresForBook(bookID)
+	if genreIDs == nil {
+		genreIDs = []int{}
+	}
+
+	revision := BookRevision{
+		ID:              r.s.nextID("book_revisions"),
+		BookID:          bookID,
+		UserID:          userID,
+		Title:           b.Title,
+		AuthorID:        b.AuthorID,
+		PublicationYear: b.PublicationYear,
+		Description:     b.Description,
+		GenreIDs:        genreIDs,
+		CreatedAt:       time.Now(),
+	}
+	r.s.revisions[revision.ID] = &revision
+
+	return revision.ID, nil
+}
+
+// HasRevisions reports whether any revision has been recorded for the book with the given id
+func (r *memRevisions) HasRevisions(ctx context.Context, bookID int) (bool, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	for _, revision := range r.s.revisions {
+		if revision.BookID == bookID {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// GetAllForBook returns all revisions of the book with the given id, newest first
+func (r *memRevisions) GetAllForBook(ctx context.Context, bookID int) ([]*BookRevision, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var revisions []*BookRevision
+	for _, revision := range sortedValues(r.s.revisions, func(a, b *BookRevision) bool { return a.ID > b.ID }) {
+		if revision.BookID == bookID {
+			revisions = append(revisions, r.s.revision(revision))
+		}
+	}
+
+	return revisions, nil
+}
+
+// GetOne returns one revision by id
+func (r *memRevisions) GetOne(ctx context.Context, id int) (*BookRevision, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	revision, ok := r.s.revisions[id]
+	if !ok {
+		return nil, sql.ErrNoRows
+	}
+
+	return r.s.revision(revision), nil
+}
+
+// revision returns a copy of a stored revision, with the email of the user who saved it
+func (s *memoryStore) revision(stored *BookRevision) *BookRevision {
+	revision := *stored
+	revision.GenreIDs = append([]int{}, stored.GenreIDs...)
+
+	if u, ok := s.users[stored.UserID]; ok {
+		revision.UserEmail = u.Email
+	}
+
+	return &revision
+}
+
+// Insert appends one entry to the audit log
+func (r *memAudit) Insert(ctx context.Context, entry AuditEntry) error {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	entry.ID = r.s.nextID("audit_log")
+	entry.Before = copyJSON(entry.Before)
+	entry.After = copyJSON(entry.After)
+	entry.CreatedAt = time.Now()
+	r.s.audit = append(r.s.audit, &entry)
+
+	return nil
+}
+
+// Filter returns the audit log entries matching the given filter, newest first
+func (r *memAudit) Filter(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error) {
+	r.s.mu.Lock()
+	defer r.s.mu.Unlock()
+
+	var entries []*AuditEntry
+	for i := len(r.s.audit) - 1; i >= 0; i-- {
+		e := r.s.audit[i]
+
+		switch {
+		case filter.ActorID > 0 && e.ActorID != filter.ActorID,
+			filter.Action != "" && e.Action != filter.Action,
+			filter.Entity != "" && e.Entity != filter.Entity,
+			filter.EntityID > 0 && e.EntityID != filter.EntityID,
+			filter.From != nil && e.CreatedAt.Before(*filter.From),
+			filter.To != nil && !e.CreatedAt.Before(*filter.To):
+			continue
+		}
+
+		entry := *e
+		entry.Before = copyJSON(e.Before)
+		entry.After = copyJSON(e.After)
+		entries = append(entries, &entry)
+	}
+
+	if filter.PageSize > 0 {
+		page := filter.Page
+		if page < 1 {
+			page = 1
+		}
+
+		offset := (page - 1) * filter.PageSize
+		if offset >= len(entries) {
+			return nil, nil
+		}
+		entries = entries[offset:min(offset+filter.PageSize, len(entries))]
+	}
+
+	return entries, nil
+}
+
+// copyJSON copies a snapshot, so that callers cannot change what is stored
+func copyJSON(snapshot json.RawMessage) json.RawMessage {
+	if len(snapshot) == 0 {
+		return nil
+	}
+	return append(json.RawMessage{}, snapshot...)
+}
@@ -14,54 +14,72 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-const dbTimeout = time.Second * 3
+var dbTimeout = time.Second * 3
 
-var db *sql.DB
+// ErrNotInTrash is returned when attempting to restore a record that has not been deleted
+var ErrNotInTrash = errors.New("record not found in trash")
 
-// New function is used to create an instance of the data package. It returns the type Model, which embeds all of the types we want to be available to our application
-func New(dbPool *sql.DB) Models {
-	db = dbPool
+// ErrEditConflict is returned when updating a record which has been changed since the version
+// being edited was read
+var ErrEditConflict = errors.New("record has been changed by someone else")
 
-	return Models{
-		User:   User{},
-		Token:  Token{},
-		Book:   Book{},
-		Author: Author{},
-	}
+// ErrInUse is returned when deleting a record that other records still refer to
+var ErrInUse = errors.New("record is still in use")
+
+// ErrDuplicate is returned when saving a record would repeat a value which must be unique
+var ErrDuplicate = errors.New("duplicate value")
+
+// ErrTooLong is returned when saving a value which is longer than the database can hold
+var ErrTooLong = errors.New("value too long")
+
+// ErrInvalidReference is returned when saving a record which refers to one that does not exist
+var ErrInvalidReference = errors.New("reference to a missing record")
+
+// SetTimeout sets the maximum time a single database query may take, when the caller has not
+// set a deadline of its own
+func SetTimeout(timeout time.Duration) {
+	dbTimeout = timeout
 }
 
-type Models struct {
-	User   User
-	Token  Token
-	Book   Book
-	Author Author
+// withTimeout returns the context a query runs with: ctx bounded by the default timeout, or ctx
+// as it is when the caller has set a deadline, so that it can be tuned per call. Either way the
+// query stops when ctx is cancelled, for instance because the client went away
+func withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, dbTimeout)
 }
 
+// User is the definition of a single user
 type User struct {
-	ID        int       `json:"id"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name,omitempty"`
-	LastName  string    `json:"last_name,omitempty"`
-	Password  string    `json:"password"`
-	Active    int       `json:"active"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Token     Token     `json:"token"`
+	ID        int        `json:"id"`
+	Email     string     `json:"email"`
+	FirstName string     `json:"first_name,omitempty"`
+	LastName  string     `json:"last_name,omitempty"`
+	Password  string     `json:"password"`
+	Active    int        `json:"active"`
+	Language  string     `json:"language"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at,omitempty"`
+	Version   int        `json:"version"`
+	Token     Token      `json:"token"`
 }
 
-// GetAll returns a slice of all users, sorted by last name
-func (u *User) GetAll() ([]*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// GetAll returns a slice of all users that have not been deleted, sorted by last name
+func (s *sqlUsers) GetAll(ctx context.Context) ([]*User, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select id, email, first_name, last_name, password, user_active, created_at, updated_at,
+	query := `select id, email, first_name, last_name, password, user_active, language, created_at, updated_at, version,
 	case 
-		when (select count(id) from tokens t where user_id = users.id and t.expiry > NOW()) > 0 then 1
+		when (select count(id) from tokens t where user_id = users.id and t.expiry > $1) > 0 then 1
 		else 0
 	end as hash_token
-	from users order by last_name`
+	from users where deleted_at is null order by last_name`
 
-	rows, err := db.QueryContext(ctx, query)
+	rows, err := s.db.QueryContext(ctx, query, time.Now())
 	if err != nil {
 		return nil, err
 	}
@@ -78,8 +96,10 @@ func (u *User) GetAll() ([]*User, error) {
 			&user.LastName,
 			&user.Password,
 			&user.Active,
+			&user.Language,
 			&user.CreatedAt,
 			&user.UpdatedAt,
+			&user.Version,
 			&user.Token.ID,
 		)
 		if err != nil {
@@ -92,14 +112,14 @@ func (u *User) GetAll() ([]*User, error) {
 	return users, nil
 }
 
-func (u *User) GetByEmail(email string) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlUsers) GetByEmail(ctx context.Context, email string) (*User, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select id, email, first_name, last_name, password, user_active, created_at, updated_at from users where email = $1`
+	query := `select id, email, first_name, last_name, password, user_active, language, created_at, updated_at, version from users where email = $1 and deleted_at is null`
 
 	var user User
-	row := db.QueryRowContext(ctx, query, email)
+	row := s.db.QueryRowContext(ctx, query, email)
 
 	err := row.Scan(
 		&user.ID,
@@ -108,8 +128,10 @@ func (u *User) GetByEmail(email string) (*User, error) {
 		&user.LastName,
 		&user.Password,
 		&user.Active,
+		&user.Language,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Version,
 	)
 
 	if err != nil {
@@ -119,14 +141,14 @@ func (u *User) GetByEmail(email string) (*User, error) {
 	return &user, nil
 }
 
-func (u *User) GetOne(id int) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlUsers) GetOne(ctx context.Context, id int) (*User, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select id, email, first_name, last_name, password, user_active, created_at, updated_at from users where id = $1`
+	query := `select id, email, first_name, last_name, password, user_active, language, created_at, updated_at, version from users where id = $1 and deleted_at is null`
 
 	var user User
-	row := db.QueryRowContext(ctx, query, id)
+	row := s.db.QueryRowContext(ctx, query, id)
 
 	err := row.Scan(
 		&user.ID,
@@ -135,8 +157,10 @@ func (u *User) GetOne(id int) (*User, error) {
 		&user.LastName,
 		&user.Password,
 		&user.Active,
+		&user.Language,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Version,
 	)
 
 	if err != nil {
@@ -146,9 +170,13 @@ func (u *User) GetOne(id int) (*User, error) {
 	return &user, nil
 }
 
-// Update updates one user in the database, using the information stored in the receiver user
-func (u *User) Update() error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// Update updates one user in the database, using the information stored in u. If u has a
+// version set, the update only happens if the stored user is still at that version, and
+// ErrEditConflict is returned otherwise; a version of 0 updates the user regardless. sql.ErrNoRows
+// is returned when there is no such user, or they are in the trash. On success, u holds the new
+// version
+func (s *sqlUsers) Update(ctx context.Context, u *User) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	stmt := `update users set
@@ -156,75 +184,173 @@ func (u *User) Update() error {
 		first_name = $2,
 		last_name = $3,
 		user_active = $4,
-		updated_at = $5
-		where id = $6
+		language = $8,
+		updated_at = $5,
+		version = version + 1
+		where id = $6 and deleted_at is null and ($7 = 0 or version = $7)
+		returning version
 	`
 
-	_, err := db.ExecContext(ctx, stmt,
+	err := s.db.QueryRowContext(ctx, stmt,
 		u.Email,
 		u.FirstName,
 		u.LastName,
 		u.Active,
 		time.Now(),
 		u.ID,
-	)
+		u.Version,
+		u.Language,
+	).Scan(&u.Version)
 
 	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return missedUpdate(ctx, s.db, "users", u.ID)
+		}
 		return err
 	}
 
 	return nil
 }
 
-func (u *User) Delete() error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// DeleteByID soft deletes a user by setting deleted_at, so that the user can be restored
+// from the trash until it is purged. Any tokens belonging to the user are removed, so
+// a deleted user is logged out immediately.
+func (s *sqlUsers) DeleteByID(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	stmt := `delete from users where id = $1`
+	// the user and their tokens go together, so that a user in the trash is never left logged in
+	return s.db.inTx(ctx, func(tx *tracedDB) error {
+		stmt := `update users set deleted_at = $1 where id = $2 and deleted_at is null`
 
-	_, err := db.ExecContext(ctx, stmt, u.ID)
+		result, err := tx.ExecContext(ctx, stmt, time.Now(), id)
+		if err != nil {
+			return err
+		}
 
-	if err != nil {
+		if err := expectOneRow(result); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `delete from tokens where user_id = $1`, id)
 		return err
+	})
+}
+
+// GetAllDeleted returns a slice of all users in the trash, most recently deleted first
+func (s *sqlUsers) GetAllDeleted(ctx context.Context) ([]*User, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select id, email, first_name, last_name, password, user_active, language, created_at, updated_at, version, deleted_at
+	from users where deleted_at is not null order by deleted_at desc`
+
+	rows, err := s.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	defer rows.Close()
+
+	var users []*User
+	for rows.Next() {
+		var user User
+		err := rows.Scan(
+			&user.ID,
+			&user.Email,
+			&user.FirstName,
+			&user.LastName,
+			&user.Password,
+			&user.Active,
+			&user.Language,
+			&user.CreatedAt,
+			&user.UpdatedAt,
+			&user.Version,
+			&user.DeletedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		users = append(users, &user)
+	}
+
+	return users, nil
 }
 
-func (u *User) DeleteByID(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// Restore takes a user out of the trash
+func (s *sqlUsers) Restore(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	stmt := `delete from users where id = $1`
+	stmt := `update users set deleted_at = null, updated_at = $1 where id = $2 and deleted_at is not null`
 
-	_, err := db.ExecContext(ctx, stmt, id)
+	result, err := s.db.ExecContext(ctx, stmt, time.Now(), id)
+	if err != nil {
+		return err
+	}
 
+	n, err := result.RowsAffected()
 	if err != nil {
 		return err
 	}
 
+	if n == 0 {
+		return ErrNotInTrash
+	}
+
 	return nil
 }
 
-func (u *User) Insert(user User) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+// PurgeDeleted permanently removes users which were moved to the trash before the given
+// time, along with their tokens, and returns the number of users removed
+func (s *sqlUsers) PurgeDeleted(ctx context.Context, before time.Time) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
+	var purged int64
+	err := s.db.inTx(ctx, func(tx *tracedDB) error {
+		stmt := `delete from tokens where user_id in (select id from users where deleted_at < $1)`
+		if _, err := tx.ExecContext(ctx, stmt, before); err != nil {
+			return err
+		}
+
+		result, err := tx.ExecContext(ctx, `delete from users where deleted_at < $1`, before)
+		if err != nil {
+			return err
+		}
+
+		purged, err = result.RowsAffected()
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, nil
+}
+
+func (s *sqlUsers) Insert(ctx context.Context, user User) (int, error) {
+	// hashing is slow on purpose, so it is done before the clock of the query starts
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(user.Password), 12)
 	if err != nil {
 		return 0, err
 	}
 
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	var newID int
-	stmt := `insert into users (email, first_name, last_name, password, user_active, created_at, updated_at)
-		values ($1, $2, $3, $4, $5, $6, $7) returning id`
+	stmt := `insert into users (email, first_name, last_name, password, user_active, language, created_at, updated_at)
+		values ($1, $2, $3, $4, $5, $6, $7, $8) returning id`
 
-	err = db.QueryRowContext(ctx, stmt,
+	err = s.db.QueryRowContext(ctx, stmt,
 		user.Email,
 		user.FirstName,
 		user.LastName,
 		hashedPassword,
 		user.Active,
+		user.Language,
 		time.Now(),
 		time.Now(),
 	).Scan(&newID)
@@ -236,18 +362,19 @@ func (u *User) Insert(user User) (int, error) {
 	return newID, nil
 }
 
-func (u *User) ResetPassword(password string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
-	defer cancel()
-
+func (s *sqlUsers) ResetPassword(ctx context.Context, id int, password string) error {
+	// hashing is slow on purpose, so it is done before the clock of the query starts
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return err
 	}
 
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
 	stmt := `update users set password = $1 where id = $2`
 
-	_, err = db.ExecContext(ctx, stmt, hashedPassword, u.ID)
+	_, err = s.db.ExecContext(ctx, stmt, hashedPassword, id)
 
 	if err != nil {
 		return err
@@ -272,6 +399,7 @@ func (u *User) PasswordMatches(plainText string) (bool, error) {
 	return true, nil
 }
 
+// Token is a bearer token, which logs a user in until it expires
 type Token struct {
 	ID        int       `json:"id"`
 	UserID    int       `json:"user_id"`
@@ -283,15 +411,15 @@ type Token struct {
 	Expiry    time.Time `json:"expiry"`
 }
 
-func (t *Token) GetByToken(plainText string) (*Token, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlTokens) GetByToken(ctx context.Context, plainText string) (*Token, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	query := `select id, user_id, email, token, token_hash, created_at, updated_at, expiry
 			from tokens where token = $1`
 
 	var token Token
-	row := db.QueryRowContext(ctx, query, plainText)
+	row := s.db.QueryRowContext(ctx, query, plainText)
 	err := row.Scan(
 		&token.ID,
 		&token.UserID,
@@ -310,14 +438,14 @@ func (t *Token) GetByToken(plainText string) (*Token, error) {
 	return &token, nil
 }
 
-func (t *Token) GetUserForToken(token Token) (*User, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlTokens) GetUserForToken(ctx context.Context, token Token) (*User, error) {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
-	query := `select id, email, first_name, last_name, password, user_active, created_at, updated_at from users where id = $1`
+	query := `select id, email, first_name, last_name, password, user_active, language, created_at, updated_at, version from users where id = $1 and deleted_at is null`
 
 	var user User
-	row := db.QueryRowContext(ctx, query, token.UserID)
+	row := s.db.QueryRowContext(ctx, query, token.UserID)
 
 	err := row.Scan(
 		&user.ID,
@@ -326,8 +454,10 @@ func (t *Token) GetUserForToken(token Token) (*User, error) {
 		&user.LastName,
 		&user.Password,
 		&user.Active,
+		&user.Language,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.Version,
 	)
 
 	if err != nil {
@@ -338,7 +468,7 @@ func (t *Token) GetUserForToken(token Token) (*User, error) {
 }
 
 // GenerateToken generate a secure token of exactly 26 characters in length and returns it
-func (t *Token) GenerateToken(userID int, ttl time.Duration) (*Token, error) {
+func GenerateToken(userID int, ttl time.Duration) (*Token, error) {
 	token := &Token{
 		UserID: userID,
 		Expiry: time.Now().Add(ttl),
@@ -358,7 +488,9 @@ func (t *Token) GenerateToken(userID int, ttl time.Duration) (*Token, error) {
 	return token, nil
 }
 
-func (t *Token) AuthenticateToken(r *http.Request) (*User, error) {
+// AuthenticateToken returns the active user whose unexpired bearer token is sent in the
+// Authorization header of r
+func (m Models) AuthenticateToken(r *http.Request) (*User, error) {
 	// Get the authorization header
 	authorizationHeader := r.Header.Get("Authorization")
 	if authorizationHeader == "" {
@@ -378,7 +510,7 @@ func (t *Token) AuthenticateToken(r *http.Request) (*User, error) {
 	}
 
 	// Get the token from database, using de plainText token to find it
-	tkn, err := t.GetByToken(token)
+	tkn, err := m.Token.GetByToken(r.Context(), token)
 	if err != nil {
 		return nil, errors.New("no matching token found")
 	}
@@ -389,7 +521,7 @@ func (t *Token) AuthenticateToken(r *http.Request) (*User, error) {
 	}
 
 	// Get the user associate with the token
-	user, err := t.GetUserForToken(*tkn)
+	user, err := m.Token.GetUserForToken(r.Context(), *tkn)
 	if err != nil {
 		return nil, errors.New("no matching user found")
 	}
@@ -401,13 +533,13 @@ func (t *Token) AuthenticateToken(r *http.Request) (*User, error) {
 	return user, nil
 }
 
-func (t *Token) Insert(token Token, u User) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlTokens) Insert(ctx context.Context, token Token, u User) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	// Delete any existing tokens
 	stmt := `delete from tokens where user_id = $1`
-	_, err := db.ExecContext(ctx, stmt, token.UserID)
+	_, err := s.db.ExecContext(ctx, stmt, token.UserID)
 
 	if err != nil {
 		return err
@@ -418,7 +550,7 @@ func (t *Token) Insert(token Token, u User) error {
 	stmt = `insert into tokens (user_id, email, token, token_hash, created_at, updated_at, expiry)
 		values ($1, $2, $3, $4, $5, $6, $7)`
 
-	_, err = db.ExecContext(ctx, stmt,
+	_, err = s.db.ExecContext(ctx, stmt,
 		token.UserID,
 		token.Email,
 		token.Token,
@@ -436,13 +568,13 @@ func (t *Token) Insert(token Token, u User) error {
 }
 
 // DeleteByToken deletes a token, by plain text token
-func (t *Token) DeleteByToken(plainText string) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlTokens) DeleteByToken(ctx context.Context, plainText string) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	stmt := `delete from tokens where token = $1`
 
-	_, err := db.ExecContext(ctx, stmt, plainText)
+	_, err := s.db.ExecContext(ctx, stmt, plainText)
 
 	if err != nil {
 		return err
@@ -451,13 +583,13 @@ func (t *Token) DeleteByToken(plainText string) error {
 	return nil
 }
 
-func (t *Token) DeleteTokensForUser(id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), dbTimeout)
+func (s *sqlTokens) DeleteTokensForUser(ctx context.Context, id int) error {
+	ctx, cancel := withTimeout(ctx)
 	defer cancel()
 
 	stmt := "delete from tokens where user_id = $1"
 
-	_, err := db.ExecContext(ctx, stmt, id)
+	_, err := s.db.ExecContext(ctx, stmt, id)
 
 	if err != nil {
 		return err
@@ -466,15 +598,28 @@ func (t *Token) DeleteTokensForUser(id int) error {
 	return nil
 }
 
+// DeleteAll removes every token, logging every user out, and returns how many were removed
+func (s *sqlTokens) DeleteAll(ctx context.Context) (int64, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	result, err := s.db.ExecContext(ctx, "delete from tokens")
+	if err != nil {
+		return 0, err
+	}
+
+	return result.RowsAffected()
+}
+
 // ValidToken makes certain that a give token is valid, ir order to be valid, the token must exist in the database, the associated user,
 // must exist in the database, and the token must not have expired.
-func (t *Token) ValidToken(plainText string) (bool, error) {
-	token, err := t.GetByToken(plainText)
+func (m Models) ValidToken(ctx context.Context, plainText string) (bool, error) {
+	token, err := m.Token.GetByToken(ctx, plainText)
 	if err != nil {
 		return false, errors.New("no matching token found")
 	}
 
-	_, err = t.GetUserForToken(*token)
+	_, err = m.Token.GetUserForToken(ctx, *token)
 	if err != nil {
 		return false, errors.New("no matching user found")
 	}
@@ -1,16 +1,110 @@
 package data
 
-import "testing"
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"testing"
+	"time"
+	"vue-api/internal/migrate"
+)
 
-func Test_Ping(t *testing.T) {
-	err := testDB.Ping()
+// suite lists the tests every implementation of the repositories must pass. They run in order
+// on the same data, starting from what insertData adds, and leave it as they found it
+var suite = []struct {
+	name string
+	test func(t *testing.T, models Models)
+}{
+	{"Book_GetAll", testBook_GetAll},
+	{"Book_GetOneByID", testBook_GetOneByID},
+	{"Book_GetOneBySlug", testBook_GetOneBySlug},
+	{"Book_DeleteAndRestore", testBook_DeleteAndRestore},
+	{"BookRevision_RecordAndDiff", testBookRevision_RecordAndDiff},
+	{"AuditEntry_InsertAndFilter", testAuditEntry_InsertAndFilter},
+	{"Book_UpdateConflict", testBook_UpdateConflict},
+	{"Author_CRUD", testAuthor_CRUD},
+	{"Genre_All", testGenre_All},
+	{"Book_UpdateGenres", testBook_UpdateGenres},
+	{"Book_Lifecycle", testBook_Lifecycle},
+	{"User_AndTokens", testUser_AndTokens},
+	{"Token_DeleteAll", testToken_DeleteAll},
+	{"Models_Transaction", testModels_Transaction},
+}
+
+func TestPostgres(t *testing.T) {
+	if testDB == nil {
+		t.Skip("postgres is not running: -short was given, or docker is not available")
+	}
+
+	if err := testDB.Ping(); err != nil {
+		t.Fatal("failed to ping database: ", err)
+	}
+
+	runSuite(t, New(testDB))
+}
+
+func TestSQLite(t *testing.T) {
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)&_time_format=sqlite")
 	if err != nil {
-		t.Error("failed to ping database")
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := createTables(migrate.NewSQLite(db)); err != nil {
+		t.Fatal("could not create tables: ", err)
+	}
+
+	runSuite(t, New(db))
+
+	// a query stops as soon as the work it is done for is abandoned
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := New(db).Book.GetAll(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected a cancelled context to stop the query, but got %v", err)
+	}
+}
+
+func Test_withTimeout(t *testing.T) {
+	ctx, cancel := withTimeout(context.Background())
+	defer cancel()
+
+	if deadline, ok := ctx.Deadline(); !ok || time.Until(deadline) > dbTimeout {
+		t.Errorf("expected the default timeout to apply, but got a deadline of %v", deadline)
+	}
+
+	long, cancelLong := context.WithTimeout(context.Background(), time.Hour)
+	defer cancelLong()
+
+	ctx, cancel = withTimeout(long)
+	defer cancel()
+
+	if deadline, _ := ctx.Deadline(); time.Until(deadline) < time.Minute {
+		t.Errorf("expected the caller's deadline to be kept, but got %v", deadline)
 	}
 }
 
-func TestBook_GetAll(t *testing.T) {
-	all, err := models.Book.GetAll()
+func TestMemory(t *testing.T) {
+	runSuite(t, NewMemory())
+}
+
+// runSuite adds the test data to models, then runs the tests of the suite against them
+func runSuite(t *testing.T, models Models) {
+	if err := insertData(models); err != nil {
+		t.Fatal("could not insert test data: ", err)
+	}
+
+	for _, e := range suite {
+		t.Run(e.name, func(t *testing.T) {
+			e.test(t, models)
+		})
+	}
+}
+
+func testBook_GetAll(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	all, err := models.Book.GetAll(ctx)
 	if err != nil {
 		t.Error("failed to get all books: ", err)
 	}
@@ -20,8 +114,10 @@ func TestBook_GetAll(t *testing.T) {
 	}
 }
 
-func TestBook_GetOneByID(t *testing.T) {
-	b, err := models.Book.GetOneById(1)
+func testBook_GetOneByID(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	b, err := models.Book.GetOneById(ctx, 1)
 	if err != nil {
 		t.Error("failed to get one book by id: ", err)
 	}
@@ -31,8 +127,10 @@ func TestBook_GetOneByID(t *testing.T) {
 	}
 }
 
-func TestBook_GetOneBySlug(t *testing.T) {
-	b, err := models.Book.GetOneBySlug("my-book")
+func testBook_GetOneBySlug(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	b, err := models.Book.GetOneBySlug(ctx, "my-book")
 	if err != nil {
 		t.Error("failed to get one book by slug: ", err)
 	}
@@ -41,8 +139,454 @@ func TestBook_GetOneBySlug(t *testing.T) {
 		t.Errorf("expected title to be my book but got: %s", b.Title)
 	}
 
-	_, err = models.Book.GetOneBySlug("bad-slug")
+	_, err = models.Book.GetOneBySlug(ctx, "bad-slug")
 	if err == nil {
 		t.Error("did no get an error when attempting to fetch non-existent slug")
 	}
 }
+
+func testBook_DeleteAndRestore(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	err := models.Book.DeleteByID(ctx, 1)
+	if err != nil {
+		t.Error("failed to delete book: ", err)
+	}
+
+	_, err = models.Book.GetOneById(ctx, 1)
+	if err == nil {
+		t.Error("deleted book returned by GetOneById")
+	}
+
+	trash, err := models.Book.GetAllDeleted(ctx)
+	if err != nil {
+		t.Error("failed to get deleted books: ", err)
+	}
+
+	if len(trash) != 1 || trash[0].DeletedAt == nil {
+		t.Error("deleted book not found in trash")
+	}
+
+	err = models.Book.Restore(ctx, 1)
+	if err != nil {
+		t.Error("failed to restore book: ", err)
+	}
+
+	err = models.Book.Restore(ctx, 1)
+	if err != ErrNotInTrash {
+		t.Errorf("expected ErrNotInTrash when restoring a live book, but got %v", err)
+	}
+
+	_, err = models.Book.GetOneById(ctx, 1)
+	if err != nil {
+		t.Error("restored book not returned by GetOneById: ", err)
+	}
+}
+
+func testBookRevision_RecordAndDiff(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	first, err := models.BookRevision.Record(ctx, 1, 0)
+	if err != nil {
+		t.Fatal("failed to record revision: ", err)
+	}
+
+	b, err := models.Book.GetOneById(ctx, 1)
+	if err != nil {
+		t.Fatal("failed to get book: ", err)
+	}
+
+	b.Title = "My Book, Revised"
+	if err := models.Book.Update(ctx, b); err != nil {
+		t.Fatal("failed to update book: ", err)
+	}
+
+	second, err := models.BookRevision.Record(ctx, 1, 0)
+	if err != nil {
+		t.Fatal("failed to record revision: ", err)
+	}
+
+	revisions, err := models.BookRevision.GetAllForBook(ctx, 1)
+	if err != nil {
+		t.Fatal("failed to get revisions: ", err)
+	}
+
+	if len(revisions) != 2 || revisions[0].ID != second {
+		t.Fatalf("expected 2 revisions with newest first, but got %d", len(revisions))
+	}
+
+	from, _ := models.BookRevision.GetOne(ctx, first)
+	to, _ := models.BookRevision.GetOne(ctx, second)
+
+	changes := from.Diff(to)
+	if len(changes) != 1 || changes[0].Field != "title" {
+		t.Errorf("expected only the title to change, but got %v", changes)
+	}
+
+	// put the book back the way the other tests expect it
+	original := from.Book()
+	if err := models.Book.Update(ctx, &original); err != nil {
+		t.Error("failed to revert book: ", err)
+	}
+}
+
+func testAuditEntry_InsertAndFilter(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	err := models.Audit.Insert(ctx, AuditEntry{
+		Action:   "book.update",
+		Entity:   "book",
+		EntityID: 1,
+		Before:   []byte(`{"title": "My Book"}`),
+		IP:       "127.0.0.1",
+	})
+	if err != nil {
+		t.Fatal("failed to insert audit entry: ", err)
+	}
+
+	entries, err := models.Audit.Filter(ctx, AuditFilter{Entity: "book", EntityID: 1})
+	if err != nil {
+		t.Fatal("failed to filter audit log: ", err)
+	}
+
+	if len(entries) != 1 || entries[0].After != nil {
+		t.Errorf("expected one entry with no after snapshot, but got %d entries", len(entries))
+	}
+
+	entries, err = models.Audit.Filter(ctx, AuditFilter{Action: "user.delete"})
+	if err != nil {
+		t.Fatal("failed to filter audit log: ", err)
+	}
+
+	if len(entries) != 0 {
+		t.Error("filter by action returned unrelated entries")
+	}
+}
+
+func testBook_UpdateConflict(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	b, err := models.Book.GetOneById(ctx, 1)
+	if err != nil {
+		t.Fatal("failed to get book: ", err)
+	}
+
+	stale := *b
+
+	if err := models.Book.Update(ctx, b); err != nil {
+		t.Fatal("failed to update book: ", err)
+	}
+
+	if b.Version != stale.Version+1 {
+		t.Errorf("expected version %d after update but got %d", stale.Version+1, b.Version)
+	}
+
+	err = models.Book.Update(ctx, &stale)
+	if err != ErrEditConflict {
+		t.Errorf("expected ErrEditConflict when updating a stale book, but got %v", err)
+	}
+
+	// a version of 0 skips the check
+	stale.Version = 0
+	if err := models.Book.Update(ctx, &stale); err != nil {
+		t.Error("unconditional update failed: ", err)
+	}
+
+	// but a book which is not there is not a conflict
+	missing := Book{ID: 1000, Title: "Missing", Version: 1}
+	if err := models.Book.Update(ctx, &missing); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no rows updating a book which does not exist, but got %v", err)
+	}
+}
+
+func testAuthor_CRUD(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	id, err := models.Author.Insert(ctx, Author{AuthorName: "Jane Doe"})
+	if err != nil {
+		t.Fatal("failed to insert author: ", err)
+	}
+
+	a, err := models.Author.GetOne(ctx, id)
+	if err != nil {
+		t.Fatal("failed to get author: ", err)
+	}
+
+	a.AuthorName = "Jane Roe"
+	if err := models.Author.Update(ctx, a); err != nil {
+		t.Error("failed to update author: ", err)
+	}
+
+	if err := models.Author.DeleteByID(ctx, 1); err != ErrInUse {
+		t.Errorf("expected ErrInUse deleting an author with books, but got %v", err)
+	}
+
+	if err := models.Author.DeleteByID(ctx, id); err != nil {
+		t.Error("failed to delete author: ", err)
+	}
+}
+
+func testGenre_All(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	all, err := models.Genre.All(ctx)
+	if err != nil {
+		t.Error("failed to get all genres: ", err)
+	}
+
+	if len(all) != 7 {
+		t.Errorf("expected 7 genres but got %d", len(all))
+	}
+
+	if err := models.Genre.DeleteByID(ctx, 3); err != ErrInUse {
+		t.Errorf("expected ErrInUse deleting a genre assigned to a book, but got %v", err)
+	}
+}
+
+func testBook_UpdateGenres(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	b, err := models.Book.GetOneById(ctx, 1)
+	if err != nil {
+		t.Fatal("failed to get book: ", err)
+	}
+	original := b.GenreIDs
+
+	// nil genre ids leave the genres alone
+	b.GenreIDs = nil
+	if err := models.Book.Update(ctx, b); err != nil {
+		t.Fatal("failed to update book: ", err)
+	}
+
+	b, _ = models.Book.GetOneById(ctx, 1)
+	if len(b.GenreIDs) != len(original) {
+		t.Errorf("expected %d genres to be kept, but got %d", len(original), len(b.GenreIDs))
+	}
+
+	// an empty slice clears them
+	b.GenreIDs = []int{}
+	if err := models.Book.Update(ctx, b); err != nil {
+		t.Fatal("failed to update book: ", err)
+	}
+
+	b, _ = models.Book.GetOneById(ctx, 1)
+	if len(b.GenreIDs) != 0 {
+		t.Errorf("expected genres to be cleared, but got %v", b.GenreIDs)
+	}
+
+	b.GenreIDs = original
+	if err := models.Book.Update(ctx, b); err != nil {
+		t.Error("failed to put genres back: ", err)
+	}
+}
+
+func testBook_Lifecycle(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	authorID, _ := models.Author.Insert(ctx, Author{AuthorName: "Jane Austen"})
+	regencyID, _ := models.Genre.Insert(ctx, Genre{GenreName: "Regency"})
+	satireID, _ := models.Genre.Insert(ctx, Genre{GenreName: "Satire"})
+
+	id, err := models.Book.Insert(ctx, Book{Title: "Pride and Prejudice", AuthorID: authorID, PublicationYear: 1813, GenreIDs: []int{satireID, regencyID}})
+	if err != nil {
+		t.Fatal("failed to insert book: ", err)
+	}
+
+	b, err := models.Book.GetOneBySlug(ctx, "pride-and-prejudice")
+	if err != nil {
+		t.Fatal("failed to get the book by slug: ", err)
+	}
+
+	if b.ID != id || b.Author.AuthorName != "Jane Austen" || b.Version != 1 {
+		t.Errorf("expected the stored book with its author, but got %+v", b)
+	}
+
+	if len(b.Genres) != 2 || b.Genres[0].GenreName != "Regency" || b.GenreIDs[0] != regencyID {
+		t.Errorf("expected the genres sorted by name, but got %+v", b.Genres)
+	}
+
+	b.Title = "Emma"
+	if err := models.Book.Update(ctx, b); err != nil {
+		t.Fatal("failed to update book: ", err)
+	}
+
+	if _, err := models.Book.GetOneBySlug(ctx, "emma"); err != nil {
+		t.Error("expected the slug to follow the title: ", err)
+	}
+
+	if err := models.Book.DeleteByID(ctx, id); err != nil {
+		t.Fatal("failed to delete book: ", err)
+	}
+
+	if _, err := models.Book.GetOneById(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no rows for a book in the trash, but got %v", err)
+	}
+
+	if err := models.Author.DeleteByID(ctx, authorID); err != ErrInUse {
+		t.Errorf("expected ErrInUse deleting an author with a book in the trash, but got %v", err)
+	}
+
+	if err := models.Book.DeleteByID(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no rows deleting a book already in the trash, but got %v", err)
+	}
+
+	if err := models.Book.Update(ctx, b); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no rows updating a book in the trash, but got %v", err)
+	}
+
+	purged, err := models.Book.PurgeDeleted(ctx, time.Now().Add(time.Second))
+	if err != nil || len(purged) != 1 || purged[0] != "emma" {
+		t.Errorf("expected the slug of the one book purged, but got %v and %v", purged, err)
+	}
+
+	if err := models.Book.Restore(ctx, id); err != ErrNotInTrash {
+		t.Errorf("expected ErrNotInTrash restoring a purged book, but got %v", err)
+	}
+
+	for _, genreID := range []int{regencyID, satireID} {
+		if err := models.Genre.DeleteByID(ctx, genreID); err != nil {
+			t.Error("failed to delete a genre once its book was purged: ", err)
+		}
+	}
+
+	if err := models.Author.DeleteByID(ctx, authorID); err != nil {
+		t.Error("failed to delete an author once their book was purged: ", err)
+	}
+}
+
+func testUser_AndTokens(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	id, err := models.User.Insert(ctx, User{Email: "reader@example.com", FirstName: "Avid", LastName: "Reader", Password: "password", Active: 1})
+	if err != nil {
+		t.Fatal("failed to insert user: ", err)
+	}
+
+	u, err := models.User.GetByEmail(ctx, "reader@example.com")
+	if err != nil {
+		t.Fatal("failed to get user by email: ", err)
+	}
+
+	if ok, _ := u.PasswordMatches("password"); !ok || u.Version != 1 {
+		t.Errorf("expected a hashed password which matches, and version 1, but got %+v", u)
+	}
+
+	first, _ := GenerateToken(id, time.Hour)
+	second, _ := GenerateToken(id, time.Hour)
+	_ = models.Token.Insert(ctx, *first, *u)
+	_ = models.Token.Insert(ctx, *second, *u)
+
+	if _, err := models.Token.GetByToken(ctx, first.Token); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected a new token to replace the old one, but got %v", err)
+	}
+
+	if owner, err := models.Token.GetUserForToken(ctx, *second); err != nil || owner.ID != id {
+		t.Errorf("expected the token to belong to the user, but got %v", err)
+	}
+
+	all, _ := models.User.GetAll(ctx)
+	for _, listed := range all {
+		if listed.ID == id && listed.Token.ID != 1 {
+			t.Error("expected the user to be listed as logged in")
+		}
+	}
+
+	if err := models.User.DeleteByID(ctx, id); err != nil {
+		t.Fatal("failed to delete user: ", err)
+	}
+
+	if err := models.User.DeleteByID(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no rows deleting a user already in the trash, but got %v", err)
+	}
+
+	if err := models.User.Update(ctx, u); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected no rows updating a user in the trash, but got %v", err)
+	}
+
+	if _, err := models.Token.GetByToken(ctx, second.Token); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected deleting a user to remove their tokens, but got %v", err)
+	}
+
+	if err := models.User.Restore(ctx, id); err != nil {
+		t.Fatal("failed to restore user: ", err)
+	}
+
+	u.Version = 0
+	u.Language = "es"
+	if err := models.User.Update(ctx, u); err != nil {
+		t.Fatal("failed to update user: ", err)
+	}
+
+	if restored, err := models.User.GetOne(ctx, id); err != nil || restored.Language != "es" || restored.Version != 2 {
+		t.Errorf("expected the restored and updated user, but got %+v and %v", restored, err)
+	}
+}
+
+func testToken_DeleteAll(t *testing.T, models Models) {
+	ctx := context.Background()
+
+	id, err := models.User.Insert(ctx, User{Email: "tokens@example.com", FirstName: "Tok", LastName: "En", Password: "password", Active: 1})
+	if err != nil {
+		t.Fatal("failed to insert user: ", err)
+	}
+
+	user, _ := models.User.GetOne(ctx, id)
+	for i := 0; i < 2; i++ {
+		token, err := GenerateToken(id, time.Hour)
+		if err != nil {
+			t.Fatal("failed to generate token: ", err)
+		}
+		if err := models.Token.Insert(ctx, *token, *user); err != nil {
+			t.Fatal("failed to insert token: ", err)
+		}
+	}
+
+	removed, err := models.Token.DeleteAll(ctx)
+	if err != nil {
+		t.Fatal("failed to delete tokens: ", err)
+	}
+
+	if removed < 1 {
+		t.Errorf("expected tokens to be removed, but %d were", removed)
+	}
+}
+
+func testModels_Transaction(t *testing.T, models Models) {
+	ctx := context.Background()
+	failed := errors.New("failed")
+
+	var id int
+	err := models.Transaction(ctx, func(tx Models) error {
+		var err error
+		if id, err = tx.Author.Insert(ctx, Author{AuthorName: "Rolled Back"}); err != nil {
+			return err
+		}
+		if _, err := tx.Author.GetOne(ctx, id); err != nil {
+			t.Error("expected the transaction to see its own changes: ", err)
+		}
+		return failed
+	})
+	if err != failed {
+		t.Errorf("expected the error of the failed transaction, but got %v", err)
+	}
+
+	if _, err := models.Author.GetOne(ctx, id); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the changes of a failed transaction to be undone, but got %v", err)
+	}
+
+	err = models.Transaction(ctx, func(tx Models) error {
+		id, err = tx.Author.Insert(ctx, Author{AuthorName: "Committed"})
+		return err
+	})
+	if err != nil {
+		t.Fatal("failed to commit transaction: ", err)
+	}
+
+	if _, err := models.Author.GetOne(ctx, id); err != nil {
+		t.Error("expected the changes of a transaction to be kept: ", err)
+	}
+
+	if err := models.Author.DeleteByID(ctx, id); err != nil {
+		t.Error("failed to delete author: ", err)
+	}
+}
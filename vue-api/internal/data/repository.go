@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Models holds the repositories through which the application reads and stores its data.
+// Handlers only see the interfaces, so the storage behind them can be swapped, or faked in tests.
+//
+// Every method takes the context of the work it is done for, which cancels the query when the
+// work is abandoned and carries request-scoped values such as the request id. Every
+// implementation returns sql.ErrNoRows when the record asked for does not exist, and the errors
+// declared in this package where their descriptions say so
+type Models struct {
+	User         UserRepository
+	Token        TokenRepository
+	Book         BookRepository
+	Author       AuthorRepository
+	Genre        GenreRepository
+	BookRevision BookRevisionRepository
+	Audit        AuditRepository
+
+	// transaction implements Transaction; it is nil for models put together by hand, in tests
+	transaction func(ctx context.Context, fn func(tx Models) error) error
+}
+
+// Transaction runs fn with models whose changes are made all together, when fn returns nil, or
+// not at all, when it returns an error, which Transaction returns. fn must only use tx, not the
+// models it was called on. Models put together by hand have no transactions, and run fn with
+// themselves
+func (m Models) Transaction(ctx context.Context, fn func(tx Models) error) error {
+	if m.transaction == nil {
+		return fn(m)
+	}
+	return m.transaction(ctx, fn)
+}
+
+// UserRepository stores users. Deleted users go to the trash, from which they can be restored
+// until they are purged
+type UserRepository interface {
+	GetAll(ctx context.Context) ([]*User, error)
+	GetByEmail(ctx context.Context, email string) (*User, error)
+	GetOne(ctx context.Context, id int) (*User, error)
+	Insert(ctx context.Context, user User) (int, error)
+	Update(ctx context.Context, u *User) error
+	ResetPassword(ctx context.Context, id int, password string) error
+	DeleteByID(ctx context.Context, id int) error
+	GetAllDeleted(ctx context.Context) ([]*User, error)
+	Restore(ctx context.Context, id int) error
+	PurgeDeleted(ctx context.Context, before time.Time) (int64, error)
+}
+
+// TokenRepository stores the bearer tokens of logged in users
+type TokenRepository interface {
+	GetByToken(ctx context.Context, plainText string) (*Token, error)
+	GetUserForToken(ctx context.Context, token Token) (*User, error)
+	Insert(ctx context.Context, token Token, u User) error
+	DeleteByToken(ctx context.Context, plainText string) error
+	DeleteTokensForUser(ctx context.Context, id int) error
+	DeleteAll(ctx context.Context) (int64, error)
+}
+
+// BookRepository stores books, along with the genres assigned to them. Deleted books go to the
+// trash, from which they can be restored until they are purged
+type BookRepository interface {
+	GetAll(ctx context.Context) ([]*Book, error)
+	GetAllPaginated(ctx context.Context, page, pageSize int) ([]*Book, error)
+	GetOneById(ctx context.Context, id int) (*Book, error)
+	GetOneBySlug(ctx context.Context, slug string) (*Book, error)
+	Insert(ctx context.Context, book Book) (int, error)
+	Update(ctx context.Context, b *Book) error
+	DeleteByID(ctx context.Context, id int) error
+	GetAllDeleted(ctx context.Context) ([]*Book, error)
+	Restore(ctx context.Context, id int) error
+	PurgeDeleted(ctx context.Context, before time.Time) ([]string, error)
+}
+
+// AuthorRepository stores authors
+type AuthorRepository interface {
+	All(ctx context.Context) ([]*Author, error)
+	GetOne(ctx context.Context, id int) (*Author, error)
+	Insert(ctx context.Context, author Author) (int, error)
+	Update(ctx context.Context, a *Author) error
+	DeleteByID(ctx context.Context, id int) error
+}
+
+// GenreRepository stores genres
+type GenreRepository interface {
+	All(ctx context.Context) ([]*Genre, error)
+	GetOne(ctx context.Context, id int) (*Genre, error)
+	Insert(ctx context.Context, genre Genre) (int, error)
+	Update(ctx context.Context, g *Genre) error
+	DeleteByID(ctx context.Context, id int) error
+}
+
+// BookRevisionRepository stores the history of each book
+type BookRevisionRepository interface {
+	Record(ctx context.Context, bookID, userID int) (int, error)
+	HasRevisions(ctx context.Context, bookID int) (bool, error)
+	GetAllForBook(ctx context.Context, bookID int) ([]*BookRevision, error)
+	GetOne(ctx context.Context, id int) (*BookRevision, error)
+}
+
+// AuditRepository stores the audit log
+type AuditRepository interface {
+	Insert(ctx context.Context, entry AuditEntry) error
+	Filter(ctx context.Context, filter AuditFilter) ([]*AuditEntry, error)
+}
+
+// The SQL implementation of the repositories. The queries keep to what PostgreSQL and SQLite
+// have in common, so the same code serves both. Each holds the connection pool it was created
+// with, so that models for more than one database can be used side by side, and every query
+// goes through it to be traced
+type (
+	sqlUsers     struct{ db *tracedDB }
+	sqlTokens    struct{ db *tracedDB }
+	sqlBooks     struct{ db *tracedDB }
+	sqlAuthors   struct{ db *tracedDB }
+	sqlGenres    struct{ db *tracedDB }
+	sqlRevisions struct{ db *tracedDB }
+	sqlAudit     struct{ db *tracedDB }
+)
+
+// New returns models which store their data in the database behind dbPool, PostgreSQL or SQLite,
+// whose schema must have been set up by package migrate
+func New(dbPool *sql.DB) Models {
+	return sqlModels(newTracedDB(dbPool))
+}
+
+// sqlModels returns the SQL repositories, running their queries through db
+func sqlModels(db *tracedDB) Models {
+	return Models{
+		User:         &sqlUsers{db: db},
+		Token:        &sqlTokens{db: db},
+		Book:         &sqlBooks{db: db},
+		Author:       &sqlAuthors{db: db},
+		Genre:        &sqlGenres{db: db},
+		BookRevision: &sqlRevisions{db: db},
+		Audit:        &sqlAudit{db: db},
+		transaction: func(ctx context.Context, fn func(tx Models) error) error {
+			return db.inTx(ctx, func(tx *tracedDB) error {
+				return fn(sqlModels(tx))
+			})
+		},
+	}
+}
@@ -0,0 +1,209 @@
+package data
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// ErrRevisionMismatch is returned when a revision does not belong to the book it is used with
+var ErrRevisionMismatch = errors.New("revision does not belong to this book")
+
+// BookRevision is a snapshot of the editable fields of a book, taken every time the book is saved
+type BookRevision struct {
+	ID              int       `json:"id"`
+	BookID          int       `json:"book_id"`
+	UserID          int       `json:"user_id"`
+	UserEmail       string    `json:"user_email"`
+	Title           string    `json:"title"`
+	AuthorID        int       `json:"author_id"`
+	PublicationYear int       `json:"publication_year"`
+	Description     string    `json:"description"`
+	GenreIDs        []int     `json:"genre_ids"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// RevisionChange describes one field that differs between two revisions of a book
+type RevisionChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+// Record stores the current state of the book with the given id as a new revision, attributed
+// to the user with the given id (or to nobody, if userID is 0), and returns the id of the revision
+func (s *sqlRevisions) Record(ctx context.Context, bookID, userID int) (int, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	_, genreIDs, err := genresForBook(ctx, s.db, bookID)
+	if err != nil {
+		return 0, err
+	}
+
+	if genreIDs == nil {
+		genreIDs = []int{}
+	}
+
+	genres, err := json.Marshal(genreIDs)
+	if err != nil {
+		return 0, err
+	}
+
+	stmt := `insert into book_revisions (book_id, user_id, title, author_id, publication_year, description, genre_ids, created_at)
+			select b.id, nullif($2, 0), b.title, b.author_id, b.publication_year, b.description, $3, $4
+			from books b where b.id = $1
+			returning id`
+
+	var newID int
+	err = s.db.QueryRowContext(ctx, stmt, bookID, userID, string(genres), time.Now()).Scan(&newID)
+	if err != nil {
+		return 0, err
+	}
+
+	return newID, nil
+}
+
+// HasRevisions reports whether any revision has been recorded for the book with the given id
+func (s *sqlRevisions) HasRevisions(ctx context.Context, bookID int) (bool, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select exists(select 1 from book_revisions where book_id = $1)`
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, query, bookID).Scan(&exists)
+	if err != nil {
+		return false, err
+	}
+
+	return exists, nil
+}
+
+// GetAllForBook returns all revisions of the book with the given id, newest first
+func (s *sqlRevisions) GetAllForBook(ctx context.Context, bookID int) ([]*BookRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select r.id, r.book_id, coalesce(r.user_id, 0), coalesce(u.email, ''), r.title, r.author_id,
+			r.publication_year, r.description, r.genre_ids, r.created_at
+			from book_revisions r
+			left join users u on (r.user_id = u.id)
+			where r.book_id = $1
+			order by r.id desc`
+
+	rows, err := s.db.QueryContext(ctx, query, bookID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var revisions []*BookRevision
+	for rows.Next() {
+		var revision BookRevision
+		var genres string
+		err := rows.Scan(
+			&revision.ID,
+			&revision.BookID,
+			&revision.UserID,
+			&revision.UserEmail,
+			&revision.Title,
+			&revision.AuthorID,
+			&revision.PublicationYear,
+			&revision.Description,
+			&genres,
+			&revision.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := json.Unmarshal([]byte(genres), &revision.GenreIDs); err != nil {
+			return nil, err
+		}
+
+		revisions = append(revisions, &revision)
+	}
+
+	return revisions, nil
+}
+
+// GetOne returns one revision by id
+func (s *sqlRevisions) GetOne(ctx context.Context, id int) (*BookRevision, error) {
+	ctx, cancel := withTimeout(ctx)
+	defer cancel()
+
+	query := `select r.id, r.book_id, coalesce(r.user_id, 0), coalesce(u.email, ''), r.title, r.author_id,
+			r.publication_year, r.description, r.genre_ids, r.created_at
+			from book_revisions r
+			left join users u on (r.user_id = u.id)
+			where r.id = $1`
+
+	var revision BookRevision
+	var genres string
+	err := s.db.QueryRowContext(ctx, query, id).Scan(
+		&revision.ID,
+		&revision.BookID,
+		&revision.UserID,
+		&revision.UserEmail,
+		&revision.Title,
+		&revision.AuthorID,
+		&revision.PublicationYear,
+		&revision.Description,
+		&genres,
+		&revision.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(genres), &revision.GenreIDs); err != nil {
+		return nil, err
+	}
+
+	return &revision, nil
+}
+
+// Book returns a book holding the values stored in the revision, ready to be saved with Update
+func (r *BookRevision) Book() Book {
+	genreIDs := r.GenreIDs
+	if genreIDs == nil {
+		genreIDs = []int{}
+	}
+
+	return Book{
+		ID:              r.BookID,
+		Title:           r.Title,
+		AuthorID:        r.AuthorID,
+		PublicationYear: r.PublicationYear,
+		Description:     r.Description,
+		GenreIDs:        genreIDs,
+	}
+}
+
+// Diff returns the fields that changed going from the receiver revision to the other one
+func (r *BookRevision) Diff(other *BookRevision) []RevisionChange {
+	changes := []RevisionChange{}
+
+	if r.Title != other.Title {
+		changes = append(changes, RevisionChange{Field: "title", From: r.Title, To: other.Title})
+	}
+
+	if r.AuthorID != other.AuthorID {
+		changes = append(changes, RevisionChange{Field: "author_id", From: r.AuthorID, To: other.AuthorID})
+	}
+
+	if r.PublicationYear != other.PublicationYear {
+		changes = append(changes, RevisionChange{Field: "publication_year", From: r.PublicationYear, To: other.PublicationYear})
+	}
+
+	if r.Description != other.Description {
+		changes = append(changes, RevisionChange{Field: "description", From: r.Description, To: other.Description})
+	}
+
+	if !reflect.DeepEqual(r.GenreIDs, other.GenreIDs) {
+		changes = append(changes, RevisionChange{Field: "genre_ids", From: r.GenreIDs, To: other.GenreIDs})
+	}
+
+	return changes
+}
@@ -1,17 +1,21 @@
 package data
 
 import (
+	"context"
 	"database/sql"
+	"flag"
 	"fmt"
 	"log"
 	"os"
 	"testing"
+	"vue-api/internal/migrate"
 
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"github.com/ory/dockertest/v3"
 	"github.com/ory/dockertest/v3/docker"
+	_ "modernc.org/sqlite"
 )
 
 // IMPORTANT -- change the values below to ones that work for your system. The only value you should have to
@@ -25,15 +29,36 @@ var (
 	dsn      = "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC connect_timeout=5"
 )
 
-var models Models
 var testDB *sql.DB
 var resource *dockertest.Resource
 var pool *dockertest.Pool
 
+// TestMain starts postgres in docker for TestPostgres. With -short it does not, and neither when
+// postgres cannot be started, for instance because docker is not available; either way the suite
+// still runs against sqlite and memory
 func TestMain(m *testing.M) {
+	flag.Parse()
+	if !testing.Short() {
+		if err := startPostgres(); err != nil {
+			log.Printf("skipping postgres: %s", err)
+			stopPostgres()
+		}
+	}
+
+	code := m.Run()
+	stopPostgres()
+
+	os.Exit(code)
+}
+
+// startPostgres runs postgres in docker, and sets testDB to a database with the schema in place
+func startPostgres() error {
 	p, err := dockertest.NewPool("")
+	if err == nil {
+		err = p.Client.Ping()
+	}
 	if err != nil {
-		log.Fatalf("could not connect to docker: %s", err)
+		return fmt.Errorf("could not connect to docker: %w", err)
 	}
 
 	pool = p
@@ -56,8 +81,7 @@ func TestMain(m *testing.M) {
 
 	resource, err = pool.RunWithOptions(&opts)
 	if err != nil {
-		_ = pool.Purge(resource)
-		log.Fatalf("could not start resource: %s", err)
+		return fmt.Errorf("could not start resource: %w", err)
 	}
 
 	if err := pool.Retry(func() error {
@@ -69,260 +93,72 @@ func TestMain(m *testing.M) {
 		}
 		return testDB.Ping()
 	}); err != nil {
-		_ = pool.Purge(resource)
-		log.Fatalf("could not connect to docker: %s", err)
+		return fmt.Errorf("could not connect to postgres: %w", err)
 	}
 
-	// get our models
-	models = New(testDB)
-
-	err = createTables(testDB)
-	if err != nil {
-		log.Fatalf("could not create tables: %v", err)
-	}
-
-	err = insertData(testDB)
-	if err != nil {
-		log.Fatalf("could not create tables: %v", err)
-	}
-
-	code := m.Run()
-
-	if err := pool.Purge(resource); err != nil {
-		log.Fatalf("could not purge resource: %s", err)
+	if err := createTables(migrate.New(testDB)); err != nil {
+		return fmt.Errorf("could not create tables: %w", err)
 	}
 
-	os.Exit(code)
+	return nil
 }
 
-// createTables will create all the tables in our test database, duplicating the structure
-// of the production environment
-func createTables(db *sql.DB) error {
-	stmt := `
-	--
--- Name: authors; Type: TABLE; Schema: public; Owner: -
---
-
-CREATE TABLE public.authors (
-    id integer NOT NULL,
-    author_name character varying(512),
-    created_at timestamp without time zone,
-    updated_at timestamp without time zone
-);
-
-
---
--- Name: authors_id_seq; Type: SEQUENCE; Schema: public; Owner: -
---
-
-ALTER TABLE public.authors ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
-    SEQUENCE NAME public.authors_id_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1
-);
-
-
---
--- Name: books; Type: TABLE; Schema: public; Owner: -
---
-
-CREATE TABLE public.books (
-    id integer NOT NULL,
-    title character varying(512),
-    author_id integer,
-    publication_year integer,
-    created_at timestamp without time zone,
-    updated_at timestamp without time zone,
-    slug character varying(512),
-    description text
-);
-
-
---
--- Name: books_genres; Type: TABLE; Schema: public; Owner: -
---
-
-CREATE TABLE public.books_genres (
-    id integer NOT NULL,
-    book_id integer,
-    genre_id integer,
-    created_at timestamp without time zone,
-    updated_at timestamp without time zone
-);
-
-
---
--- Name: books_genres_id_seq; Type: SEQUENCE; Schema: public; Owner: -
---
-
-ALTER TABLE public.books_genres ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
-    SEQUENCE NAME public.books_genres_id_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1
-);
-
-
---
--- Name: books_id_seq; Type: SEQUENCE; Schema: public; Owner: -
---
-
-ALTER TABLE public.books ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
-    SEQUENCE NAME public.books_id_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1
-);
-
-
---
--- Name: genres; Type: TABLE; Schema: public; Owner: -
---
-
-CREATE TABLE public.genres (
-    id integer NOT NULL,
-    genre_name character varying(255),
-    created_at timestamp without time zone,
-    updated_at timestamp without time zone
-);
-
-
---
--- Name: genres_id_seq; Type: SEQUENCE; Schema: public; Owner: -
---
-
-ALTER TABLE public.genres ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
-    SEQUENCE NAME public.genres_id_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1
-);
-
-
---
--- Name: tokens; Type: TABLE; Schema: public; Owner: -
---
-
-CREATE TABLE public.tokens (
-    id integer NOT NULL,
-    user_id integer,
-    email character varying(255) NOT NULL,
-    token character varying(255) NOT NULL,
-    token_hash bytea NOT NULL,
-    expiry timestamp with time zone NOT NULL,
-    created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL
-);
-
-
---
--- Name: tokens_id_seq; Type: SEQUENCE; Schema: public; Owner: -
---
-
-ALTER TABLE public.tokens ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
-    SEQUENCE NAME public.tokens_id_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1
-);
-
-
---
--- Name: users; Type: TABLE; Schema: public; Owner: -
---
-
-CREATE TABLE public.users (
-    id integer NOT NULL,
-    email character varying(255),
-    first_name character varying(255) NOT NULL,
-    last_name character varying(255) NOT NULL,
-    password character varying(60) NOT NULL,
-    created_at timestamp without time zone NOT NULL,
-    updated_at timestamp without time zone NOT NULL,
-    user_active integer DEFAULT 0
-);
-
-
---
--- Name: users_id_seq; Type: SEQUENCE; Schema: public; Owner: -
---
-
-ALTER TABLE public.users ALTER COLUMN id ADD GENERATED ALWAYS AS IDENTITY (
-    SEQUENCE NAME public.users_id_seq
-    START WITH 1
-    INCREMENT BY 1
-    NO MINVALUE
-    NO MAXVALUE
-    CACHE 1
-);
-`
+// stopPostgres removes whatever startPostgres got running, and leaves testDB nil so that
+// TestPostgres is skipped
+func stopPostgres() {
+	if testDB != nil {
+		_ = testDB.Close()
+		testDB = nil
+	}
 
-	_, err := db.Exec(stmt)
-	if err != nil {
-		return err
+	if resource != nil {
+		if err := pool.Purge(resource); err != nil {
+			log.Printf("could not purge resource: %s", err)
+		}
+		resource = nil
 	}
-	return nil
 }
 
-// insertData inserts a minimal amout of test data into the test database
-func insertData(db *sql.DB) error {
+// createTables builds the schema of the test database with the same migrations used in
+// production. Every migration is reverted and applied again on the way, so that the down
+// migrations are tested too
+func createTables(m *migrate.Migrator) error {
+	ctx := context.Background()
 
-	// insert one author
-	stmt := `
-	insert into authors (author_name, created_at, updated_at)
-	values ('John Smith', '2022-03-05 00:00:01', '2022-03-05 00:00:01')`
-	_, err := db.Exec(stmt)
-	if err != nil {
+	if _, err := m.Up(ctx); err != nil {
 		return err
 	}
 
-	// insert all genres
-	stmt = `insert into genres (genre_name, created_at, updated_at)
-	values 
-	('Science Fiction', '2020-01-01 01:00:00', '2020-01-01 01:00:00'),
-	('Fantasy', '2020-01-01 01:00:00', '2020-01-01 01:00:00'),
-	('Romance', '2020-01-01 01:00:00', '2020-01-01 01:00:00'),
-	('Thriller', '2020-01-01 01:00:00', '2020-01-01 01:00:00'),
-	('Mystery', '2020-01-01 01:00:00', '2020-01-01 01:00:00'),
-	('Horror', '2020-01-01 01:00:00', '2020-01-01 01:00:00'),
-	('Classic', '2020-01-01 01:00:00', '2020-01-01 01:00:00')`
-	_, err = db.Exec(stmt)
-	if err != nil {
+	if _, err := m.To(ctx, 0); err != nil {
 		return err
 	}
 
-	// insert one book
-	stmt = `
-	insert into books (title, author_id, publication_year, created_at, updated_at, slug, description)
-	values
-	('My Book', 1, 2020, '2020-01-01 01:00:00', '2020-01-01 01:00:00', 'my-book', 'My description')`
-	_, err = db.Exec(stmt)
-	if err != nil {
+	_, err := m.Up(ctx)
+	return err
+}
+
+// insertData inserts a minimal amount of test data through models: one author, all genres, and
+// one book with one genre. They get ids from 1, which the tests rely on
+func insertData(models Models) error {
+	ctx := context.Background()
+
+	if _, err := models.Author.Insert(ctx, Author{AuthorName: "John Smith"}); err != nil {
 		return err
 	}
 
-	// assign a genre to the book
-	stmt = `
-	insert into books_genres (book_id, genre_id, created_at, updated_at)
-	values
-	(1, 3, '2020-01-01 01:00:00', '2020-01-01 01:00:00')`
-	_, err = db.Exec(stmt)
-	if err != nil {
-		return err
+	for _, name := range []string{"Science Fiction", "Fantasy", "Romance", "Thriller", "Mystery", "Horror", "Classic"} {
+		if _, err := models.Genre.Insert(ctx, Genre{GenreName: name}); err != nil {
+			return err
+		}
 	}
 
-	// you can do the same thing for users & tokens, of course...
+	_, err := models.Book.Insert(ctx, Book{
+		Title:           "My Book",
+		AuthorID:        1,
+		PublicationYear: 2020,
+		Description:     "My description",
+		GenreIDs:        []int{3},
+	})
 
-	return nil
+	return err
 }
@@ -0,0 +1,148 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName names the tracer of this package. It is looked up for every span rather than once,
+// so that spans go to whichever tracer provider is installed at the time
+const tracerName = "vue-api/internal/data"
+
+// tracedDB is a connection pool, or a transaction begun on one, which traces every query run
+// through it, with a span named after the kind of statement and holding its text. Spans cover
+// running the query, not reading its rows
+type tracedDB struct {
+	conn   queryer
+	pool   *sql.DB // nil when conn is a transaction
+	system attribute.KeyValue
+	sqlite bool
+}
+
+// queryer is what queries run on: a *sql.DB or a *sql.Tx
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+// newTracedDB wraps db, which may be nil in tests that never reach the database
+func newTracedDB(db *sql.DB) *tracedDB {
+	system, sqlite := semconv.DBSystemPostgreSQL, false
+	if db != nil && strings.Contains(fmt.Sprintf("%T", db.Driver()), "sqlite") {
+		system, sqlite = semconv.DBSystemSqlite, true
+	}
+
+	return &tracedDB{conn: db, pool: db, system: system, sqlite: sqlite}
+}
+
+// Errors from the database pass through dbError, so that broken constraints are reported the same
+// way whichever database is used. Arguments pass through bind
+
+func (db *tracedDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	ctx, span := db.start(ctx, query)
+	rows, err := db.conn.QueryContext(ctx, query, db.bind(args)...)
+	endSpan(span, err)
+	return rows, dbError(err)
+}
+
+func (db *tracedDB) QueryRowContext(ctx context.Context, query string, args ...interface{}) tracedRow {
+	ctx, span := db.start(ctx, query)
+	row := db.conn.QueryRowContext(ctx, query, db.bind(args)...)
+	endSpan(span, row.Err())
+	return tracedRow{row}
+}
+
+func (db *tracedDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, span := db.start(ctx, query)
+	result, err := db.conn.ExecContext(ctx, query, db.bind(args)...)
+	endSpan(span, err)
+	return result, dbError(err)
+}
+
+// tracedRow is the row returned by tracedDB.QueryRowContext
+type tracedRow struct {
+	*sql.Row
+}
+
+func (r tracedRow) Scan(dest ...interface{}) error {
+	return dbError(r.Row.Scan(dest...))
+}
+
+// inTx runs fn with a tracedDB whose queries all run in one transaction, which is committed when
+// fn returns nil and rolled back otherwise. When db is a transaction already, fn runs in it
+func (db *tracedDB) inTx(ctx context.Context, fn func(tx *tracedDB) error) error {
+	if db.pool == nil {
+		return fn(db)
+	}
+
+	tx, err := db.pool.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := fn(&tracedDB{conn: tx, system: db.system, sqlite: db.sqlite}); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// bind returns the arguments of a query as they should be sent to the database. sqlite stores
+// times as text and compares them as text, which only sorts them in time order when they are all
+// in the same zone, so times sent to it are put in UTC
+func (db *tracedDB) bind(args []interface{}) []interface{} {
+	if !db.sqlite {
+		return args
+	}
+
+	bound := make([]interface{}, len(args))
+	for i, arg := range args {
+		switch t := arg.(type) {
+		case time.Time:
+			bound[i] = t.UTC()
+		case *time.Time:
+			if t != nil {
+				utc := t.UTC()
+				bound[i] = &utc
+			} else {
+				bound[i] = t
+			}
+		default:
+			bound[i] = arg
+		}
+	}
+
+	return bound
+}
+
+// start begins the span of a query, named after its first word: select, insert and so on
+func (db *tracedDB) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	name := "query"
+	if fields := strings.Fields(query); len(fields) > 0 {
+		name = strings.ToLower(fields[0])
+	}
+
+	return otel.Tracer(tracerName).Start(ctx, "db."+name, trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(db.system, semconv.DBQueryText(query)))
+}
+
+// endSpan ends span, recording err unless it only says there were no rows
+func endSpan(span trace.Span, err error) {
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
@@ -0,0 +1,119 @@
+package data
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+	"vue-api/internal/migrate"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func Test_tracedDB(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	defer otel.SetTracerProvider(previous)
+
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := createTables(migrate.NewSQLite(db)); err != nil {
+		t.Fatal("could not create tables: ", err)
+	}
+
+	models := New(db)
+	ctx := context.Background()
+
+	if _, err := models.Author.Insert(ctx, Author{AuthorName: "Ann Author"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := models.Book.GetOneById(ctx, 1000); err == nil {
+		t.Fatal("expected no book with id 1000")
+	}
+	if _, err := models.Book.GetAll(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) < 3 {
+		t.Fatalf("expected a span for each query, got %d", len(spans))
+	}
+
+	insert := spans[0]
+	if insert.Name != "db.insert" {
+		t.Errorf("expected the first span to be db.insert, got %s", insert.Name)
+	}
+
+	attrs := map[string]string{}
+	for _, kv := range insert.Attributes {
+		attrs[string(kv.Key)] = kv.Value.Emit()
+	}
+	if attrs["db.system"] != "sqlite" {
+		t.Errorf("expected db.system sqlite, got %q", attrs["db.system"])
+	}
+	if !strings.Contains(attrs["db.query.text"], "insert into authors") {
+		t.Errorf("expected the query text to be recorded, got %q", attrs["db.query.text"])
+	}
+
+	// a query finding nothing has not failed
+	for _, s := range spans {
+		if s.Status.Code == codes.Error {
+			t.Errorf("%s: expected no error, got %s", s.Name, s.Status.Description)
+		}
+	}
+
+	// but one which could not run has
+	exporter.Reset()
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := models.Book.GetAll(cancelled); err == nil {
+		t.Fatal("expected a cancelled query to fail")
+	}
+	if spans := exporter.GetSpans(); len(spans) == 0 || spans[0].Status.Code != codes.Error {
+		t.Error("expected the failed query's span to be marked as failed")
+	}
+}
+
+func Test_tracedDB_sqliteTimes(t *testing.T) {
+	// sqlite compares times as text, which goes wrong when they are written in a zone behind UTC
+	previous := time.Local
+	time.Local = time.FixedZone("-03", -3*60*60)
+	defer func() { time.Local = previous }()
+
+	db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "test.db")+"?_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if err := createTables(migrate.NewSQLite(db)); err != nil {
+		t.Fatal("could not create tables: ", err)
+	}
+
+	models := New(db)
+	ctx := context.Background()
+
+	if err := models.Audit.Insert(ctx, AuditEntry{ActorEmail: "cli", Action: "book.create", Entity: "book", EntityID: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	from := time.Now().UTC().Add(-time.Hour)
+	to := time.Now().UTC().Add(time.Hour)
+	entries, err := models.Audit.Filter(ctx, AuditFilter{From: &from, To: &to})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Errorf("expected the entry written in the last hour, got %d entries", len(entries))
+	}
+}
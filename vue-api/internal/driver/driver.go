@@ -8,6 +8,7 @@ import (
 	_ "github.com/jackc/pgconn"
 	_ "github.com/jackc/pgx/v4"
 	_ "github.com/jackc/pgx/v4/stdlib"
+	_ "modernc.org/sqlite"
 )
 
 type DB struct {
@@ -16,20 +17,26 @@ type DB struct {
 
 var dbConn = &DB{}
 
-const maxOpenDbConn = 6
-const maxIdleDbConn = 6
-const maxDbLifeTime = 6 * time.Minute
+// PoolOptions holds the settings for the database connection pool. A zero lifetime or idle
+// time means connections are kept for as long as the pool likes
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+}
 
-func ConnectPostgres(dsn string) (*DB, error) {
+func ConnectPostgres(dsn string, opts PoolOptions) (*DB, error) {
 
 	d, err := sql.Open("pgx", dsn)
 	if err != nil {
 		return nil, err
 	}
 
-	d.SetMaxOpenConns(maxOpenDbConn)
-	d.SetMaxIdleConns(maxIdleDbConn)
-	d.SetConnMaxLifetime(maxDbLifeTime)
+	d.SetMaxOpenConns(opts.MaxOpenConns)
+	d.SetMaxIdleConns(opts.MaxIdleConns)
+	d.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	d.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 
 	err = testDB(d)
 	if err != nil {
@@ -41,14 +48,37 @@ func ConnectPostgres(dsn string) (*DB, error) {
 	return dbConn, nil
 }
 
-func testDB(d *sql.DB) error {
+// ConnectSQLite opens the sqlite database in the file at path, creating it if need be. Writes
+// go to a write-ahead log, so that readers do not block the writer, and a writer waits for
+// another rather than failing at once. Times are written in the format of sqlite's own date
+// functions, which sorts as text, rather than as Go prints them
+func ConnectSQLite(path string, opts PoolOptions) (*DB, error) {
+	d, err := sql.Open("sqlite", "file:"+path+"?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)&_time_format=sqlite")
+	if err != nil {
+		return nil, err
+	}
+
+	d.SetMaxOpenConns(opts.MaxOpenConns)
+	d.SetMaxIdleConns(opts.MaxIdleConns)
+	d.SetConnMaxLifetime(opts.ConnMaxLifetime)
+	d.SetConnMaxIdleTime(opts.ConnMaxIdleTime)
 
-	err := d.Ping()
+	err = testDB(d)
 	if err != nil {
-		fmt.Println("Error!", err)
-		return err
+		return nil, err
+	}
+
+	dbConn.SQL = d
+
+	return dbConn, nil
+}
+
+// testDB makes sure the database can be reached, so that a bad dsn is reported at start up
+// rather than on the first request
+func testDB(d *sql.DB) error {
+	if err := d.Ping(); err != nil {
+		return fmt.Errorf("pinging database: %w", err)
 	}
-	fmt.Println("*** Pinged database successfuly! ***")
 
 	return nil
 }
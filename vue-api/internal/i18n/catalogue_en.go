@@ -0,0 +1,57 @@
+package i18n
+
+var english = map[string]string{
+	// validation
+	"validation.required":        "must be provided",
+	"validation.min_length":      "must be at least %d characters long",
+	"validation.max_length":      "must not be more than %d characters long",
+	"validation.min_items":       "must contain at least %d items",
+	"validation.max_items":       "must not contain more than %d items",
+	"validation.max_bytes":       "must not take more than %d bytes",
+	"validation.min":             "must be at least %d",
+	"validation.max":             "must not be more than %d",
+	"validation.email":           "must be a valid email address",
+	"validation.oneof":           "must be one of %s",
+	"validation.future_year":     "must not be in the future",
+	"validation.base64_image":    "must be a base64 encoded image",
+	"validation.not_found":       "does not exist",
+	"validation.genre_not_found": "contains a genre which does not exist",
+	"validation.email_in_use":    "is already in use",
+	"validation.revision_id":     "must be a revision id",
+	"validation.number":          "must be a number",
+	"validation.positive_number": "must be a positive number",
+	"validation.between":         "must be between %d and %d",
+	"validation.timestamp":       "must be an RFC 3339 timestamp",
+
+	// problems
+	"problem.bad_request":            "bad request",
+	"problem.invalid_json":           "invalid json supplied, or json missing entirely",
+	"problem.validation_failed":      "the supplied data is invalid",
+	"problem.invalid_precondition":   "invalid If-Match header",
+	"problem.unauthorized":           "invalid authentication credentials",
+	"problem.invalid_credentials":    "invalid username/password",
+	"problem.user_inactive":          "user is not active",
+	"problem.forbidden":              "forbidden",
+	"problem.not_found":              "record not found",
+	"problem.not_in_trash":           "record not found in trash",
+	"problem.revision_mismatch":      "revision does not belong to this book",
+	"problem.edit_conflict":          "record has been changed by someone else",
+	"problem.in_use":                 "record is still in use",
+	"problem.duplicate_value":        "duplicate value violates unique constraint",
+	"problem.value_too_long":         "the value you are trying to insert is too large",
+	"problem.foreign_key_violation":  "foreign key violation",
+	"problem.unsupported_media_type": "PATCH requests must be sent as application/merge-patch+json",
+	"problem.internal_error":         "the server encountered a problem and could not process your request",
+
+	// success messages
+	"message.success":         "success",
+	"message.logged_in":       "logged in",
+	"message.logged_out":      "logged out",
+	"message.changes_saved":   "Changes saved",
+	"message.user_trashed":    "User moved to trash",
+	"message.user_restored":   "User restored",
+	"message.user_logged_out": "user logged out and set to inactive",
+	"message.book_trashed":    "Book moved to trash",
+	"message.book_restored":   "Book restored",
+	"message.book_reverted":   "Book reverted",
+}
@@ -0,0 +1,57 @@
+package i18n
+
+var spanish = map[string]string{
+	// validation
+	"validation.required":        "es obligatorio",
+	"validation.min_length":      "debe tener al menos %d caracteres",
+	"validation.max_length":      "no puede tener más de %d caracteres",
+	"validation.min_items":       "debe contener al menos %d elementos",
+	"validation.max_items":       "no puede contener más de %d elementos",
+	"validation.max_bytes":       "no puede ocupar más de %d bytes",
+	"validation.min":             "debe ser como mínimo %d",
+	"validation.max":             "no puede ser mayor que %d",
+	"validation.email":           "debe ser una dirección de correo válida",
+	"validation.oneof":           "debe ser uno de %s",
+	"validation.future_year":     "no puede estar en el futuro",
+	"validation.base64_image":    "debe ser una imagen codificada en base64",
+	"validation.not_found":       "no existe",
+	"validation.genre_not_found": "contiene un género que no existe",
+	"validation.email_in_use":    "ya está en uso",
+	"validation.revision_id":     "debe ser el id de una revisión",
+	"validation.number":          "debe ser un número",
+	"validation.positive_number": "debe ser un número positivo",
+	"validation.between":         "debe estar entre %d y %d",
+	"validation.timestamp":       "debe ser una fecha RFC 3339",
+
+	// problems
+	"problem.bad_request":            "petición incorrecta",
+	"problem.invalid_json":           "el json enviado no es válido, o falta por completo",
+	"problem.validation_failed":      "los datos enviados no son válidos",
+	"problem.invalid_precondition":   "la cabecera If-Match no es válida",
+	"problem.unauthorized":           "las credenciales de autenticación no son válidas",
+	"problem.invalid_credentials":    "usuario o contraseña incorrectos",
+	"problem.user_inactive":          "el usuario no está activo",
+	"problem.forbidden":              "prohibido",
+	"problem.not_found":              "registro no encontrado",
+	"problem.not_in_trash":           "el registro no está en la papelera",
+	"problem.revision_mismatch":      "la revisión no pertenece a este libro",
+	"problem.edit_conflict":          "otra persona ha modificado el registro",
+	"problem.in_use":                 "el registro todavía está en uso",
+	"problem.duplicate_value":        "el valor duplicado incumple una restricción de unicidad",
+	"problem.value_too_long":         "el valor que intenta guardar es demasiado largo",
+	"problem.foreign_key_violation":  "incumplimiento de clave foránea",
+	"problem.unsupported_media_type": "las peticiones PATCH deben enviarse como application/merge-patch+json",
+	"problem.internal_error":         "el servidor ha tenido un problema y no ha podido procesar la petición",
+
+	// success messages
+	"message.success":         "correcto",
+	"message.logged_in":       "sesión iniciada",
+	"message.logged_out":      "sesión cerrada",
+	"message.changes_saved":   "Cambios guardados",
+	"message.user_trashed":    "Usuario movido a la papelera",
+	"message.user_restored":   "Usuario restaurado",
+	"message.user_logged_out": "sesión del usuario cerrada y usuario desactivado",
+	"message.book_trashed":    "Libro movido a la papelera",
+	"message.book_restored":   "Libro restaurado",
+	"message.book_reverted":   "Libro revertido",
+}
@@ -0,0 +1,140 @@
+package i18n
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// The languages messages can be translated into
+const (
+	English = "en"
+	Spanish = "es"
+)
+
+// Fallback is the language used when a client asks for none of the supported languages
+var Fallback = English
+
+// catalogues maps each supported language to its messages, keyed by message key
+var catalogues = map[string]map[string]string{
+	English: english,
+	Spanish: spanish,
+}
+
+// Languages returns the supported languages, sorted
+func Languages() []string {
+	var languages []string
+	for lang := range catalogues {
+		languages = append(languages, lang)
+	}
+	sort.Strings(languages)
+	return languages
+}
+
+// Supported reports whether messages can be translated into lang
+func Supported(lang string) bool {
+	_, ok := catalogues[lang]
+	return ok
+}
+
+// Printer translates messages into one language
+type Printer struct {
+	lang string
+}
+
+// NewPrinter returns a Printer for the given language, or for the fallback language if lang
+// is not supported
+func NewPrinter(lang string) Printer {
+	return Printer{lang: lang}
+}
+
+// Language returns the language the printer translates into
+func (p Printer) Language() string {
+	if Supported(p.lang) {
+		return p.lang
+	}
+	return Fallback
+}
+
+// Sprintf looks up the message with the given key, and formats it with args. Messages missing
+// from a catalogue are taken from the fallback language, and failing that from English; an
+// unknown key is returned as it is
+func (p Printer) Sprintf(key string, args ...interface{}) string {
+	message, ok := catalogues[p.Language()][key]
+	if !ok {
+		message, ok = catalogues[Fallback][key]
+	}
+	if !ok {
+		message, ok = english[key]
+	}
+	if !ok {
+		message = key
+	}
+
+	if len(args) > 0 {
+		return fmt.Sprintf(message, args...)
+	}
+	return message
+}
+
+// Match picks the language to use for a request: the user's preferred language if it is
+// supported, otherwise the first supported language in the Accept-Language header, otherwise
+// the fallback language
+func Match(preferred, acceptLanguage string) string {
+	if Supported(preferred) {
+		return preferred
+	}
+
+	for _, tag := range ParseAcceptLanguage(acceptLanguage) {
+		// only the primary subtag matters; es-MX and es-ES both get Spanish
+		lang, _, _ := strings.Cut(tag, "-")
+		if Supported(lang) {
+			return lang
+		}
+	}
+
+	return Fallback
+}
+
+// ParseAcceptLanguage returns the language tags in an Accept-Language header, lower cased and
+// ordered by their quality value. Tags with a quality of 0, and the * wildcard, are left out
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		if q > 0 {
+			tags = append(tags, weighted{tag: tag, q: q})
+		}
+	}
+
+	// a stable sort keeps the order of tags with the same quality
+	sort.SliceStable(tags, func(i, j int) bool {
+		return tags[i].q > tags[j].q
+	})
+
+	result := make([]string, len(tags))
+	for i, t := range tags {
+		result[i] = t.tag
+	}
+	return result
+}
@@ -0,0 +1,86 @@
+package i18n
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCatalogues_Complete(t *testing.T) {
+	for lang, catalogue := range catalogues {
+		for key, message := range english {
+			translated, ok := catalogue[key]
+			if !ok {
+				t.Errorf("%s: missing message %s", lang, key)
+				continue
+			}
+
+			if strings.Count(translated, "%") != strings.Count(message, "%") {
+				t.Errorf("%s: message %s does not take the same arguments as in English", lang, key)
+			}
+		}
+
+		for key := range catalogue {
+			if _, ok := english[key]; !ok {
+				t.Errorf("%s: message %s is not in the English catalogue", lang, key)
+			}
+		}
+	}
+}
+
+func TestPrinter_Sprintf(t *testing.T) {
+	var tests = []struct {
+		name     string
+		lang     string
+		key      string
+		args     []interface{}
+		expected string
+	}{
+		{"english", English, "validation.required", nil, "must be provided"},
+		{"spanish", Spanish, "validation.required", nil, "es obligatorio"},
+		{"with arguments", Spanish, "validation.between", []interface{}{1, 100}, "debe estar entre 1 y 100"},
+		{"unsupported language", "fr", "message.book_restored", nil, "Book restored"},
+		{"unknown key", Spanish, "no.such.key", nil, "no.such.key"},
+	}
+
+	for _, e := range tests {
+		got := NewPrinter(e.lang).Sprintf(e.key, e.args...)
+		if got != e.expected {
+			t.Errorf("%s: expected %q but got %q", e.name, e.expected, got)
+		}
+	}
+}
+
+func TestParseAcceptLanguage(t *testing.T) {
+	got := ParseAcceptLanguage("fr-CH, fr;q=0.9, en;q=0.8, de;q=0.7, *;q=0.5, it;q=0")
+	expected := []string{"fr-ch", "fr", "en", "de"}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected %v but got %v", expected, got)
+	}
+
+	if len(ParseAcceptLanguage("")) != 0 {
+		t.Error("expected no languages for an empty header")
+	}
+}
+
+func TestMatch(t *testing.T) {
+	var tests = []struct {
+		name           string
+		preferred      string
+		acceptLanguage string
+		expected       string
+	}{
+		{"preference wins", Spanish, "en-US,en;q=0.9", Spanish},
+		{"unsupported preference", "de", "es-MX,es;q=0.9", Spanish},
+		{"quality order", "", "fr;q=0.9, en;q=0.5, es;q=0.8", Spanish},
+		{"nothing supported", "", "fr, de", Fallback},
+		{"no header", "", "", Fallback},
+	}
+
+	for _, e := range tests {
+		if got := Match(e.preferred, e.acceptLanguage); got != e.expected {
+			t.Errorf("%s: expected %s but got %s", e.name, e.expected, got)
+		}
+	}
+}
@@ -0,0 +1,426 @@
+// Package migrate keeps the database schema up to date. Migrations are plain SQL files, embedded
+// in the binary, named <version>_<name>.up.sql and <version>_<name>.down.sql; the versions
+// applied to a database are recorded in its schema_migrations table. PostgreSQL and SQLite each
+// have their own set of migrations, which must keep the same versions and names so that the data
+// layer sees the same schema on either.
+//
+// Migrations are applied in a transaction each, so a failed migration leaves the database at
+// the previous version. A migration must never be changed once it has been released: add a new
+// one instead
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgconn"
+)
+
+//go:embed postgres/*.sql sqlite/*.sql
+var files embed.FS
+
+// lockID identifies the advisory lock which stops two instances migrating at the same time
+const lockID = 7318829651
+
+// pgUndefinedTable is the SQLSTATE reported for a table which does not exist
+const pgUndefinedTable = "42P01"
+
+// ErrDirty is returned when the versions applied to a database are not a prefix of the known
+// migrations, for instance after a binary was rolled back past a migration it does not know
+var ErrDirty = errors.New("migrate: the database has versions applied which are not known")
+
+// Migration is one step in the history of the schema
+type Migration struct {
+	Version int
+	Name    string
+	Up      string
+	Down    string
+}
+
+// Status is a migration, and when it was applied to the database, if it has been
+type Status struct {
+	Migration
+	AppliedAt *time.Time
+}
+
+// Migrator applies migrations to a database
+type Migrator struct {
+	db         *sql.DB
+	dialect    dialect
+	migrations []Migration
+}
+
+// dialect holds what differs between the databases a migrator can work on
+type dialect struct {
+	dir         string // of the embedded migrations
+	createTable string // creates schema_migrations
+
+	// lock and unlock take and release the migration lock, and are empty when the database
+	// serialises writers by itself
+	lock, unlock string
+
+	// undefinedTable reports whether err says a table does not exist
+	undefinedTable func(err error) bool
+}
+
+var pgDialect = dialect{
+	dir: "postgres",
+	createTable: `create table if not exists schema_migrations (
+		version integer primary key,
+		name character varying(255) not null,
+		applied_at timestamp without time zone not null
+	)`,
+	lock:   `select pg_advisory_lock($1)`,
+	unlock: `select pg_advisory_unlock($1)`,
+	undefinedTable: func(err error) bool {
+		var pgErr *pgconn.PgError
+		return errors.As(err, &pgErr) && pgErr.Code == pgUndefinedTable
+	},
+}
+
+var sqliteDialect = dialect{
+	dir: "sqlite",
+	createTable: `create table if not exists schema_migrations (
+		version integer primary key,
+		name text not null,
+		applied_at timestamp not null
+	)`,
+	undefinedTable: func(err error) bool {
+		// the sqlite driver reports this as a generic error, so only the message tells
+		return err != nil && strings.Contains(err.Error(), "no such table")
+	},
+}
+
+// New returns a migrator for a postgres database, using the migrations embedded in the binary
+func New(db *sql.DB) *Migrator {
+	return &Migrator{db: db, dialect: pgDialect, migrations: embedded(pgDialect)}
+}
+
+// NewSQLite returns a migrator for an sqlite database, using the migrations embedded in the binary
+func NewSQLite(db *sql.DB) *Migrator {
+	return &Migrator{db: db, dialect: sqliteDialect, migrations: embedded(sqliteDialect)}
+}
+
+// WithMigrations returns a migrator for a postgres database, using the given migrations, which
+// must be sorted by version
+func WithMigrations(db *sql.DB, migrations []Migration) *Migrator {
+	return &Migrator{db: db, dialect: pgDialect, migrations: migrations}
+}
+
+// embedded returns the migrations embedded in the binary for d
+func embedded(d dialect) []Migration {
+	sub, err := fs.Sub(files, d.dir)
+	if err != nil {
+		panic(err)
+	}
+
+	migrations, err := Load(sub)
+	if err != nil {
+		// the files are embedded at build time, and covered by the tests
+		panic(err)
+	}
+
+	return migrations
+}
+
+var fileRX = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+
+// Load reads the migrations in the top directory of fsys. Versions must start at 1 and follow on
+// from each other, and every migration needs both an up and a down file
+func Load(fsys fs.FS) ([]Migration, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := make(map[int]*Migration)
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		match := fileRX.FindStringSubmatch(entry.Name())
+		if match == nil {
+			return nil, fmt.Errorf("migrate: %s is not named <version>_<name>.up.sql or .down.sql", entry.Name())
+		}
+
+		version, _ := strconv.Atoi(match[1])
+		contents, err := fs.ReadFile(fsys, path.Clean(entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[version]
+		if !ok {
+			m = &Migration{Version: version, Name: match[2]}
+			byVersion[version] = m
+		}
+		if m.Name != match[2] {
+			return nil, fmt.Errorf("migrate: version %d is used by both %s and %s", version, m.Name, match[2])
+		}
+
+		if match[3] == "up" {
+			m.Up = string(contents)
+		} else {
+			m.Down = string(contents)
+		}
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	for i, m := range migrations {
+		if m.Version != i+1 {
+			return nil, fmt.Errorf("migrate: expected version %d but found %d_%s", i+1, m.Version, m.Name)
+		}
+		if m.Up == "" || m.Down == "" {
+			return nil, fmt.Errorf("migrate: %d_%s needs both an up and a down file", m.Version, m.Name)
+		}
+	}
+
+	return migrations, nil
+}
+
+// Migrations returns the known migrations, oldest first
+func (m *Migrator) Migrations() []Migration {
+	return m.migrations
+}
+
+// Latest returns the version the schema is at once every migration has been applied
+func (m *Migrator) Latest() int {
+	return len(m.migrations)
+}
+
+// Version returns the version the database is at; 0 for a database which has never been
+// migrated
+func (m *Migrator) Version(ctx context.Context) (int, error) {
+	var version int
+	err := m.db.QueryRowContext(ctx, `select coalesce(max(version), 0) from schema_migrations`).Scan(&version)
+	if m.dialect.undefinedTable(err) {
+		return 0, nil
+	}
+
+	return version, err
+}
+
+// Status returns every known migration, with the time it was applied for those which have been
+func (m *Migrator) Status(ctx context.Context) ([]Status, error) {
+	applied, err := m.applied(ctx, m.db)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		status := Status{Migration: migration}
+		if at, ok := applied[migration.Version]; ok {
+			status.AppliedAt = &at
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+// Up applies every migration which has not been applied yet, and returns them
+func (m *Migrator) Up(ctx context.Context) ([]Migration, error) {
+	return m.To(ctx, m.Latest())
+}
+
+// Down reverts the last steps migrations which have been applied, and returns them
+func (m *Migrator) Down(ctx context.Context, steps int) ([]Migration, error) {
+	version, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	target := version - steps
+	if target < 0 {
+		target = 0
+	}
+
+	return m.To(ctx, target)
+}
+
+// To migrates the database up or down to version, and returns the migrations which were run
+func (m *Migrator) To(ctx context.Context, version int) ([]Migration, error) {
+	if version < 0 || version > m.Latest() {
+		return nil, fmt.Errorf("migrate: there is no version %d; the latest is %d", version, m.Latest())
+	}
+
+	var run []Migration
+
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		current, err := m.current(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for current < version {
+			migration := m.migrations[current]
+			if err := m.apply(ctx, conn, migration.Up, `insert into schema_migrations (version, name, applied_at) values ($1, $2, $3)`,
+				migration.Version, migration.Name, time.Now().UTC()); err != nil {
+				return fmt.Errorf("migrate: %d_%s up: %w", migration.Version, migration.Name, err)
+			}
+			run = append(run, migration)
+			current++
+		}
+
+		for current > version {
+			migration := m.migrations[current-1]
+			if err := m.apply(ctx, conn, migration.Down, `delete from schema_migrations where version = $1`,
+				migration.Version); err != nil {
+				return fmt.Errorf("migrate: %d_%s down: %w", migration.Version, migration.Name, err)
+			}
+			run = append(run, migration)
+			current--
+		}
+
+		return nil
+	})
+
+	return run, err
+}
+
+// Force records the database as being at version without running any migration. It is meant
+// for databases whose schema was set up by hand, and for recovering from a failed migration
+// which had to be finished by hand
+func (m *Migrator) Force(ctx context.Context, version int) error {
+	if version < 0 || version > m.Latest() {
+		return fmt.Errorf("migrate: there is no version %d; the latest is %d", version, m.Latest())
+	}
+
+	return m.locked(ctx, func(conn *sql.Conn) error {
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.ExecContext(ctx, `delete from schema_migrations`); err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		for _, migration := range m.migrations[:version] {
+			if _, err := tx.ExecContext(ctx, `insert into schema_migrations (version, name, applied_at) values ($1, $2, $3)`,
+				migration.Version, migration.Name, now); err != nil {
+				return err
+			}
+		}
+
+		return tx.Commit()
+	})
+}
+
+// locked runs fn on a single connection, holding the migration lock, once the schema_migrations
+// table has been created
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if m.dialect.lock != "" {
+		if _, err := conn.ExecContext(ctx, m.dialect.lock, lockID); err != nil {
+			return err
+		}
+		defer func() {
+			// the lock goes with the session, so it must be released before the connection is
+			// handed back to the pool; a fresh context is used in case ctx has been cancelled
+			_, _ = conn.ExecContext(context.Background(), m.dialect.unlock, lockID)
+		}()
+	}
+
+	_, err = conn.ExecContext(ctx, m.dialect.createTable)
+	if err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// current returns the version conn's database is at, checking that the versions applied are
+// exactly the first ones known
+func (m *Migrator) current(ctx context.Context, q querier) (int, error) {
+	applied, err := m.applied(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+
+	for version := range applied {
+		if version < 1 || version > m.Latest() {
+			return 0, ErrDirty
+		}
+	}
+	for version := 1; version <= len(applied); version++ {
+		if _, ok := applied[version]; !ok {
+			return 0, ErrDirty
+		}
+	}
+
+	return len(applied), nil
+}
+
+// apply runs the statements of a migration and records the change, in one transaction
+func (m *Migrator) apply(ctx context.Context, conn *sql.Conn, statements, record string, args ...interface{}) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, statements); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// querier is what applied needs from a *sql.DB or *sql.Conn
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// applied returns the versions recorded in schema_migrations, with the time they were applied
+func (m *Migrator) applied(ctx context.Context, q querier) (map[int]time.Time, error) {
+	applied := make(map[int]time.Time)
+
+	rows, err := q.QueryContext(ctx, `select version, applied_at from schema_migrations`)
+	if m.dialect.undefinedTable(err) {
+		return applied, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var version int
+		var at time.Time
+		if err := rows.Scan(&version, &at); err != nil {
+			return nil, err
+		}
+		applied[version] = at
+	}
+
+	return applied, rows.Err()
+}
@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	_ "modernc.org/sqlite"
+)
+
+func file(contents string) *fstest.MapFile {
+	return &fstest.MapFile{Data: []byte(contents)}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, err := Load(fstest.MapFS{
+		"0002_add_column.down.sql":    file("alter table things drop column colour;"),
+		"0002_add_column.up.sql":      file("alter table things add column colour text;"),
+		"0001_create_things.up.sql":   file("create table things (id integer);"),
+		"0001_create_things.down.sql": file("drop table things;"),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(migrations) != 2 || migrations[0].Name != "create_things" || migrations[1].Version != 2 {
+		t.Fatalf("expected the migrations in order, got %+v", migrations)
+	}
+
+	if !strings.HasPrefix(migrations[1].Down, "alter table things drop") {
+		t.Errorf("expected the down file to be read, got %q", migrations[1].Down)
+	}
+}
+
+func TestLoad_errors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		files    fstest.MapFS
+		expected string
+	}{
+		{"bad name", fstest.MapFS{"create_things.sql": file("")}, "is not named"},
+		{"missing down", fstest.MapFS{"0001_things.up.sql": file("create table things (id integer);")}, "needs both"},
+		{"gap", fstest.MapFS{
+			"0001_a.up.sql": file("x"), "0001_a.down.sql": file("x"),
+			"0003_c.up.sql": file("x"), "0003_c.down.sql": file("x"),
+		}, "expected version 2"},
+		{"clashing names", fstest.MapFS{"0001_a.up.sql": file("x"), "0001_b.down.sql": file("x")}, "used by both"},
+	}
+
+	for _, e := range tests {
+		_, err := Load(e.files)
+		if err == nil || !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected an error containing %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+func TestNew_embedded(t *testing.T) {
+	m := New(nil)
+
+	if m.Latest() == 0 {
+		t.Fatal("expected the embedded migrations to be loaded")
+	}
+
+	if m.Migrations()[0].Name != "initial_schema" {
+		t.Errorf("expected the first migration to create the schema, got %s", m.Migrations()[0].Name)
+	}
+}
+
+func TestNewSQLite_embedded(t *testing.T) {
+	pg, lite := New(nil).Migrations(), NewSQLite(nil).Migrations()
+
+	if len(pg) != len(lite) {
+		t.Fatalf("expected as many sqlite migrations as postgres ones, got %d and %d", len(lite), len(pg))
+	}
+
+	for i := range pg {
+		if pg[i].Name != lite[i].Name {
+			t.Errorf("expected version %d to be %s on both databases, got %s on sqlite", pg[i].Version, pg[i].Name, lite[i].Name)
+		}
+	}
+}
+
+func TestMigrator_sqlite(t *testing.T) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	ctx := context.Background()
+	m := NewSQLite(db)
+
+	if version, err := m.Version(ctx); err != nil || version != 0 {
+		t.Fatalf("expected a new database to be at version 0, got %d and %v", version, err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := m.Down(ctx, m.Latest()); err != nil {
+		t.Fatal("failed to revert every migration: ", err)
+	}
+
+	if _, err := m.Up(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := m.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, status := range statuses {
+		if status.AppliedAt == nil {
+			t.Errorf("expected %d_%s to be applied", status.Version, status.Name)
+		}
+	}
+}
+
+func TestMigrator_To(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := WithMigrations(db, []Migration{
+		{Version: 1, Name: "a", Up: "create table a", Down: "drop table a"},
+		{Version: 2, Name: "b", Up: "create table b", Down: "drop table b"},
+		{Version: 3, Name: "c", Up: "create table c", Down: "drop table c"},
+	})
+
+	// version 1 is applied already
+	mock.ExpectExec("select pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version, applied_at from schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()))
+	for _, table := range []string{"b", "c"} {
+		mock.ExpectBegin()
+		mock.ExpectExec("create table " + table).WillReturnResult(sqlmock.NewResult(0, 0))
+		mock.ExpectExec("insert into schema_migrations").WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+	}
+	mock.ExpectExec("select pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	run, err := m.Up(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(run) != 2 || run[0].Name != "b" || run[1].Name != "c" {
+		t.Errorf("expected b and c to be applied, got %+v", run)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMigrator_To_dirty(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	m := WithMigrations(db, []Migration{{Version: 1, Name: "a", Up: "create table a", Down: "drop table a"}})
+
+	// version 2 comes from a newer binary
+	mock.ExpectExec("select pg_advisory_lock").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("create table if not exists schema_migrations").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery("select version, applied_at from schema_migrations").
+		WillReturnRows(sqlmock.NewRows([]string{"version", "applied_at"}).AddRow(1, time.Now()).AddRow(2, time.Now()))
+	mock.ExpectExec("select pg_advisory_unlock").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	if _, err := m.Up(context.Background()); err != ErrDirty {
+		t.Errorf("expected ErrDirty but got %v", err)
+	}
+
+	if _, err := m.To(context.Background(), 5); err == nil {
+		t.Error("expected an error for an unknown version")
+	}
+}
@@ -0,0 +1,54 @@
+CREATE TABLE authors (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    author_name character varying(512),
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+);
+
+CREATE TABLE genres (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    genre_name character varying(255),
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+);
+
+CREATE TABLE books (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    title character varying(512),
+    author_id integer,
+    publication_year integer,
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone,
+    slug character varying(512),
+    description text
+);
+
+CREATE TABLE books_genres (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    book_id integer,
+    genre_id integer,
+    created_at timestamp without time zone,
+    updated_at timestamp without time zone
+);
+
+CREATE TABLE users (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    email character varying(255),
+    first_name character varying(255) NOT NULL,
+    last_name character varying(255) NOT NULL,
+    password character varying(60) NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL,
+    user_active integer DEFAULT 0
+);
+
+CREATE TABLE tokens (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    user_id integer,
+    email character varying(255) NOT NULL,
+    token character varying(255) NOT NULL,
+    token_hash bytea NOT NULL,
+    expiry timestamp with time zone NOT NULL,
+    created_at timestamp without time zone NOT NULL,
+    updated_at timestamp without time zone NOT NULL
+);
@@ -0,0 +1,11 @@
+CREATE TABLE book_revisions (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    book_id integer NOT NULL,
+    user_id integer,
+    title character varying(512),
+    author_id integer,
+    publication_year integer,
+    description text,
+    genre_ids text NOT NULL,
+    created_at timestamp without time zone NOT NULL
+);
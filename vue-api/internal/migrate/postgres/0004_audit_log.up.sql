@@ -0,0 +1,17 @@
+CREATE TABLE audit_log (
+    id integer GENERATED ALWAYS AS IDENTITY PRIMARY KEY,
+    actor_id integer,
+    actor_email character varying(255) NOT NULL,
+    action character varying(64) NOT NULL,
+    entity character varying(64) NOT NULL,
+    entity_id integer NOT NULL,
+    before jsonb,
+    after jsonb,
+    ip character varying(64) NOT NULL,
+    request_id character varying(255) NOT NULL,
+    created_at timestamp without time zone NOT NULL
+);
+
+-- the audit log is append only
+CREATE RULE audit_log_no_update AS ON UPDATE TO audit_log DO INSTEAD NOTHING;
+CREATE RULE audit_log_no_delete AS ON DELETE TO audit_log DO INSTEAD NOTHING;
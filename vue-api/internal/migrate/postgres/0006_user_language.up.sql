@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN language character varying(5) DEFAULT '' NOT NULL;
@@ -0,0 +1,6 @@
+DROP TABLE tokens;
+DROP TABLE users;
+DROP TABLE books_genres;
+DROP TABLE books;
+DROP TABLE genres;
+DROP TABLE authors;
@@ -0,0 +1,54 @@
+CREATE TABLE authors (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    author_name text,
+    created_at timestamp,
+    updated_at timestamp
+);
+
+CREATE TABLE genres (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    genre_name text,
+    created_at timestamp,
+    updated_at timestamp
+);
+
+CREATE TABLE books (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    title text,
+    author_id integer,
+    publication_year integer,
+    created_at timestamp,
+    updated_at timestamp,
+    slug text,
+    description text
+);
+
+CREATE TABLE books_genres (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    book_id integer,
+    genre_id integer,
+    created_at timestamp,
+    updated_at timestamp
+);
+
+CREATE TABLE users (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    email text,
+    first_name text NOT NULL,
+    last_name text NOT NULL,
+    password text NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL,
+    user_active integer DEFAULT 0
+);
+
+CREATE TABLE tokens (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    user_id integer,
+    email text NOT NULL,
+    token text NOT NULL,
+    token_hash blob NOT NULL,
+    expiry timestamp NOT NULL,
+    created_at timestamp NOT NULL,
+    updated_at timestamp NOT NULL
+);
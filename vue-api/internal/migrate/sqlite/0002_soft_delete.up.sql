@@ -0,0 +1,2 @@
+ALTER TABLE books ADD COLUMN deleted_at timestamp;
+ALTER TABLE users ADD COLUMN deleted_at timestamp;
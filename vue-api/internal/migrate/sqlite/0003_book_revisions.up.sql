@@ -0,0 +1,11 @@
+CREATE TABLE book_revisions (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    book_id integer NOT NULL,
+    user_id integer,
+    title text,
+    author_id integer,
+    publication_year integer,
+    description text,
+    genre_ids text NOT NULL,
+    created_at timestamp NOT NULL
+);
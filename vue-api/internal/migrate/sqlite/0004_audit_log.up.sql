@@ -0,0 +1,17 @@
+CREATE TABLE audit_log (
+    id integer PRIMARY KEY AUTOINCREMENT,
+    actor_id integer,
+    actor_email text NOT NULL,
+    action text NOT NULL,
+    entity text NOT NULL,
+    entity_id integer NOT NULL,
+    before text,
+    after text,
+    ip text NOT NULL,
+    request_id text NOT NULL,
+    created_at timestamp NOT NULL
+);
+
+-- the audit log is append only
+CREATE TRIGGER audit_log_no_update BEFORE UPDATE ON audit_log BEGIN SELECT RAISE(IGNORE); END;
+CREATE TRIGGER audit_log_no_delete BEFORE DELETE ON audit_log BEGIN SELECT RAISE(IGNORE); END;
@@ -0,0 +1,2 @@
+ALTER TABLE books ADD COLUMN version integer DEFAULT 1 NOT NULL;
+ALTER TABLE users ADD COLUMN version integer DEFAULT 1 NOT NULL;
@@ -0,0 +1 @@
+ALTER TABLE users ADD COLUMN language text DEFAULT '' NOT NULL;
@@ -0,0 +1,259 @@
+// Package seed makes up sample data: a catalogue of authors, genres and books with covers, and
+// the users who look after it. The data is generated from a seed, so the same options always
+// give the same data, which makes it usable for demos, load tests and frontend development
+package seed
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"image/jpeg"
+	"math/rand"
+	"strings"
+)
+
+// The size of the generated covers, which matches the covers shipped in static/covers
+const (
+	CoverWidth  = 256
+	CoverHeight = 384
+)
+
+// Options says how much data to make up
+type Options struct {
+	Seed    int64
+	Authors int
+	Genres  int
+	Books   int
+	Users   int
+}
+
+// DefaultOptions returns the options used when none are given: enough data to fill a few pages
+func DefaultOptions() Options {
+	return Options{Seed: 1, Authors: 12, Genres: 7, Books: 60, Users: 6}
+}
+
+// Data is everything made up by Generate
+type Data struct {
+	Authors []string
+	Genres  []string
+	Books   []Book
+	Users   []User
+}
+
+// Book is a made up book. Its author and genres are referred to by name
+type Book struct {
+	Title           string
+	Author          string
+	PublicationYear int
+	Description     string
+	Genres          []string
+	Cover           []byte
+}
+
+// User is a made up user. The first user generated is always the administrator, AdminEmail;
+// the others are a mix of active and inactive users, with either language
+type User struct {
+	Email     string
+	FirstName string
+	LastName  string
+	Active    bool
+	Language  string
+}
+
+// AdminEmail is the email address of the first user generated
+const AdminEmail = "admin@example.com"
+
+// Limits, set by the number of names which can be made up from the word lists
+var (
+	MaxAuthors = len(firstNames) * len(lastNames)
+	MaxGenres  = len(genreNames)
+)
+
+// Generate makes up the data asked for by opts
+func Generate(opts Options) (*Data, error) {
+	if err := opts.check(); err != nil {
+		return nil, err
+	}
+
+	rng := rand.New(rand.NewSource(opts.Seed))
+	d := &Data{}
+
+	// authors are picked without replacement from every combination of first and last names
+	for _, n := range rng.Perm(MaxAuthors)[:opts.Authors] {
+		d.Authors = append(d.Authors, firstNames[n/len(lastNames)]+" "+lastNames[n%len(lastNames)])
+	}
+
+	// the genres are taken in order, so that a small catalogue has the familiar ones
+	d.Genres = append(d.Genres, genreNames[:opts.Genres]...)
+
+	titles := make(map[string]bool)
+	for i := 0; i < opts.Books; i++ {
+		title := bookTitle(rng, titles)
+		titles[strings.ToLower(title)] = true
+
+		book := Book{
+			Title:           title,
+			Author:          d.Authors[rng.Intn(len(d.Authors))],
+			PublicationYear: 1850 + rng.Intn(175),
+			Description:     description(rng, title),
+		}
+
+		if len(d.Genres) > 0 {
+			for _, n := range rng.Perm(len(d.Genres))[:1+rng.Intn(min(3, len(d.Genres)))] {
+				book.Genres = append(book.Genres, d.Genres[n])
+			}
+		}
+
+		cover, err := Cover(rng)
+		if err != nil {
+			return nil, err
+		}
+		book.Cover = cover
+
+		d.Books = append(d.Books, book)
+	}
+
+	for i := 0; i < opts.Users; i++ {
+		d.Users = append(d.Users, user(rng, i))
+	}
+
+	return d, nil
+}
+
+// check makes sure the options ask for data which can be made up
+func (opts Options) check() error {
+	var problems []string
+
+	if opts.Authors < 0 || opts.Genres < 0 || opts.Books < 0 || opts.Users < 0 {
+		problems = append(problems, "counts must not be negative")
+	}
+	if opts.Authors > MaxAuthors {
+		problems = append(problems, fmt.Sprintf("at most %d authors can be made up", MaxAuthors))
+	}
+	if opts.Genres > MaxGenres {
+		problems = append(problems, fmt.Sprintf("at most %d genres can be made up", MaxGenres))
+	}
+	if opts.Books > 0 && opts.Authors == 0 {
+		problems = append(problems, "books need at least one author")
+	}
+
+	if len(problems) > 0 {
+		return errors.New("seed: " + strings.Join(problems, "; "))
+	}
+
+	return nil
+}
+
+// bookTitle makes up a title which is not in taken, comparing without case
+func bookTitle(rng *rand.Rand, taken map[string]bool) string {
+	for attempt := 0; ; attempt++ {
+		var title string
+		switch rng.Intn(3) {
+		case 0:
+			title = "The " + pick(rng, adjectives) + " " + pick(rng, nouns)
+		case 1:
+			title = "The " + pick(rng, nouns) + " of " + pick(rng, places)
+		default:
+			title = pick(rng, adjectives) + " " + pick(rng, nouns) + "s"
+		}
+
+		// once the word lists run short, sequels keep the titles unique
+		if attempt > 20 {
+			title = fmt.Sprintf("%s, Part %d", title, attempt-19)
+		}
+
+		if !taken[strings.ToLower(title)] {
+			return title
+		}
+	}
+}
+
+// description makes up the blurb of a book
+func description(rng *rand.Rand, title string) string {
+	return fmt.Sprintf("In %s, %s %s must %s before %s. %s",
+		title,
+		pick(rng, []string{"a young", "an old", "a reluctant", "a disgraced", "a curious"}),
+		pick(rng, []string{"detective", "sailor", "librarian", "engineer", "queen", "thief", "doctor"}),
+		pick(rng, []string{"find the truth", "cross the mountains", "break an ancient curse", "save the city", "solve a murder"}),
+		pick(rng, []string{"winter comes", "the war begins", "it is too late", "the last train leaves", "the tide turns"}),
+		pick(rng, []string{"A story of courage.", "Nothing is as it seems.", "A modern classic.", "Impossible to put down."}))
+}
+
+// user makes up the i-th user
+func user(rng *rand.Rand, i int) User {
+	first, last := pick(rng, firstNames), pick(rng, lastNames)
+
+	u := User{
+		Email:     fmt.Sprintf("%s.%s%d@example.com", strings.ToLower(first), strings.ToLower(last), i),
+		FirstName: first,
+		LastName:  last,
+		Active:    rng.Intn(4) != 0,
+		Language:  pick(rng, []string{"en", "es"}),
+	}
+
+	if i == 0 {
+		u.Email, u.FirstName, u.LastName, u.Active, u.Language = AdminEmail, "Admin", "User", true, "en"
+	}
+
+	return u
+}
+
+// Cover draws a cover: a background colour with a band across it, and a frame in a lighter
+// shade, encoded as a JPEG
+func Cover(rng *rand.Rand) ([]byte, error) {
+	background := color.RGBA{R: uint8(rng.Intn(200)), G: uint8(rng.Intn(200)), B: uint8(rng.Intn(200)), A: 255}
+	accent := color.RGBA{R: background.R + 55, G: background.G + 55, B: background.B + 55, A: 255}
+	bandTop := CoverHeight/4 + rng.Intn(CoverHeight/2)
+	bandHeight := 20 + rng.Intn(60)
+
+	img := image.NewRGBA(image.Rect(0, 0, CoverWidth, CoverHeight))
+	draw.Draw(img, img.Bounds(), &image.Uniform{C: accent}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(12, 12, CoverWidth-12, CoverHeight-12), &image.Uniform{C: background}, image.Point{}, draw.Src)
+	draw.Draw(img, image.Rect(12, bandTop, CoverWidth-12, bandTop+bandHeight), &image.Uniform{C: accent}, image.Point{}, draw.Src)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// pick returns one of words
+func pick(rng *rand.Rand, words []string) string {
+	return words[rng.Intn(len(words))]
+}
+
+var firstNames = []string{
+	"Ada", "Alan", "Beatriz", "Carlos", "Clara", "Daniel", "Elena", "Farid", "Grace", "Hugo",
+	"Ines", "James", "Julia", "Kenji", "Lucia", "Marco", "Nadia", "Oscar", "Paula", "Rosa",
+}
+
+var lastNames = []string{
+	"Abbott", "Bennett", "Castillo", "Dubois", "Evans", "Fischer", "Garcia", "Hughes", "Ibarra", "Jensen",
+	"Kowalski", "Lopez", "Moreau", "Novak", "Ortega", "Price", "Quinn", "Romero", "Silva", "Turner",
+}
+
+var genreNames = []string{
+	"Science Fiction", "Fantasy", "Romance", "Thriller", "Mystery", "Horror", "Classic",
+	"Historical Fiction", "Adventure", "Poetry", "Biography", "Drama", "Humour", "Young Adult",
+	"Crime", "Dystopian", "Short Stories", "Travel", "Philosophy", "Graphic Novel",
+}
+
+var adjectives = []string{
+	"Silent", "Crimson", "Forgotten", "Hidden", "Last", "Broken", "Golden", "Midnight", "Distant",
+	"Burning", "Frozen", "Secret", "Wandering", "Hollow", "Iron", "Glass",
+}
+
+var nouns = []string{
+	"Garden", "River", "Tower", "Letter", "Clock", "Harbour", "Mirror", "Crown", "Forest", "Lantern",
+	"Bridge", "Island", "Map", "Orchard", "Storm", "Key",
+}
+
+var places = []string{
+	"Avalon", "the North", "Seville", "the Moors", "Kings", "the Deep", "Lisbon", "the Valley",
+	"Ashes", "the Sea", "Winter", "Shadows",
+}
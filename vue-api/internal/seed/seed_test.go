@@ -0,0 +1,117 @@
+package seed
+
+import (
+	"bytes"
+	"image/jpeg"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestGenerate(t *testing.T) {
+	opts := Options{Seed: 42, Authors: 5, Genres: 4, Books: 30, Users: 4}
+
+	d, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Authors) != 5 || len(d.Genres) != 4 || len(d.Books) != 30 || len(d.Users) != 4 {
+		t.Fatalf("expected the counts asked for, got %d authors, %d genres, %d books and %d users",
+			len(d.Authors), len(d.Genres), len(d.Books), len(d.Users))
+	}
+
+	titles := make(map[string]bool)
+	for _, b := range d.Books {
+		if titles[strings.ToLower(b.Title)] {
+			t.Errorf("expected unique titles, got %q twice", b.Title)
+		}
+		titles[strings.ToLower(b.Title)] = true
+
+		if len(b.Genres) == 0 || len(b.Genres) > 3 {
+			t.Errorf("expected %q to have one to three genres, got %v", b.Title, b.Genres)
+		}
+	}
+
+	if d.Users[0].Email != AdminEmail || !d.Users[0].Active {
+		t.Errorf("expected the first user to be the administrator, got %+v", d.Users[0])
+	}
+}
+
+func TestGenerate_deterministic(t *testing.T) {
+	opts := Options{Seed: 7, Authors: 3, Genres: 3, Books: 5, Users: 3}
+
+	a, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(a, b) {
+		t.Error("expected the same seed to give the same data")
+	}
+
+	opts.Seed = 8
+	c, err := Generate(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reflect.DeepEqual(a.Books, c.Books) {
+		t.Error("expected another seed to give other books")
+	}
+}
+
+func TestGenerate_manyBooks(t *testing.T) {
+	d, err := Generate(Options{Seed: 1, Authors: 1, Books: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Books) != 1000 {
+		t.Errorf("expected 1000 books, got %d", len(d.Books))
+	}
+
+	if len(d.Books[0].Genres) != 0 {
+		t.Errorf("expected no genres when none were asked for, got %v", d.Books[0].Genres)
+	}
+}
+
+func TestGenerate_errors(t *testing.T) {
+	var tests = []struct {
+		name     string
+		opts     Options
+		expected string
+	}{
+		{"negative", Options{Books: -1}, "must not be negative"},
+		{"too many authors", Options{Authors: MaxAuthors + 1}, "authors"},
+		{"too many genres", Options{Genres: MaxGenres + 1}, "genres"},
+		{"books without authors", Options{Books: 1}, "at least one author"},
+	}
+
+	for _, e := range tests {
+		_, err := Generate(e.opts)
+		if err == nil || !strings.Contains(err.Error(), e.expected) {
+			t.Errorf("%s: expected an error containing %q but got %v", e.name, e.expected, err)
+		}
+	}
+}
+
+func TestCover(t *testing.T) {
+	d, err := Generate(Options{Seed: 3, Authors: 1, Books: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := jpeg.Decode(bytes.NewReader(d.Books[0].Cover))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size := img.Bounds().Size(); size.X != CoverWidth || size.Y != CoverHeight {
+		t.Errorf("expected a %dx%d cover, got %v", CoverWidth, CoverHeight, size)
+	}
+}
@@ -0,0 +1,190 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+	"vue-api/internal/i18n"
+)
+
+// EmailRX is the pattern an email address has to match. It is deliberately loose; the only
+// way to be certain an address is valid is to send mail to it
+var EmailRX = regexp.MustCompile(`^[a-zA-Z0-9.!#$%&'*+/=?^_{|}~-]+@[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?(?:\.[a-zA-Z0-9](?:[a-zA-Z0-9-]{0,61}[a-zA-Z0-9])?)+$`)
+
+// Validator collects validation errors, keyed by the name of the field they belong to. Messages
+// are given as i18n message keys, and stored already translated by Printer
+type Validator struct {
+	Errors  map[string][]string `json:"errors"`
+	Printer i18n.Printer        `json:"-"`
+}
+
+// New returns a Validator with no errors, which translates messages with the given printer
+func New(printer i18n.Printer) *Validator {
+	return &Validator{Errors: make(map[string][]string), Printer: printer}
+}
+
+// Valid reports whether no errors have been added
+func (v *Validator) Valid() bool {
+	return len(v.Errors) == 0
+}
+
+// AddError adds the message with the given key, formatted with args, to the errors for a field
+func (v *Validator) AddError(field, key string, args ...interface{}) {
+	v.Errors[field] = append(v.Errors[field], v.Printer.Sprintf(key, args...))
+}
+
+// Check adds the message with the given key to the errors for a field if ok is false
+func (v *Validator) Check(ok bool, field, key string, args ...interface{}) {
+	if !ok {
+		v.AddError(field, key, args...)
+	}
+}
+
+// Struct checks the fields of a struct (or pointer to struct) against the rules in their
+// validate tags, and adds an error for every rule that fails. Errors are keyed by the json
+// name of the field. Rules are separated by commas:
+//
+//	required     the field must not be its zero value (for slices, must not be empty)
+//	omitempty    skip the remaining rules when the field is its zero value
+//	min=N        strings must have at least N characters, numbers be at least N, slices hold at least N items
+//	max=N        strings must have at most N characters, numbers be at most N, slices hold at most N items
+//	max_bytes=N  strings must take at most N bytes, for limits which count bytes rather than characters
+//	email        the field must look like an email address
+//	oneof=a|b    the field must be one of the listed values
+//
+// For example:
+//
+//	Title string `json:"title" validate:"required,max=512"`
+func (v *Validator) Struct(s interface{}) {
+	value := reflect.Indirect(reflect.ValueOf(s))
+	if value.Kind() != reflect.Struct {
+		return
+	}
+
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+
+		rules := field.Tag.Get("validate")
+		if rules == "" || !field.IsExported() {
+			continue
+		}
+
+		v.checkField(fieldName(field), value.Field(i), rules)
+	}
+}
+
+// checkField applies the rules from one validate tag to a field
+func (v *Validator) checkField(name string, value reflect.Value, rules string) {
+	for _, rule := range strings.Split(rules, ",") {
+		rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+
+		switch rule {
+		case "omitempty":
+			if value.IsZero() {
+				return
+			}
+
+		case "required":
+			if value.IsZero() || (value.Kind() == reflect.Slice && value.Len() == 0) {
+				v.AddError(name, "validation.required")
+				// the other rules would only repeat the same complaint
+				return
+			}
+
+		case "min":
+			n := mustAtoi(param)
+			if size, isLength := measure(value); size < n {
+				if isLength && value.Kind() == reflect.String {
+					v.AddError(name, "validation.min_length", n)
+				} else if isLength {
+					v.AddError(name, "validation.min_items", n)
+				} else {
+					v.AddError(name, "validation.min", n)
+				}
+			}
+
+		case "max":
+			n := mustAtoi(param)
+			if size, isLength := measure(value); size > n {
+				if isLength && value.Kind() == reflect.String {
+					v.AddError(name, "validation.max_length", n)
+				} else if isLength {
+					v.AddError(name, "validation.max_items", n)
+				} else {
+					v.AddError(name, "validation.max", n)
+				}
+			}
+
+		case "max_bytes":
+			n := mustAtoi(param)
+			if value.Kind() != reflect.String {
+				panic(fmt.Sprintf("validator: max_bytes cannot be used on %s", value.Kind()))
+			}
+			if len(value.String()) > n {
+				v.AddError(name, "validation.max_bytes", n)
+			}
+
+		case "email":
+			if value.Kind() == reflect.String && !EmailRX.MatchString(value.String()) {
+				v.AddError(name, "validation.email")
+			}
+
+		case "oneof":
+			options := strings.Split(param, "|")
+			if !PermittedValue(fmt.Sprint(value.Interface()), options...) {
+				v.AddError(name, "validation.oneof", strings.Join(options, ", "))
+			}
+
+		default:
+			panic(fmt.Sprintf("validator: unknown rule %q on field %s", rule, name))
+		}
+	}
+}
+
+// PermittedValue reports whether value is one of the permitted values
+func PermittedValue[T comparable](value T, permittedValues ...T) bool {
+	for _, permitted := range permittedValues {
+		if value == permitted {
+			return true
+		}
+	}
+	return false
+}
+
+// measure returns the size of a value as seen by the min and max rules, and whether that
+// size is a length (of a string or slice) rather than a number
+func measure(value reflect.Value) (int, bool) {
+	switch value.Kind() {
+	case reflect.String:
+		return utf8.RuneCountInString(value.String()), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return value.Len(), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return int(value.Int()), false
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int(value.Uint()), false
+	default:
+		panic(fmt.Sprintf("validator: min and max cannot be used on %s", value.Kind()))
+	}
+}
+
+// fieldName returns the name a field is known by in JSON
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func mustAtoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		panic(fmt.Sprintf("validator: %q is not a number", s))
+	}
+	return n
+}
@@ -0,0 +1,104 @@
+package validator
+
+import (
+	"reflect"
+	"testing"
+	"vue-api/internal/i18n"
+)
+
+type testPayload struct {
+	Title    string `json:"title" validate:"required,max=5"`
+	Year     int    `json:"publication_year" validate:"required,min=1"`
+	Email    string `json:"email" validate:"omitempty,email"`
+	Active   int    `json:"active" validate:"oneof=0|1"`
+	Genres   []int  `json:"genre_ids" validate:"max=2"`
+	Password string `validate:"omitempty,min=3"`
+	Code     string `json:"code" validate:"max_bytes=4"`
+	Ignored  string
+}
+
+func TestValidator_Struct(t *testing.T) {
+	var tests = []struct {
+		name     string
+		payload  testPayload
+		expected map[string][]string
+	}{
+		{"valid", testPayload{Title: "Dune", Year: 1965, Email: "me@here.com", Active: 1}, map[string][]string{}},
+		{"missing", testPayload{}, map[string][]string{
+			"title":            {"must be provided"},
+			"publication_year": {"must be provided"},
+		}},
+		{"too long", testPayload{Title: "Émile et", Year: 1}, map[string][]string{
+			"title": {"must not be more than 5 characters long"},
+		}},
+		{"too small", testPayload{Title: "a", Year: -3}, map[string][]string{
+			"publication_year": {"must be at least 1"},
+		}},
+		{"bad email", testPayload{Title: "a", Year: 1, Email: "nobody"}, map[string][]string{
+			"email": {"must be a valid email address"},
+		}},
+		{"not permitted", testPayload{Title: "a", Year: 1, Active: 2}, map[string][]string{
+			"active": {"must be one of 0, 1"},
+		}},
+		{"too many items", testPayload{Title: "a", Year: 1, Genres: []int{1, 2, 3}}, map[string][]string{
+			"genre_ids": {"must not contain more than 2 items"},
+		}},
+		{"too many bytes", testPayload{Title: "a", Year: 1, Code: "ééé"}, map[string][]string{
+			"code": {"must not take more than 4 bytes"},
+		}},
+		{"no json name", testPayload{Title: "a", Year: 1, Password: "ab"}, map[string][]string{
+			"Password": {"must be at least 3 characters long"},
+		}},
+	}
+
+	for _, e := range tests {
+		v := New(i18n.NewPrinter(i18n.English))
+		v.Struct(&e.payload)
+
+		if !reflect.DeepEqual(v.Errors, e.expected) {
+			t.Errorf("%s: expected %v but got %v", e.name, e.expected, v.Errors)
+		}
+
+		if v.Valid() != (len(e.expected) == 0) {
+			t.Errorf("%s: Valid returned %t", e.name, v.Valid())
+		}
+	}
+}
+
+func TestValidator_Check(t *testing.T) {
+	v := New(i18n.NewPrinter(i18n.English))
+	v.Check(true, "title", "validation.email")
+	v.Check(false, "title", "validation.required")
+	v.Check(false, "title", "validation.max_length", 5)
+
+	if !reflect.DeepEqual(v.Errors["title"], []string{"must be provided", "must not be more than 5 characters long"}) {
+		t.Errorf("unexpected errors: %v", v.Errors)
+	}
+}
+
+func TestValidator_Translated(t *testing.T) {
+	v := New(i18n.NewPrinter(i18n.Spanish))
+	v.Struct(testPayload{Year: 1, Active: 3})
+
+	expected := map[string][]string{
+		"title":  {"es obligatorio"},
+		"active": {"debe ser uno de 0, 1"},
+	}
+
+	if !reflect.DeepEqual(v.Errors, expected) {
+		t.Errorf("expected %v but got %v", expected, v.Errors)
+	}
+}
+
+func TestValidator_UnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for an unknown rule")
+		}
+	}()
+
+	v := New(i18n.NewPrinter(i18n.English))
+	v.Struct(struct {
+		Name string `validate:"shiny"`
+	}{})
+}